@@ -0,0 +1,308 @@
+// Package analysis provides a go vet compatible analyzer for code using pglock. It reports:
+//
+//   - a lock id hard-coded in several places, in the same package or in a package it imports,
+//   - constant key names, such as pglock.NS("billing").Key("run"), whose ids collide with each other or with a
+//     hard-coded id,
+//   - a lock created with pglock.NewLock that is not closed on every path of the function creating it, and an
+//     acquisition on such a lock that is not released on every path.
+//
+// Run it with the pglockvet command: go vet -vettool=$(which pglockvet) ./...
+//
+// The analyzer is a module of its own, so that the pglock module keeps its Go version and does not depend on
+// golang.org/x/tools. It does not import pglock either: it derives key ids itself, checked against the interop
+// fixtures of pglock, and can be installed with go install at any version.
+package analysis
+
+import (
+	"fmt"
+	"go/ast"
+	"go/constant"
+	"go/token"
+	"go/types"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/analysis/passes/ctrlflow"
+	"golang.org/x/tools/go/analysis/passes/inspect"
+	"golang.org/x/tools/go/ast/astutil"
+	"golang.org/x/tools/go/ast/inspector"
+	"golang.org/x/tools/go/types/typeutil"
+)
+
+// pglockPath is the import path of the pglock package.
+const pglockPath = "github.com/allisson/go-pglock/v3"
+
+// Analyzer reports lock id collisions and locks that are not released on every path.
+var Analyzer = &analysis.Analyzer{
+	Name:      "pglock",
+	Doc:       "report duplicate pglock ids, colliding key names and locks not released on every path",
+	Requires:  []*analysis.Analyzer{inspect.Analyzer, ctrlflow.Analyzer},
+	FactTypes: []analysis.Fact{(*lockIDs)(nil)},
+	Run:       run,
+}
+
+// lockIDs is the package fact holding the lock ids a package uses, so that importers can check theirs against them.
+type lockIDs struct {
+	IDs map[int64][]idSource
+}
+
+// AFact implements analysis.Fact.
+func (*lockIDs) AFact() {}
+
+func (f *lockIDs) String() string {
+	ids := make([]int64, 0, len(f.IDs))
+	for id := range f.IDs {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	parts := make([]string, 0, len(ids))
+	for _, id := range ids {
+		for _, src := range f.IDs[id] {
+			parts = append(parts, fmt.Sprintf("%d=%s", id, src.describe()))
+		}
+	}
+	return "lockIDs(" + strings.Join(parts, ", ") + ")"
+}
+
+// idSource is where a lock id comes from.
+type idSource struct {
+	// Name names the source, a constant as "constant pkg.Name" and a key as `key "a/b"`. It is empty for any other
+	// expression. Uses with the same non-empty name are the same source and never collide.
+	Name string
+	// Pos is the position of the use, as package/file.go:line.
+	Pos string
+}
+
+func (s idSource) describe() string {
+	if s.Name == "" {
+		return "a literal"
+	}
+	return s.Name
+}
+
+func (s idSource) same(other idSource) bool {
+	if s.Name != "" || other.Name != "" {
+		return s.Name == other.Name
+	}
+	return s.Pos == other.Pos
+}
+
+// idUse is a lock id used by the package being analyzed.
+type idUse struct {
+	id  int64
+	src idSource
+	pos token.Pos
+}
+
+func run(pass *analysis.Pass) (interface{}, error) {
+	inspect := pass.ResultOf[inspect.Analyzer].(*inspector.Inspector)
+
+	var uses []idUse
+	inspect.Preorder([]ast.Node{(*ast.CallExpr)(nil)}, func(n ast.Node) {
+		if use, ok := lockIDUse(pass, n.(*ast.CallExpr)); ok {
+			uses = append(uses, use)
+		}
+	})
+	checkIDs(pass, uses)
+	checkReleases(pass)
+	return nil, nil
+}
+
+// checkIDs reports the uses colliding with an earlier use of the package or with a use of an imported package, and
+// exports the uses of the package as a fact.
+func checkIDs(pass *analysis.Pass, uses []idUse) {
+	imported := make(map[int64][]idSource)
+	for _, fact := range pass.AllPackageFacts() {
+		if fact.Package == pass.Pkg {
+			continue
+		}
+		for id, srcs := range fact.Fact.(*lockIDs).IDs {
+			imported[id] = append(imported[id], srcs...)
+		}
+	}
+
+	own := make(map[int64][]idSource)
+	for _, use := range uses {
+		// A use of an imported source is that lock: its collisions were reported in the package it comes from.
+		if other, ok := collision(use.src, own[use.id]); ok {
+			reportCollision(pass, use, other)
+		} else if _, same := find(use.src, imported[use.id]); !same {
+			if other, ok := collision(use.src, imported[use.id]); ok {
+				reportCollision(pass, use, other)
+			}
+		}
+		if _, ok := find(use.src, own[use.id]); !ok {
+			own[use.id] = append(own[use.id], use.src)
+		}
+	}
+	if len(own) > 0 {
+		pass.ExportPackageFact(&lockIDs{IDs: own})
+	}
+}
+
+// collision returns the first source of srcs that is not src.
+func collision(src idSource, srcs []idSource) (idSource, bool) {
+	for _, other := range srcs {
+		if !src.same(other) {
+			return other, true
+		}
+	}
+	return idSource{}, false
+}
+
+// find returns the source of srcs that is src.
+func find(src idSource, srcs []idSource) (idSource, bool) {
+	for _, other := range srcs {
+		if src.same(other) {
+			return other, true
+		}
+	}
+	return idSource{}, false
+}
+
+func reportCollision(pass *analysis.Pass, use idUse, other idSource) {
+	pass.Reportf(use.pos, "lock id %d (%s) is also used by %s at %s", use.id, use.src.describe(), other.describe(), other.Pos)
+}
+
+// lockIDUse returns the lock id used by call when it is a constant: the id argument of NewLock, or the key built by a
+// constant Namespace chain or by ParseKey.
+func lockIDUse(pass *analysis.Pass, call *ast.CallExpr) (idUse, bool) {
+	fn := pglockFunc(pass.TypesInfo, call)
+	if fn == nil {
+		return idUse{}, false
+	}
+	switch {
+	case fn.Name() == "NewLock" && recvName(fn) == "" && len(call.Args) >= 2:
+		arg := call.Args[1]
+		tv := pass.TypesInfo.Types[arg]
+		if tv.Value == nil {
+			return idUse{}, false
+		}
+		id, exact := constant.Int64Val(constant.ToInt(tv.Value))
+		if !exact {
+			return idUse{}, false
+		}
+		return idUse{id: id, src: idSource{Name: constName(pass.TypesInfo, arg), Pos: position(pass, arg.Pos())}, pos: arg.Pos()}, true
+	case fn.Name() == "Key" && recvName(fn) == "Namespace" && len(call.Args) == 1:
+		sel, ok := astutil.Unparen(call.Fun).(*ast.SelectorExpr)
+		if !ok {
+			return idUse{}, false
+		}
+		segments, ok := namespace(pass.TypesInfo, sel.X)
+		name, isConst := constString(pass.TypesInfo, call.Args[0])
+		if !ok || !isConst {
+			return idUse{}, false
+		}
+		return keyUse(pass, newKey(append(segments, name)), call)
+	case fn.Name() == "ParseKey" && recvName(fn) == "" && len(call.Args) == 1:
+		name, ok := constString(pass.TypesInfo, call.Args[0])
+		if !ok {
+			return idUse{}, false
+		}
+		key, err := parseKey(name)
+		if err != nil {
+			return idUse{}, false
+		}
+		return keyUse(pass, key, call)
+	}
+	return idUse{}, false
+}
+
+func keyUse(pass *analysis.Pass, key key, call *ast.CallExpr) (idUse, bool) {
+	return idUse{id: key.id, src: idSource{Name: fmt.Sprintf("key %q", key.name), Pos: position(pass, call.Pos())}, pos: call.Pos()}, true
+}
+
+// namespace evaluates a chain of NS calls with constant arguments, such as pglock.NS("a").NS("b"), to its segments.
+func namespace(info *types.Info, expr ast.Expr) ([]string, bool) {
+	call, ok := astutil.Unparen(expr).(*ast.CallExpr)
+	if !ok || len(call.Args) != 1 {
+		return nil, false
+	}
+	fn := pglockFunc(info, call)
+	if fn == nil || fn.Name() != "NS" {
+		return nil, false
+	}
+	name, ok := constString(info, call.Args[0])
+	if !ok {
+		return nil, false
+	}
+	switch recvName(fn) {
+	case "":
+		return []string{name}, true
+	case "Namespace":
+		sel, ok := astutil.Unparen(call.Fun).(*ast.SelectorExpr)
+		if !ok {
+			return nil, false
+		}
+		parent, ok := namespace(info, sel.X)
+		if !ok {
+			return nil, false
+		}
+		return append(parent[:len(parent):len(parent)], name), true
+	}
+	return nil, false
+}
+
+// pglockFunc returns the pglock function or method called by call, nil when call calls anything else.
+func pglockFunc(info *types.Info, call *ast.CallExpr) *types.Func {
+	fn, _ := typeutil.Callee(info, call).(*types.Func)
+	if fn == nil || fn.Pkg() == nil || fn.Pkg().Path() != pglockPath {
+		return nil
+	}
+	return fn
+}
+
+// recvName returns the name of the receiver type of fn, empty for a function.
+func recvName(fn *types.Func) string {
+	recv := fn.Type().(*types.Signature).Recv()
+	if recv == nil {
+		return ""
+	}
+	t := recv.Type()
+	if ptr, ok := t.(*types.Pointer); ok {
+		t = ptr.Elem()
+	}
+	if named, ok := t.(*types.Named); ok {
+		return named.Obj().Name()
+	}
+	return ""
+}
+
+// constName names the constant expr refers to, possibly through a conversion, empty for any other expression.
+func constName(info *types.Info, expr ast.Expr) string {
+	expr = astutil.Unparen(expr)
+	if call, ok := expr.(*ast.CallExpr); ok && len(call.Args) == 1 && info.Types[call.Fun].IsType() {
+		return constName(info, call.Args[0])
+	}
+	var ident *ast.Ident
+	switch e := expr.(type) {
+	case *ast.Ident:
+		ident = e
+	case *ast.SelectorExpr:
+		ident = e.Sel
+	default:
+		return ""
+	}
+	c, ok := info.Uses[ident].(*types.Const)
+	if !ok || c.Pkg() == nil {
+		return ""
+	}
+	return "constant " + c.Pkg().Path() + "." + c.Name()
+}
+
+// constString returns the value of a constant string expression.
+func constString(info *types.Info, expr ast.Expr) (string, bool) {
+	tv := info.Types[expr]
+	if tv.Value == nil || tv.Value.Kind() != constant.String {
+		return "", false
+	}
+	return constant.StringVal(tv.Value), true
+}
+
+// position formats pos as package/file.go:line.
+func position(pass *analysis.Pass, pos token.Pos) string {
+	p := pass.Fset.Position(pos)
+	return fmt.Sprintf("%s/%s:%d", pass.Pkg.Path(), filepath.Base(p.Filename), p.Line)
+}
@@ -0,0 +1,18 @@
+// Command pglockvet runs the pglock analyzer, see package analysis for what it reports.
+//
+// Usage:
+//
+//	go install github.com/allisson/go-pglock/v3/analysis/cmd/pglockvet@latest
+//	go vet -vettool=$(which pglockvet) ./...
+//
+// It can also run on its own, as pglockvet ./...
+package main
+
+import (
+	"github.com/allisson/go-pglock/v3/analysis"
+	"golang.org/x/tools/go/analysis/singlechecker"
+)
+
+func main() {
+	singlechecker.Main(analysis.Analyzer)
+}
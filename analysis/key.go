@@ -0,0 +1,57 @@
+package analysis
+
+import (
+	"fmt"
+	"hash/fnv"
+	"strings"
+)
+
+// segmentEscaper escapes a key segment like pglock.Namespace does.
+var segmentEscaper = strings.NewReplacer(`\`, `\\`, "/", `\/`)
+
+// key is a lock key name and its id, derived like pglock.Namespace.Key: the 64-bit FNV-1a hash of the name read as a
+// two's complement int64. The derivation is checked against the interop fixtures of pglock.
+type key struct {
+	id   int64
+	name string
+}
+
+// newKey returns the key of the segments, the last one being the name inside the namespace.
+func newKey(segments []string) key {
+	escaped := make([]string, len(segments))
+	for i, segment := range segments {
+		escaped[i] = segmentEscaper.Replace(segment)
+	}
+	name := strings.Join(escaped, "/")
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(name))
+	return key{id: int64(h.Sum64()), name: name}
+}
+
+// parseKey returns the key of a full key name, like pglock.ParseKey.
+func parseKey(name string) (key, error) {
+	var (
+		segments []string
+		segment  strings.Builder
+	)
+	for i := 0; i < len(name); i++ {
+		switch c := name[i]; c {
+		case '/':
+			segments = append(segments, segment.String())
+			segment.Reset()
+		case '\\':
+			i++
+			if i == len(name) || (name[i] != '/' && name[i] != '\\') {
+				return key{}, fmt.Errorf("key %q has an invalid escape at offset %d", name, i-1)
+			}
+			segment.WriteByte(name[i])
+		default:
+			segment.WriteByte(c)
+		}
+	}
+	segments = append(segments, segment.String())
+	if len(segments) < 2 {
+		return key{}, fmt.Errorf("key %q needs at least a namespace and a name", name)
+	}
+	return newKey(segments), nil
+}
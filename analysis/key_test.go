@@ -0,0 +1,37 @@
+package analysis
+
+import (
+	"encoding/json"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestKeyInteropFixtures checks the key derivation against the fixtures of the pglock module.
+func TestKeyInteropFixtures(t *testing.T) {
+	data, err := os.ReadFile("../testdata/interop/keys.json")
+	assert.Nil(t, err)
+	fixtures := struct {
+		Namespaced []struct {
+			Name string `json:"name"`
+			ID   int64  `json:"id"`
+		} `json:"namespaced"`
+	}{}
+	assert.Nil(t, json.Unmarshal(data, &fixtures))
+	assert.NotEmpty(t, fixtures.Namespaced)
+
+	for _, fixture := range fixtures.Namespaced {
+		key, err := parseKey(fixture.Name)
+		assert.Nil(t, err)
+		assert.Equal(t, fixture.Name, key.name)
+		assert.Equal(t, fixture.ID, key.id, fixture.Name)
+	}
+}
+
+func TestParseKeyInvalid(t *testing.T) {
+	for _, name := range []string{"single", `a/b\`, `a/b\c`} {
+		_, err := parseKey(name)
+		assert.NotNil(t, err, name)
+	}
+}
@@ -0,0 +1,376 @@
+package analysis
+
+import (
+	"go/ast"
+	"go/token"
+	"go/types"
+
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/analysis/passes/ctrlflow"
+	"golang.org/x/tools/go/analysis/passes/inspect"
+	"golang.org/x/tools/go/ast/astutil"
+	"golang.org/x/tools/go/ast/inspector"
+	"golang.org/x/tools/go/cfg"
+)
+
+// acquireMethods are the Lock methods acquiring the lock.
+var acquireMethods = map[string]bool{
+	"Lock":           true,
+	"RLock":          true,
+	"TryLockOrError": true,
+	"WaitAndLock":    true,
+	"WaitAndRLock":   true,
+}
+
+// releaseMethods are the Lock methods releasing an acquisition. Close releases every acquisition with the session.
+var releaseMethods = map[string]bool{
+	"Close":        true,
+	"RUnlock":      true,
+	"Unlock":       true,
+	"UnlockAll":    true,
+	"UnlockWithin": true,
+}
+
+// checkReleases reports the locks created with NewLock that are not closed on some path to a return of the function
+// creating them. Passing the lock to another function, storing it or returning it hands it over and ends the check.
+// The main function is skipped: its return ends the process and with it the session.
+//
+// It also reports the acquisitions not released on some path of a function releasing the lock elsewhere. A function
+// that never releases the lock, or defers the release, is taken to hand the acquisition over to its caller.
+func checkReleases(pass *analysis.Pass) {
+	inspect := pass.ResultOf[inspect.Analyzer].(*inspector.Inspector)
+	cfgs := pass.ResultOf[ctrlflow.Analyzer].(*ctrlflow.CFGs)
+
+	inspect.Preorder([]ast.Node{(*ast.FuncDecl)(nil), (*ast.FuncLit)(nil)}, func(n ast.Node) {
+		switch n := n.(type) {
+		case *ast.FuncDecl:
+			if n.Body == nil || (pass.Pkg.Name() == "main" && n.Recv == nil && n.Name.Name == "main") {
+				return
+			}
+			checkFunc(pass, n.Body, cfgs.FuncDecl(n))
+		case *ast.FuncLit:
+			checkFunc(pass, n.Body, cfgs.FuncLit(n))
+		}
+	})
+}
+
+// checkFunc checks the statements of body, not counting nested function literals, which are checked on their own.
+func checkFunc(pass *analysis.Pass, body *ast.BlockStmt, g *cfg.CFG) {
+	ast.Inspect(body, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.FuncLit:
+			return false
+		case *ast.AssignStmt:
+			if lock, results, ok := newLock(pass, body, n); ok {
+				if ret := unreleased(pass.TypesInfo, g, n, results, closes(pass.TypesInfo, lock)); ret != nil {
+					pass.Reportf(n.Pos(), "%s is not closed on every path, its session leaks (see the return at line %d)",
+						lock.Name(), pass.Fset.Position(ret.Pos()).Line)
+				}
+			}
+		}
+		if stmt, ok := n.(ast.Stmt); ok {
+			checkAcquisition(pass, body, g, stmt)
+		}
+		return true
+	})
+}
+
+// checkAcquisition reports stmt when it acquires a lock the function releases, but not on every path.
+func checkAcquisition(pass *analysis.Pass, body *ast.BlockStmt, g *cfg.CFG, stmt ast.Stmt) {
+	lock, method, results, ok := acquisition(pass.TypesInfo, stmt)
+	if !ok {
+		return
+	}
+	released := releases(pass.TypesInfo, lock)
+	paired, deferred := false, false
+	ast.Inspect(body, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.FuncLit:
+			return false
+		case *ast.DeferStmt:
+			deferred = deferred || released(n)
+			return false
+		case *ast.SelectorExpr:
+			paired = paired || (variable(pass.TypesInfo, n.X) == lock && releaseMethods[n.Sel.Name])
+		}
+		return true
+	})
+	if !paired || deferred {
+		return
+	}
+	if ret := unreleased(pass.TypesInfo, g, stmt, results, released); ret != nil {
+		pass.Reportf(stmt.Pos(), "%s.%s is not released on every path (see the return at line %d)",
+			lock.Name(), method, pass.Fset.Position(ret.Pos()).Line)
+	}
+}
+
+// newLock returns the variable assign stores the result of NewLock in, when it is local to body.
+func newLock(pass *analysis.Pass, body *ast.BlockStmt, assign *ast.AssignStmt) (*types.Var, outcome, bool) {
+	if len(assign.Lhs) != 2 || len(assign.Rhs) != 1 {
+		return nil, outcome{}, false
+	}
+	call, ok := astutil.Unparen(assign.Rhs[0]).(*ast.CallExpr)
+	if !ok {
+		return nil, outcome{}, false
+	}
+	if fn := pglockFunc(pass.TypesInfo, call); fn == nil || fn.Name() != "NewLock" || recvName(fn) != "" {
+		return nil, outcome{}, false
+	}
+	ident, ok := assign.Lhs[0].(*ast.Ident)
+	if !ok {
+		return nil, outcome{}, false
+	}
+	if ident.Name == "_" {
+		pass.Reportf(ident.Pos(), "the lock returned by NewLock is discarded and never closed, its session leaks")
+		return nil, outcome{}, false
+	}
+	lock, ok := pass.TypesInfo.ObjectOf(ident).(*types.Var)
+	if !ok || lock.Pos() < body.Pos() || lock.Pos() >= body.End() {
+		return nil, outcome{}, false
+	}
+	return lock, outcome{call: call, err: variable(pass.TypesInfo, assign.Lhs[1])}, true
+}
+
+// acquisition returns the lock variable acquired by stmt, when it is a statement calling an acquire method.
+func acquisition(info *types.Info, stmt ast.Stmt) (*types.Var, string, outcome, bool) {
+	var expr ast.Expr
+	var lhs []ast.Expr
+	switch stmt := stmt.(type) {
+	case *ast.ExprStmt:
+		expr = stmt.X
+	case *ast.AssignStmt:
+		if len(stmt.Rhs) != 1 {
+			return nil, "", outcome{}, false
+		}
+		expr, lhs = stmt.Rhs[0], stmt.Lhs
+	case *ast.IfStmt:
+		// if lock.TryLockOrError(ctx) != nil { ... }
+		expr = stmt.Cond
+		if bin, ok := astutil.Unparen(expr).(*ast.BinaryExpr); ok {
+			expr = bin.X
+		}
+	default:
+		return nil, "", outcome{}, false
+	}
+	call, ok := astutil.Unparen(expr).(*ast.CallExpr)
+	if !ok {
+		return nil, "", outcome{}, false
+	}
+	sel, ok := astutil.Unparen(call.Fun).(*ast.SelectorExpr)
+	if !ok || !acquireMethods[sel.Sel.Name] {
+		return nil, "", outcome{}, false
+	}
+	if fn := pglockFunc(info, call); fn == nil || recvName(fn) != "Lock" {
+		return nil, "", outcome{}, false
+	}
+	lock := variable(info, sel.X)
+	if lock == nil {
+		return nil, "", outcome{}, false
+	}
+	results := outcome{call: call}
+	switch len(lhs) {
+	case 1:
+		results.err = variable(info, lhs[0])
+	case 2:
+		results.ok, results.err = variable(info, lhs[0]), variable(info, lhs[1])
+	}
+	return lock, sel.Sel.Name, results, true
+}
+
+// closes returns whether a node closes lock or hands it over.
+func closes(info *types.Info, lock *types.Var) func(ast.Node) bool {
+	return uses(info, lock, func(method string) bool { return method == "Close" })
+}
+
+// releases returns whether a node releases an acquisition of lock or hands the lock over.
+func releases(info *types.Info, lock *types.Var) func(ast.Node) bool {
+	return uses(info, lock, func(method string) bool { return releaseMethods[method] })
+}
+
+// uses returns whether a node refers to lock other than as the receiver of a method for which release is false.
+// References inside a function literal count, the literal may run at any time.
+func uses(info *types.Info, lock *types.Var, release func(method string) bool) func(ast.Node) bool {
+	return func(node ast.Node) bool {
+		found := false
+		ast.Inspect(node, func(n ast.Node) bool {
+			switch n := n.(type) {
+			case *ast.SelectorExpr:
+				if variable(info, n.X) == lock {
+					found = release(n.Sel.Name)
+					return false
+				}
+			case *ast.Ident:
+				if info.Uses[n] == lock {
+					found = true
+				}
+			}
+			return !found
+		})
+		return found
+	}
+}
+
+// unreleased returns a return statement reachable from stmt without passing a node for which released is true, nil
+// when there is none. While the results of stmt hold the values it produced, the branches of the if statements
+// testing them for a failure are not followed: nothing was created or acquired there.
+func unreleased(info *types.Info, g *cfg.CFG, stmt ast.Node, results outcome, released func(ast.Node) bool) *ast.ReturnStmt {
+	block, index := findNode(g, stmt)
+	if block == nil {
+		return nil
+	}
+
+	type visit struct {
+		block *cfg.Block
+		fresh bool
+	}
+	seen := make(map[visit]bool)
+	var search func(b *cfg.Block, nodes []ast.Node, fresh bool) *ast.ReturnStmt
+	search = func(b *cfg.Block, nodes []ast.Node, fresh bool) *ast.ReturnStmt {
+		for _, n := range nodes {
+			if released(n) {
+				return nil
+			}
+			if fresh && results.assigned(info, n) {
+				fresh = false
+			}
+		}
+		if ret := b.Return(); ret != nil {
+			return ret
+		}
+		succs := b.Succs
+		if fresh && len(succs) == 2 && len(b.Nodes) > 0 {
+			if cond, ok := b.Nodes[len(b.Nodes)-1].(ast.Expr); ok {
+				switch results.test(info, cond) {
+				case failed:
+					succs = succs[1:]
+				case succeeded:
+					succs = succs[:1]
+				}
+			}
+		}
+		for _, succ := range succs {
+			if seen[visit{succ, fresh}] {
+				continue
+			}
+			seen[visit{succ, fresh}] = true
+			if ret := search(succ, succ.Nodes, fresh); ret != nil {
+				return ret
+			}
+		}
+		return nil
+	}
+	// An if statement with the call in its condition is its own test.
+	if isIfCond(block, index) {
+		return search(block, nil, true)
+	}
+	return search(block, block.Nodes[index+1:], true)
+}
+
+// findNode returns the block and index of the node of g that is or contains stmt. An if statement is found through
+// its condition.
+func findNode(g *cfg.CFG, stmt ast.Node) (*cfg.Block, int) {
+	if ifStmt, ok := stmt.(*ast.IfStmt); ok {
+		stmt = ifStmt.Cond
+	}
+	for _, b := range g.Blocks {
+		for i, n := range b.Nodes {
+			if n.Pos() <= stmt.Pos() && stmt.End() <= n.End() {
+				return b, i
+			}
+		}
+	}
+	return nil, 0
+}
+
+// isIfCond reports whether the node at index of block is the condition ending it.
+func isIfCond(block *cfg.Block, index int) bool {
+	_, ok := block.Nodes[index].(ast.Expr)
+	return ok && index == len(block.Nodes)-1 && len(block.Succs) == 2
+}
+
+// variable returns the variable expr refers to, nil when it is not an identifier of a variable.
+func variable(info *types.Info, expr ast.Expr) *types.Var {
+	ident, ok := astutil.Unparen(expr).(*ast.Ident)
+	if !ok {
+		return nil
+	}
+	v, _ := info.ObjectOf(ident).(*types.Var)
+	return v
+}
+
+// outcome holds the results of a call creating or acquiring a lock.
+type outcome struct {
+	call *ast.CallExpr
+	ok   *types.Var
+	err  *types.Var
+}
+
+// Results of outcome.test.
+const (
+	unknown = iota
+	failed
+	succeeded
+)
+
+// test returns whether cond is true when the call failed, when it succeeded, or neither.
+func (o outcome) test(info *types.Info, cond ast.Expr) int {
+	switch cond := astutil.Unparen(cond).(type) {
+	case *ast.BinaryExpr:
+		switch cond.Op {
+		case token.NEQ, token.EQL:
+			if !o.isErr(info, cond.X) || !info.Types[cond.Y].IsNil() {
+				return unknown
+			}
+			if cond.Op == token.NEQ {
+				return failed
+			}
+			return succeeded
+		case token.LOR, token.LAND:
+			x, y := o.test(info, cond.X), o.test(info, cond.Y)
+			if x != y {
+				return unknown
+			}
+			if (cond.Op == token.LOR) == (x == failed) {
+				return x
+			}
+		}
+	case *ast.UnaryExpr:
+		if cond.Op == token.NOT {
+			switch o.test(info, cond.X) {
+			case failed:
+				return succeeded
+			case succeeded:
+				return failed
+			}
+		}
+	case *ast.Ident:
+		if o.ok != nil && info.Uses[cond] == o.ok {
+			return succeeded
+		}
+	}
+	return unknown
+}
+
+// isErr reports whether expr is the error of the call.
+func (o outcome) isErr(info *types.Info, expr ast.Expr) bool {
+	expr = astutil.Unparen(expr)
+	if expr == o.call {
+		return true
+	}
+	ident, ok := expr.(*ast.Ident)
+	return ok && o.err != nil && info.Uses[ident] == o.err
+}
+
+// assigned reports whether node assigns one of the results again, after which its tests say nothing about the call.
+func (o outcome) assigned(info *types.Info, node ast.Node) bool {
+	assign, ok := node.(*ast.AssignStmt)
+	if !ok {
+		return false
+	}
+	for _, lhs := range assign.Lhs {
+		if v := variable(info, lhs); v != nil && (v == o.ok || v == o.err) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,36 @@
+// Package pglock is a stub of the pglock API the analyzer looks at.
+package pglock
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+type Lock struct{}
+
+func NewLock(ctx context.Context, id int64, db *sql.DB) (Lock, error) { return Lock{}, nil }
+
+func (l *Lock) Lock(ctx context.Context) (bool, error)   { return true, nil }
+func (l *Lock) RLock(ctx context.Context) (bool, error)  { return true, nil }
+func (l *Lock) WaitAndLock(ctx context.Context) error    { return nil }
+func (l *Lock) WaitAndRLock(ctx context.Context) error   { return nil }
+func (l *Lock) TryLockOrError(ctx context.Context) error { return nil }
+func (l *Lock) Unlock(ctx context.Context) error         { return nil }
+func (l *Lock) RUnlock(ctx context.Context) error        { return nil }
+func (l *Lock) UnlockWithin(d time.Duration) error       { return nil }
+func (l *Lock) Close() error                             { return nil }
+func (l *Lock) Stats() int                               { return 0 }
+
+type Namespace struct{}
+
+func NS(name string) Namespace               { return Namespace{} }
+func (n Namespace) NS(name string) Namespace { return Namespace{} }
+func (n Namespace) Key(name string) Key      { return Key{} }
+
+type Key struct {
+	ID   int64
+	Name string
+}
+
+func ParseKey(name string) (Key, error) { return Key{}, nil }
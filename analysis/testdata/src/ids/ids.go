@@ -0,0 +1,55 @@
+package ids // want package:`lockIDs\(1=constant ids.Jobs, 1=a literal, 2=constant ids.Reports, 2=constant ids.Exports, 1967617716387303423=key "billing/invoices/run", 1967617716387303423=a literal\)`
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/allisson/go-pglock/v3"
+)
+
+const (
+	Jobs    = 1
+	Reports = 2
+	Exports = 2
+)
+
+func jobs(ctx context.Context, db *sql.DB) (pglock.Lock, error) {
+	return pglock.NewLock(ctx, Jobs, db)
+}
+
+func jobsAgain(ctx context.Context, db *sql.DB) (pglock.Lock, error) {
+	return pglock.NewLock(ctx, (Jobs), db)
+}
+
+func reports(ctx context.Context, db *sql.DB) (pglock.Lock, error) {
+	return pglock.NewLock(ctx, int64(Reports), db)
+}
+
+func exports(ctx context.Context, db *sql.DB) (pglock.Lock, error) {
+	return pglock.NewLock(ctx, Exports, db) // want `lock id 2 \(constant ids.Exports\) is also used by constant ids.Reports at ids/ids.go:25`
+}
+
+func literal(ctx context.Context, db *sql.DB) (pglock.Lock, error) {
+	return pglock.NewLock(ctx, 1, db) // want `lock id 1 \(a literal\) is also used by constant ids.Jobs at ids/ids.go:17`
+}
+
+func dynamic(ctx context.Context, db *sql.DB, id int64) (pglock.Lock, error) {
+	return pglock.NewLock(ctx, id, db)
+}
+
+func invoices() int64 {
+	return pglock.NS("billing").NS("invoices").Key("run").ID
+}
+
+func parsed() (pglock.Key, error) {
+	return pglock.ParseKey("billing/invoices/run")
+}
+
+func fromKey(ctx context.Context, db *sql.DB) (pglock.Lock, error) {
+	return pglock.NewLock(ctx, 1967617716387303423, db) // want `lock id 1967617716387303423 \(a literal\) is also used by key "billing/invoices/run" at ids/ids.go:41`
+}
+
+func variable(ctx context.Context, db *sql.DB) int64 {
+	ns := pglock.NS("billing")
+	return ns.Key("run").ID
+}
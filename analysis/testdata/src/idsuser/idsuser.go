@@ -0,0 +1,28 @@
+package idsuser // want package:`lockIDs\(1=constant ids.Jobs, 1=constant idsuser.Local, 2=a literal, 1967617716387303423=key "billing/invoices/run"\)`
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/allisson/go-pglock/v3"
+
+	"ids"
+)
+
+const Local = 1
+
+func jobs(ctx context.Context, db *sql.DB) (pglock.Lock, error) {
+	return pglock.NewLock(ctx, ids.Jobs, db)
+}
+
+func reports(ctx context.Context, db *sql.DB) (pglock.Lock, error) {
+	return pglock.NewLock(ctx, 2, db) // want `lock id 2 \(a literal\) is also used by constant ids.Reports at ids/ids.go:25`
+}
+
+func local(ctx context.Context, db *sql.DB) (pglock.Lock, error) {
+	return pglock.NewLock(ctx, Local, db) // want `lock id 1 \(constant idsuser.Local\) is also used by constant ids.Jobs at idsuser/idsuser.go:15`
+}
+
+func invoices() int64 {
+	return pglock.NS("billing").NS("invoices").Key("run").ID
+}
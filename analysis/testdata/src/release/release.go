@@ -0,0 +1,136 @@
+package release
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/allisson/go-pglock/v3"
+)
+
+func deferred(ctx context.Context, db *sql.DB, id int64) error {
+	lock, err := pglock.NewLock(ctx, id, db)
+	if err != nil {
+		return err
+	}
+	defer lock.Close()
+
+	ok, err := lock.Lock(ctx)
+	if err != nil || !ok {
+		return err
+	}
+	return nil
+}
+
+func closedOnEveryPath(ctx context.Context, db *sql.DB, id int64) error {
+	lock, err := pglock.NewLock(ctx, id, db)
+	if err != nil {
+		return err
+	}
+	ok, err := lock.Lock(ctx)
+	if err != nil {
+		_ = lock.Close()
+		return err
+	}
+	if !ok {
+		return lock.Close()
+	}
+	if err := lock.Unlock(ctx); err != nil {
+		_ = lock.Close()
+		return err
+	}
+	return lock.Close()
+}
+
+func notClosed(ctx context.Context, db *sql.DB, id int64) error {
+	lock, err := pglock.NewLock(ctx, id, db) // want `lock is not closed on every path, its session leaks \(see the return at line 51\)`
+	if err != nil {
+		return err
+	}
+	if err := lock.WaitAndLock(ctx); err != nil {
+		return err
+	}
+	return lock.Close()
+}
+
+func discarded(ctx context.Context, db *sql.DB, id int64) error {
+	_, err := pglock.NewLock(ctx, id, db) // want `the lock returned by NewLock is discarded and never closed, its session leaks`
+	return err
+}
+
+type worker struct {
+	lock pglock.Lock
+}
+
+func handedOver(ctx context.Context, db *sql.DB, id int64) (*worker, error) {
+	lock, err := pglock.NewLock(ctx, id, db)
+	if err != nil {
+		return nil, err
+	}
+	return &worker{lock: lock}, nil
+}
+
+func returned(ctx context.Context, db *sql.DB, id int64) (pglock.Lock, error) {
+	lock, err := pglock.NewLock(ctx, id, db)
+	if err != nil {
+		return pglock.Lock{}, err
+	}
+	return lock, nil
+}
+
+func unreleased(ctx context.Context, lock *pglock.Lock, work func() error) error {
+	if err := lock.WaitAndLock(ctx); err != nil { // want `lock.WaitAndLock is not released on every path \(see the return at line 86\)`
+		return err
+	}
+	if err := work(); err != nil {
+		return err
+	}
+	return lock.Unlock(ctx)
+}
+
+func released(ctx context.Context, lock *pglock.Lock, work func() error) error {
+	ok, err := lock.Lock(ctx)
+	if !ok || err != nil {
+		return err
+	}
+	if err := work(); err != nil {
+		_ = lock.Unlock(ctx)
+		return err
+	}
+	return lock.Unlock(ctx)
+}
+
+func tryLock(ctx context.Context, lock *pglock.Lock, work func() error) error {
+	if lock.TryLockOrError(ctx) != nil {
+		return errors.New("busy")
+	}
+	err := work()
+	_ = lock.Unlock(ctx)
+	return err
+}
+
+func errReused(ctx context.Context, lock *pglock.Lock, work func() error) error {
+	_, err := lock.Lock(ctx) // want `lock.Lock is not released on every path \(see the return at line 116\)`
+	err = work()
+	if err != nil {
+		return err
+	}
+	return lock.Unlock(ctx)
+}
+
+func acquireHelper(ctx context.Context, lock *pglock.Lock) error {
+	if err := lock.WaitAndLock(ctx); err != nil {
+		return err
+	}
+	return nil
+}
+
+func literal(ctx context.Context, db *sql.DB, id int64) func() error {
+	return func() error {
+		lock, err := pglock.NewLock(ctx, id, db) // want `lock is not closed on every path, its session leaks \(see the return at line 134\)`
+		if err != nil {
+			return err
+		}
+		return lock.WaitAndLock(ctx)
+	}
+}
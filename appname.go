@@ -0,0 +1,125 @@
+package pglock
+
+import (
+	"context"
+	"strconv"
+	"strings"
+)
+
+// maxApplicationName is the longest application_name the server keeps (NAMEDATALEN - 1), longer values are truncated.
+const maxApplicationName = 63
+
+// HolderInfo is the lock metadata a session publishes in its application_name, see WithApplicationName.
+type HolderInfo struct {
+	// ID is the advisory lock id.
+	ID int64 `json:"id"`
+	// Instance identifies the process holding the session, for example a hostname or pod name.
+	Instance string `json:"instance"`
+	// CorrelationID identifies the request that opened the session or made the latest acquisition, see
+	// WithCorrelationID.
+	CorrelationID string `json:"correlation_id,omitempty"`
+	// Purpose is the purpose of the latest acquisition, see WithPurpose.
+	Purpose string `json:"purpose,omitempty"`
+}
+
+// AppNameCodec packs HolderInfo into an application_name of at most 63 printable ASCII characters and parses it back.
+type AppNameCodec interface {
+	Encode(info HolderInfo) string
+	Decode(applicationName string) (HolderInfo, bool)
+}
+
+// CompactAppNameCodec encodes HolderInfo as "pglock/<id in base 36>/<instance>/<correlation id>/<purpose>".
+// The purpose is truncated first, then the instance and then the correlation id to fit the length limit.
+// Slashes in the instance and the correlation id are replaced with underscores, and characters outside printable
+// ASCII with question marks, as the server does.
+type CompactAppNameCodec struct{}
+
+const compactAppNamePrefix = "pglock/"
+
+// Encode implements AppNameCodec.
+func (CompactAppNameCodec) Encode(info HolderInfo) string {
+	name := compactAppNamePrefix + strconv.FormatInt(info.ID, 36) + "/"
+	instance := strings.ReplaceAll(printableASCII(info.Instance), "/", "_")
+	correlationID := strings.ReplaceAll(printableASCII(info.CorrelationID), "/", "_")
+	// Two separators follow the instance.
+	room := maxApplicationName - 2 - len(name)
+	if len(correlationID) > room {
+		correlationID = correlationID[:room]
+	}
+	room -= len(correlationID)
+	if len(instance) > room {
+		instance = instance[:room]
+	}
+	name += instance + "/" + correlationID + "/" + printableASCII(info.Purpose)
+	if len(name) > maxApplicationName {
+		name = name[:maxApplicationName]
+	}
+	return name
+}
+
+// Decode implements AppNameCodec.
+func (CompactAppNameCodec) Decode(applicationName string) (HolderInfo, bool) {
+	if !strings.HasPrefix(applicationName, compactAppNamePrefix) {
+		return HolderInfo{}, false
+	}
+	parts := strings.SplitN(strings.TrimPrefix(applicationName, compactAppNamePrefix), "/", 4)
+	if len(parts) != 4 {
+		return HolderInfo{}, false
+	}
+	id, err := strconv.ParseInt(parts[0], 36, 64)
+	if err != nil {
+		return HolderInfo{}, false
+	}
+	return HolderInfo{ID: id, Instance: parts[1], CorrelationID: parts[2], Purpose: parts[3]}, true
+}
+
+// printableASCII replaces the characters the server does not keep in application_name.
+func printableASCII(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r < 0x20 || r > 0x7e {
+			return '?'
+		}
+		return r
+	}, s)
+}
+
+// WithApplicationName makes the lock session publish the lock id, instance, correlation id and purpose of the latest
+// acquisition in its application_name using codec, CompactAppNameCodec if nil.
+// Tools inspecting pg_stat_activity get richer holder information, parse it back with SessionSnapshot.Info.
+// Without it the application_name is only changed while a purpose or a correlation id is set, see WithPurpose and
+// WithCorrelationID.
+// Statements of WithSessionSetup run after it.
+func WithApplicationName(instance string, codec AppNameCodec) Option {
+	return func(l *Lock) {
+		if codec == nil {
+			codec = CompactAppNameCodec{}
+		}
+		l.appNameCodec = codec
+		l.instance = instance
+	}
+}
+
+// setApplicationName publishes the holder information of the lock with purpose and the correlation id of ctx in the
+// session application_name.
+func (l *Lock) setApplicationName(ctx context.Context, purpose string) error {
+	_, err := l.conn.ExecContext(ctx, "SELECT set_config('application_name', $1, false)", l.applicationName(purpose, l.correlationID(ctx)))
+	return err
+}
+
+// applicationName encodes the holder information of the lock with purpose and correlationID, with CompactAppNameCodec
+// if no codec was set.
+func (l *Lock) applicationName(purpose, correlationID string) string {
+	codec := l.appNameCodec
+	if codec == nil {
+		codec = CompactAppNameCodec{}
+	}
+	return codec.Encode(HolderInfo{ID: l.id, Instance: l.instance, CorrelationID: correlationID, Purpose: purpose})
+}
+
+// Info decodes the holder information published by WithApplicationName with codec, CompactAppNameCodec if nil.
+func (s SessionSnapshot) Info(codec AppNameCodec) (HolderInfo, bool) {
+	if codec == nil {
+		codec = CompactAppNameCodec{}
+	}
+	return codec.Decode(s.ApplicationName)
+}
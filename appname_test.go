@@ -0,0 +1,85 @@
+package pglock
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompactAppNameCodec(t *testing.T) {
+	codec := CompactAppNameCodec{}
+	info := HolderInfo{ID: -42, Instance: "worker-1", Purpose: "billing/invoices"}
+	name := codec.Encode(info)
+	assert.Equal(t, "pglock/-16/worker-1//billing/invoices", name)
+	decoded, ok := codec.Decode(name)
+	assert.True(t, ok)
+	assert.Equal(t, info, decoded)
+
+	info = HolderInfo{ID: -42, Instance: "worker-1", CorrelationID: "req-1", Purpose: "billing/invoices"}
+	name = codec.Encode(info)
+	assert.Equal(t, "pglock/-16/worker-1/req-1/billing/invoices", name)
+	decoded, ok = codec.Decode(name)
+	assert.True(t, ok)
+	assert.Equal(t, info, decoded)
+
+	name = codec.Encode(HolderInfo{ID: 1, Instance: "pod/é", Purpose: strings.Repeat("p", 100)})
+	assert.Len(t, name, maxApplicationName)
+	decoded, ok = codec.Decode(name)
+	assert.True(t, ok)
+	assert.Equal(t, "pod_?", decoded.Instance)
+
+	name = codec.Encode(HolderInfo{ID: 1, Instance: strings.Repeat("i", 100)})
+	assert.Len(t, name, maxApplicationName)
+	decoded, ok = codec.Decode(name)
+	assert.True(t, ok)
+	assert.Equal(t, "", decoded.Purpose)
+
+	// The correlation id is kept over the instance.
+	correlationID := strings.Repeat("c", 50)
+	name = codec.Encode(HolderInfo{ID: 1, Instance: strings.Repeat("i", 100), CorrelationID: correlationID, Purpose: "p"})
+	assert.Len(t, name, maxApplicationName)
+	decoded, ok = codec.Decode(name)
+	assert.True(t, ok)
+	assert.Equal(t, correlationID, decoded.CorrelationID)
+	assert.Equal(t, "", decoded.Purpose)
+
+	for _, name := range []string{"psql", "pglock/zzzzzzzzzzzzzzzzz/a/b/c", "pglock/1/a/b"} {
+		_, ok := codec.Decode(name)
+		assert.False(t, ok, name)
+	}
+}
+
+func TestWithApplicationName(t *testing.T) {
+	db1, err := newDB()
+	assert.Nil(t, err)
+	defer closeDB(db1)
+	db2, err := newDB()
+	assert.Nil(t, err)
+	defer closeDB(db2)
+
+	ctx := context.Background()
+	id := int64(44)
+	lock, err := NewLock(ctx, id, db1, WithApplicationName("worker-1", nil))
+	assert.Nil(t, err)
+	defer lock.Close()
+
+	ok, err := lock.Lock(WithPurpose(ctx, "nightly-report"))
+	assert.True(t, ok)
+	assert.Nil(t, err)
+
+	sessions, err := HolderSessions(ctx, db2, id)
+	assert.Nil(t, err)
+	assert.Len(t, sessions, 1)
+	info, ok := sessions[0].Info(nil)
+	assert.True(t, ok)
+	assert.Equal(t, HolderInfo{ID: id, Instance: "worker-1", Purpose: "nightly-report"}, info)
+	assert.Nil(t, lock.Unlock(ctx))
+
+	name := ""
+	assert.Nil(t, lock.conn.QueryRowContext(ctx, "SELECT current_setting('application_name')").Scan(&name))
+	info, ok = CompactAppNameCodec{}.Decode(name)
+	assert.True(t, ok)
+	assert.Equal(t, HolderInfo{ID: id, Instance: "worker-1"}, info)
+}
@@ -0,0 +1,63 @@
+package pglock
+
+import (
+	"context"
+	"time"
+)
+
+// AttemptEvent reports an acquisition attempt, see WithAttemptHook.
+type AttemptEvent struct {
+	// ID is the advisory lock id.
+	ID int64
+	// Attempt is the number of the attempt, counted from 1. Retries made by Maintain after failures count up until the lock is acquired.
+	Attempt int
+	// Delay is the backoff delay chosen before the attempt, zero for a first attempt.
+	Delay time.Duration
+	// Shared reports whether the attempt was for the shared lock.
+	Shared bool
+	// Wait reports whether the attempt waited for the lock, like WaitAndLock, or failed immediately, like Lock.
+	Wait bool
+	// Result describes the acquisition. A failed Lock or a long ServerWait signal contention.
+	Result AcquireResult
+	// Err is the error of the attempt, if any.
+	Err error
+}
+
+// WithAttemptHook registers a function called after every acquisition attempt of the lock, so adaptive systems can use
+// lock contention as a backpressure signal and reduce their own concurrency when it rises.
+// It is called synchronously by the goroutine that made the attempt.
+func WithAttemptHook(fn func(AttemptEvent)) Option {
+	return func(l *Lock) {
+		l.onAttempt = fn
+	}
+}
+
+type attemptKey struct{}
+
+type attemptInfo struct {
+	attempt int
+	delay   time.Duration
+}
+
+// withAttempt returns a copy of ctx carrying the number and the backoff delay of a retried acquisition.
+func withAttempt(ctx context.Context, attempt int, delay time.Duration) context.Context {
+	return context.WithValue(ctx, attemptKey{}, attemptInfo{attempt: attempt, delay: delay})
+}
+
+// attemptOf returns the number and the backoff delay of an acquisition made with ctx, a first attempt by default.
+func attemptOf(ctx context.Context) attemptInfo {
+	info, ok := ctx.Value(attemptKey{}).(attemptInfo)
+	if !ok {
+		info.attempt = 1
+	}
+	return info
+}
+
+// reportAttempt calls the attempt hook for an acquisition made with ctx.
+func (l *Lock) reportAttempt(ctx context.Context, shared, wait bool, result AcquireResult, err error) {
+	if l.onAttempt == nil {
+		return
+	}
+	info := attemptOf(ctx)
+	l.onAttempt(AttemptEvent{ID: l.id, Attempt: info.attempt, Delay: info.delay, Shared: shared, Wait: wait, Result: result, Err: err})
+}
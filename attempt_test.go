@@ -0,0 +1,66 @@
+package pglock
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReportAttempt(t *testing.T) {
+	events := []AttemptEvent{}
+	l := Lock{id: 1}
+	WithAttemptHook(func(event AttemptEvent) { events = append(events, event) })(&l)
+
+	ctx := context.Background()
+	l.reportAttempt(ctx, false, true, AcquireResult{Acquired: true}, nil)
+	l.reportAttempt(withAttempt(ctx, 3, time.Second), true, false, AcquireResult{}, nil)
+	assert.Equal(t, []AttemptEvent{
+		{ID: 1, Attempt: 1, Wait: true, Result: AcquireResult{Acquired: true}},
+		{ID: 1, Attempt: 3, Delay: time.Second, Shared: true},
+	}, events)
+}
+
+func TestWithAttemptHook(t *testing.T) {
+	db1, err := newDB()
+	assert.Nil(t, err)
+	defer closeDB(db1)
+	db2, err := newDB()
+	assert.Nil(t, err)
+	defer closeDB(db2)
+
+	ctx := context.Background()
+	id := int64(50)
+	events := []AttemptEvent{}
+	lock1, err := NewLock(ctx, id, db1, WithAttemptHook(func(event AttemptEvent) { events = append(events, event) }))
+	assert.Nil(t, err)
+	defer lock1.Close()
+	lock2, err := NewLock(ctx, id, db2)
+	assert.Nil(t, err)
+	defer lock2.Close()
+
+	assert.Nil(t, lock2.WaitAndLock(ctx))
+	acquired, err := lock1.Lock(ctx)
+	assert.Nil(t, err)
+	assert.False(t, acquired)
+	assert.Nil(t, lock2.Unlock(ctx))
+	assert.Nil(t, lock1.WaitAndRLock(ctx))
+	assert.Nil(t, lock1.RUnlock(ctx))
+	// A retry counts up in the result as in the event.
+	result, err := lock1.WaitAndLockWithResult(withAttempt(ctx, 3, time.Second))
+	assert.Nil(t, err)
+	assert.Equal(t, 3, result.Attempts)
+	assert.Nil(t, lock1.Unlock(ctx))
+
+	if assert.Len(t, events, 3) {
+		assert.Equal(t, 1, events[0].Attempt)
+		assert.False(t, events[0].Wait)
+		assert.False(t, events[0].Result.Acquired)
+		assert.True(t, events[1].Wait)
+		assert.True(t, events[1].Shared)
+		assert.True(t, events[1].Result.Acquired)
+		assert.Equal(t, 3, events[2].Attempt)
+		assert.Equal(t, 3, events[2].Result.Attempts)
+	}
+}
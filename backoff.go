@@ -0,0 +1,127 @@
+package pglock
+
+import (
+	"math/rand"
+	"time"
+)
+
+// Backoff chooses the delay before retrying after a failure. It is used between the reacquisitions of Maintain,
+// to space out the polls of WatchXactLocks and the probes of RunCanary while they fail, and by CacheGuard callers
+// before they take over the fill of a key whose filler failed. It does not apply to TokenBucketLimiter.Wait,
+// which sleeps for the computed time until the next token.
+type Backoff interface {
+	// NextDelay returns the delay before retry attempt, counted from 1, given the time elapsed since the first failure.
+	NextDelay(attempt int, elapsed time.Duration) time.Duration
+}
+
+// BackoffFunc adapts a function to the Backoff interface.
+type BackoffFunc func(attempt int, elapsed time.Duration) time.Duration
+
+// NextDelay calls f.
+func (f BackoffFunc) NextDelay(attempt int, elapsed time.Duration) time.Duration {
+	return f(attempt, elapsed)
+}
+
+// WithBackoff sets the delays between the retries of the lock and of the helpers given the option, see Backoff.
+// The default is ExponentialBackoff(100*time.Millisecond, 30*time.Second, 0).
+func WithBackoff(b Backoff) Option {
+	return func(l *Lock) {
+		l.backoff = b
+	}
+}
+
+// ConstantBackoff waits d before every retry.
+func ConstantBackoff(d time.Duration) Backoff {
+	return BackoffFunc(func(int, time.Duration) time.Duration {
+		return d
+	})
+}
+
+// ExponentialBackoff doubles the delay from min up to max. jitter, between 0 and 1, randomly shortens each delay by up to
+// that fraction, so that sessions failing together do not retry in lockstep.
+func ExponentialBackoff(min, max time.Duration, jitter float64) Backoff {
+	return BackoffFunc(func(attempt int, _ time.Duration) time.Duration {
+		d := min
+		for i := 1; i < attempt && d < max; i++ {
+			d *= 2
+		}
+		return withJitter(capDelay(d, max), jitter)
+	})
+}
+
+// FibonacciBackoff grows the delay like the Fibonacci sequence, min, min, 2*min, 3*min, 5*min and so on, up to max.
+// It grows slower than ExponentialBackoff.
+func FibonacciBackoff(min, max time.Duration) Backoff {
+	return BackoffFunc(func(attempt int, _ time.Duration) time.Duration {
+		previous, d := time.Duration(0), min
+		for i := 1; i < attempt && d < max; i++ {
+			previous, d = d, previous+d
+		}
+		return capDelay(d, max)
+	})
+}
+
+// capDelay limits d to max.
+func capDelay(d, max time.Duration) time.Duration {
+	if d > max {
+		return max
+	}
+	return d
+}
+
+// maxDuration returns the longer of a and b.
+func maxDuration(a, b time.Duration) time.Duration {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+// withJitter randomly shortens d by up to the jitter fraction.
+func withJitter(d time.Duration, jitter float64) time.Duration {
+	if jitter <= 0 {
+		return d
+	}
+	if jitter > 1 {
+		jitter = 1
+	}
+	return d - time.Duration(rand.Float64()*jitter*float64(d))
+}
+
+// retryDelay returns the delay before retry attempt of the lock.
+func (l *Lock) retryDelay(attempt int, elapsed time.Duration) time.Duration {
+	if l.backoff == nil {
+		return ExponentialBackoff(defaultMaintainMinBackoff, defaultMaintainMaxBackoff, 0).NextDelay(attempt, elapsed)
+	}
+	return l.backoff.NextDelay(attempt, elapsed)
+}
+
+// retrier counts consecutive failures and returns the delays before the next tries, see Backoff.
+type retrier struct {
+	l       *Lock
+	attempt int
+	first   time.Time
+}
+
+// fail records a failure and returns the delay before the next try.
+func (r *retrier) fail() time.Duration {
+	if r.attempt == 0 {
+		r.first = r.l.now()
+	}
+	r.attempt++
+	return r.l.retryDelay(r.attempt, r.l.since(r.first))
+}
+
+// reset records a success.
+func (r *retrier) reset() {
+	r.attempt = 0
+}
+
+// timing returns a Lock configured by opts, for helpers that take their clock and backoff from lock options.
+func timing(opts []Option) *Lock {
+	l := &Lock{state: &lockState{clock: systemClock{}}}
+	for _, opt := range opts {
+		opt(l)
+	}
+	return l
+}
@@ -0,0 +1,47 @@
+package pglock
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConstantBackoff(t *testing.T) {
+	b := ConstantBackoff(time.Second)
+	assert.Equal(t, time.Second, b.NextDelay(1, 0))
+	assert.Equal(t, time.Second, b.NextDelay(10, time.Minute))
+}
+
+func TestExponentialBackoff(t *testing.T) {
+	b := ExponentialBackoff(time.Second, 10*time.Second, 0)
+	delays := []time.Duration{}
+	for attempt := 1; attempt <= 6; attempt++ {
+		delays = append(delays, b.NextDelay(attempt, 0))
+	}
+	assert.Equal(t, []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second, 10 * time.Second, 10 * time.Second}, delays)
+	assert.Equal(t, 10*time.Second, b.NextDelay(1000, 0))
+
+	b = ExponentialBackoff(time.Second, 10*time.Second, 0.5)
+	for attempt := 1; attempt <= 6; attempt++ {
+		d := b.NextDelay(attempt, 0)
+		assert.True(t, d > delays[attempt-1]/2 && d <= delays[attempt-1], "attempt %d: %s", attempt, d)
+	}
+}
+
+func TestFibonacciBackoff(t *testing.T) {
+	b := FibonacciBackoff(time.Second, 10*time.Second)
+	delays := []time.Duration{}
+	for attempt := 1; attempt <= 7; attempt++ {
+		delays = append(delays, b.NextDelay(attempt, 0))
+	}
+	assert.Equal(t, []time.Duration{time.Second, time.Second, 2 * time.Second, 3 * time.Second, 5 * time.Second, 8 * time.Second, 10 * time.Second}, delays)
+}
+
+func TestWithBackoff(t *testing.T) {
+	l := Lock{}
+	WithBackoff(BackoffFunc(func(attempt int, elapsed time.Duration) time.Duration {
+		return time.Duration(attempt)*time.Second + elapsed
+	}))(&l)
+	assert.Equal(t, 3*time.Second+time.Millisecond, l.retryDelay(3, time.Millisecond))
+}
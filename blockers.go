@@ -0,0 +1,85 @@
+package pglock
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// WithBlockerSampling makes WaitAndLock sample pg_blocking_pids for the lock session every interval while it waits,
+// using a separate connection from the pool. The backends seen blocking the acquisition are reported in AcquireResult.Blockers.
+func WithBlockerSampling(interval time.Duration) Option {
+	return func(l *Lock) {
+		l.blockerInterval = interval
+	}
+}
+
+// blockingPIDs returns the backends blocking pid.
+func (l *Lock) blockingPIDs(ctx context.Context, pid int) ([]int, error) {
+	rows, err := l.inspectDB().QueryContext(ctx, "SELECT unnest(pg_blocking_pids($1))", pid)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	pids := []int{}
+	for rows.Next() {
+		var blocker int
+		if err := rows.Scan(&blocker); err != nil {
+			return nil, err
+		}
+		pids = append(pids, blocker)
+	}
+	return pids, rows.Err()
+}
+
+// sampleBlockers samples the backends blocking the lock session until stop is closed.
+// Sampling errors are ignored, the samples are only diagnostics.
+func (l *Lock) sampleBlockers(ctx context.Context, stop <-chan struct{}) func() []int {
+	if l.blockerInterval <= 0 {
+		return func() []int { return nil }
+	}
+	pid, err := l.PID(ctx)
+	if err != nil {
+		return func() []int { return nil }
+	}
+
+	seen := map[int]bool{}
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ticker := l.state.clock.NewTicker(l.blockerInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ctx.Done():
+				return
+			case <-ticker.C():
+				pids, err := l.blockingPIDs(ctx, pid)
+				if err != nil {
+					continue
+				}
+				for _, blocker := range pids {
+					seen[blocker] = true
+				}
+			}
+		}
+	}()
+
+	return func() []int {
+		wg.Wait()
+		if len(seen) == 0 {
+			return nil
+		}
+		blockers := make([]int, 0, len(seen))
+		for blocker := range seen {
+			blockers = append(blockers, blocker)
+		}
+		sort.Ints(blockers)
+		return blockers
+	}
+}
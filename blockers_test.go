@@ -0,0 +1,47 @@
+package pglock
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWaitAndLockBlockers(t *testing.T) {
+	db1, err := newDB()
+	assert.Nil(t, err)
+	defer closeDB(db1)
+	db2, err := newDB()
+	assert.Nil(t, err)
+	defer closeDB(db2)
+
+	ctx := context.Background()
+	id := int64(8)
+	lock1, err := NewLock(ctx, id, db1)
+	assert.Nil(t, err)
+	defer lock1.Close()
+	lock2, err := NewLock(ctx, id, db2, WithBlockerSampling(50*time.Millisecond))
+	assert.Nil(t, err)
+	defer lock2.Close()
+
+	err = lock1.WaitAndLock(ctx)
+	assert.Nil(t, err)
+	pid1, err := lock1.PID(ctx)
+	assert.Nil(t, err)
+
+	go func() {
+		time.Sleep(300 * time.Millisecond)
+		if err := lock1.Unlock(ctx); err != nil {
+			t.Error(err)
+		}
+	}()
+
+	result, err := lock2.WaitAndLockWithResult(ctx)
+	assert.Nil(t, err)
+	assert.True(t, result.Acquired)
+	assert.Equal(t, []int{pid1}, result.Blockers)
+
+	err = lock2.Unlock(ctx)
+	assert.Nil(t, err)
+}
@@ -0,0 +1,112 @@
+package pglock
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+)
+
+// CacheStats are the stampede metrics of a CacheGuard.
+type CacheStats struct {
+	// Hits is the number of Get calls answered by the first lookup.
+	Hits int64 `json:"hits"`
+	// Fills is the number of times fill ran.
+	Fills int64 `json:"fills"`
+	// Waits is the number of times a caller waited for another filler instead of filling, each one a stampede avoided.
+	Waits int64 `json:"waits"`
+	// WaitHits is the number of waits after which the cache had been filled by the other caller.
+	WaitHits int64 `json:"wait_hits"`
+}
+
+// CacheGuard implements the cache miss, lock, recompute, fill and release pattern with one advisory lock per cache
+// key, so concurrent misses of a key across processes fill the cache once while the other callers wait for it.
+type CacheGuard struct {
+	db   *sql.DB
+	ns   Namespace
+	opts []Option
+
+	mu    sync.Mutex
+	stats CacheStats
+}
+
+// NewCacheGuard returns a CacheGuard whose lock ids are derived from namespace and the cache keys.
+// opts configure the lock created for each fill, the sessions are returned to the pool after use.
+func NewCacheGuard(db *sql.DB, namespace string, opts ...Option) *CacheGuard {
+	opts = append([]Option{WithAutoUnlockOnClose(nil)}, opts...)
+	return &CacheGuard{db: db, ns: NS("pglock").NS("cache").NS(namespace), opts: opts}
+}
+
+// Get calls lookup, which reports whether key is cached, and on a miss makes sure the key is filled once: the
+// caller that obtains the lock of the key looks up again and calls fill, the others wait for it to release the
+// lock and look up again, filling themselves if the filler failed. Before taking over from a failed filler, a caller
+// waits for the delay of the WithBackoff option, so a failing fill is not retried in a tight loop. Get reports whether
+// the cache was hit.
+func (g *CacheGuard) Get(ctx context.Context, key string, lookup func(ctx context.Context) (bool, error), fill func(ctx context.Context) error) (bool, error) {
+	hit, err := lookup(ctx)
+	if hit || err != nil {
+		if hit {
+			g.record(func(s *CacheStats) { s.Hits++ })
+		}
+		return hit, err
+	}
+
+	lock, err := NewLock(ctx, g.ns.Key(key).ID, g.db, g.opts...)
+	if err != nil {
+		return false, err
+	}
+	defer lock.Close()
+
+	failures := retrier{l: &lock}
+	for {
+		ok, err := lock.Lock(ctx)
+		if err != nil {
+			return false, err
+		}
+		if ok {
+			return g.fill(ctx, &lock, lookup, fill)
+		}
+
+		// Another caller is filling, wait for it with a shared lock so all waiters wake up together.
+		g.record(func(s *CacheStats) { s.Waits++ })
+		if err := lock.WaitAndRLock(ctx); err != nil {
+			return false, err
+		}
+		if err := lock.RUnlock(ctx); err != nil {
+			return false, err
+		}
+		hit, err := lookup(ctx)
+		if hit {
+			g.record(func(s *CacheStats) { s.WaitHits++ })
+		}
+		if hit || err != nil {
+			return hit, err
+		}
+		if err := lock.sleep(ctx, failures.fail()); err != nil {
+			return false, err
+		}
+	}
+}
+
+// fill fills the cache while holding the lock of the key, unless a previous filler already did.
+func (g *CacheGuard) fill(ctx context.Context, lock *Lock, lookup func(ctx context.Context) (bool, error), fill func(ctx context.Context) error) (bool, error) {
+	defer func() { _ = lock.Unlock(context.Background()) }()
+	hit, err := lookup(ctx)
+	if hit || err != nil {
+		return hit, err
+	}
+	g.record(func(s *CacheStats) { s.Fills++ })
+	return false, fill(ctx)
+}
+
+// Stats returns the stampede metrics of the guard.
+func (g *CacheGuard) Stats() CacheStats {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.stats
+}
+
+func (g *CacheGuard) record(update func(*CacheStats)) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	update(&g.stats)
+}
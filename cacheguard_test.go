@@ -0,0 +1,53 @@
+package pglock
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCacheGuard(t *testing.T) {
+	db, err := newDB()
+	assert.Nil(t, err)
+	defer closeDB(db)
+
+	ctx := context.Background()
+	guard := NewCacheGuard(db, "pglock-test")
+	var mu sync.Mutex
+	cache := map[string]string{}
+	lookup := func(ctx context.Context) (bool, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		_, ok := cache["report"]
+		return ok, nil
+	}
+	fill := func(ctx context.Context) error {
+		time.Sleep(100 * time.Millisecond)
+		mu.Lock()
+		defer mu.Unlock()
+		cache["report"] = "computed"
+		return nil
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := guard.Get(ctx, "report", lookup, fill)
+			assert.Nil(t, err)
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, "computed", cache["report"])
+	stats := guard.Stats()
+	assert.Equal(t, int64(1), stats.Fills)
+
+	hit, err := guard.Get(ctx, "report", lookup, fill)
+	assert.Nil(t, err)
+	assert.True(t, hit)
+	assert.Equal(t, stats.Hits+1, guard.Stats().Hits)
+}
@@ -0,0 +1,66 @@
+package pglock
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// CanaryResult is the outcome of one canary probe.
+type CanaryResult struct {
+	// Acquire describes the acquisition of the canary lock.
+	Acquire AcquireResult
+	// Total is the time spent on the whole probe: connection, lock, unlock and close.
+	Total time.Duration
+	// Err is the error of the probe, if any.
+	Err error
+}
+
+// RunCanary acquires and releases the canary lock id every interval until ctx is done, reporting each probe to observe.
+// Each probe goes through the whole locking path with a fresh session, so observe sees degradations of the pool, the server or the network before real jobs start timing out.
+// The id should be dedicated to the canary. Each probe is bounded by interval.
+// opts configure the canary lock, WithClock also sets the clock of the interval and of the probe timings.
+// While probes fail, the next ones are spaced by the delays of WithBackoff when they are longer than interval,
+// so the canary does not add load to a struggling server.
+func RunCanary(ctx context.Context, db *sql.DB, id int64, interval time.Duration, observe func(CanaryResult), opts ...Option) {
+	l := timing(opts)
+	failures := retrier{l: l}
+	delay := interval
+	for {
+		if err := l.sleep(ctx, delay); err != nil {
+			return
+		}
+		result := probeCanary(ctx, l, db, id, interval, opts)
+		observe(result)
+		if result.Err != nil {
+			delay = maxDuration(interval, failures.fail())
+			continue
+		}
+		failures.reset()
+		delay = interval
+	}
+}
+
+// probeCanary runs one probe, timed on the clock of l.
+func probeCanary(ctx context.Context, l *Lock, db *sql.DB, id int64, timeout time.Duration, opts []Option) CanaryResult {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	start := l.now()
+	result := CanaryResult{}
+	lock, err := NewLock(ctx, id, db, opts...)
+	if err != nil {
+		result.Err = err
+		result.Total = l.since(start)
+		return result
+	}
+	result.Acquire, result.Err = lock.WaitAndLockWithResult(ctx)
+	if result.Err == nil {
+		result.Err = lock.Unlock(ctx)
+	}
+	if err := lock.Close(); err != nil && result.Err == nil {
+		result.Err = err
+	}
+	result.Total = l.since(start)
+	return result
+}
@@ -0,0 +1,56 @@
+package pglock
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunCanary(t *testing.T) {
+	db, err := newDB()
+	assert.Nil(t, err)
+	defer closeDB(db)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	results := []CanaryResult{}
+	RunCanary(ctx, db, int64(9), 50*time.Millisecond, func(result CanaryResult) {
+		results = append(results, result)
+		if len(results) == 3 {
+			cancel()
+		}
+	})
+
+	assert.Len(t, results, 3)
+	for _, result := range results {
+		assert.Nil(t, result.Err)
+		assert.True(t, result.Acquire.Acquired)
+		assert.True(t, result.Total >= result.Acquire.Total)
+	}
+}
+
+func TestRunCanaryBackoff(t *testing.T) {
+	db, err := sql.Open("postgres", "")
+	assert.Nil(t, err)
+	assert.Nil(t, db.Close())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	attempts := []int{}
+	backoff := BackoffFunc(func(attempt int, _ time.Duration) time.Duration {
+		attempts = append(attempts, attempt)
+		return time.Millisecond
+	})
+	failures := 0
+	RunCanary(ctx, db, int64(9), time.Millisecond, func(result CanaryResult) {
+		assert.NotNil(t, result.Err)
+		failures++
+		if failures == 3 {
+			cancel()
+		}
+	}, WithBackoff(backoff))
+
+	// Each failed probe is followed by the next backoff delay.
+	assert.Equal(t, []int{1, 2, 3}, attempts)
+}
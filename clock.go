@@ -0,0 +1,86 @@
+package pglock
+
+import "time"
+
+// Clock is the source of time used by a Lock for its timers, tickers and timing measurements.
+// The default is the system clock, tests can inject a fake one with WithClock, for example pglocktest.FakeClock.
+// Deadlines of the contexts passed to lock methods are always real time.
+type Clock interface {
+	Now() time.Time
+	NewTimer(d time.Duration) Timer
+	NewTicker(d time.Duration) Ticker
+	AfterFunc(d time.Duration, f func()) Timer
+}
+
+// Timer is the subset of *time.Timer used by the package.
+type Timer interface {
+	// C returns the channel on which the time is delivered, nil for timers created by AfterFunc.
+	C() <-chan time.Time
+	Stop() bool
+}
+
+// Ticker is the subset of *time.Ticker used by the package.
+type Ticker interface {
+	C() <-chan time.Time
+	Stop()
+}
+
+// WithClock sets the clock used by the lock.
+func WithClock(clock Clock) Option {
+	return func(l *Lock) {
+		l.state.clock = clock
+	}
+}
+
+// systemClock is the Clock backed by the time package.
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+func (systemClock) NewTimer(d time.Duration) Timer {
+	return systemTimer{time.NewTimer(d)}
+}
+
+func (systemClock) NewTicker(d time.Duration) Ticker {
+	return systemTicker{time.NewTicker(d)}
+}
+
+func (systemClock) AfterFunc(d time.Duration, f func()) Timer {
+	return systemTimer{time.AfterFunc(d, f)}
+}
+
+type systemTimer struct {
+	t *time.Timer
+}
+
+func (t systemTimer) C() <-chan time.Time {
+	return t.t.C
+}
+
+func (t systemTimer) Stop() bool {
+	return t.t.Stop()
+}
+
+type systemTicker struct {
+	t *time.Ticker
+}
+
+func (t systemTicker) C() <-chan time.Time {
+	return t.t.C
+}
+
+func (t systemTicker) Stop() {
+	t.t.Stop()
+}
+
+// now returns the current time of the lock clock.
+func (l *Lock) now() time.Time {
+	return l.state.clock.Now()
+}
+
+// since returns the time elapsed since t on the lock clock.
+func (l *Lock) since(t time.Time) time.Duration {
+	return l.state.clock.Now().Sub(t)
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPercentile(t *testing.T) {
+	latencies := []time.Duration{}
+	for i := 1; i <= 100; i++ {
+		latencies = append(latencies, time.Duration(i)*time.Millisecond)
+	}
+	assert.Equal(t, 50*time.Millisecond, percentile(latencies, 0.50))
+	assert.Equal(t, 99*time.Millisecond, percentile(latencies, 0.99))
+	assert.Equal(t, 100*time.Millisecond, percentile(latencies, 1))
+	assert.Equal(t, time.Millisecond, percentile(latencies[:1], 0.99))
+}
+
+func TestSleep(t *testing.T) {
+	start := time.Now()
+	sleep(context.Background(), 10*time.Millisecond)
+	assert.True(t, time.Since(start) >= 10*time.Millisecond)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	start = time.Now()
+	sleep(ctx, time.Minute)
+	assert.True(t, time.Since(start) < time.Second)
+}
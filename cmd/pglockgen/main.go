@@ -0,0 +1,198 @@
+// Command pglockgen generates typed lock id constants from a manifest of named locks.
+//
+// Usage:
+//
+//	//go:generate pglockgen -in locks.yaml -out locks_gen.go
+//
+// The manifest is YAML (or JSON):
+//
+//	package: locks
+//	locks:
+//	  - name: InvoiceRun
+//	    key: billing/invoices/run
+//	    description: Nightly invoice generation.
+//	    owner: billing-team
+//	  - name: Migrations
+//	    id: 1
+//	    description: Schema migrations.
+//
+// A lock either has an explicit id or a key, in which case the id is derived with pglock.NS, for example
+// billing/invoices/run is pglock.NS("billing").NS("invoices").Key("run").ID. A "/" inside a segment is written
+// as "\/" and a "\" as "\\", see pglock.ParseKey.
+// Duplicate names and colliding ids are rejected.
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/format"
+	"go/token"
+	"log"
+	"os"
+	"strings"
+	"text/template"
+
+	"github.com/allisson/go-pglock/v3"
+	"gopkg.in/yaml.v3"
+)
+
+type manifest struct {
+	Package string         `yaml:"package"`
+	Locks   []manifestLock `yaml:"locks"`
+}
+
+type manifestLock struct {
+	Name        string `yaml:"name"`
+	Key         string `yaml:"key"`
+	ID          *int64 `yaml:"id"`
+	Description string `yaml:"description"`
+	Owner       string `yaml:"owner"`
+}
+
+type generatedLock struct {
+	Name        string
+	Key         string
+	ID          int64
+	Description string
+	Owner       string
+}
+
+var fileTemplate = template.Must(template.New("file").Parse(`// Code generated by pglockgen. DO NOT EDIT.
+
+package {{.Package}}
+
+import "strconv"
+
+// LockID is an advisory lock id declared in the lock manifest.
+type LockID int64
+
+const (
+{{- range .Locks}}
+	// {{.Name}}{{if .Description}} {{.Description}}{{end}}
+{{- if .Key}}
+	// Key: {{.Key}}.
+{{- end}}
+{{- if .Owner}}
+	// Owner: {{.Owner}}.
+{{- end}}
+	{{.Name}} LockID = {{.ID}}
+{{- end}}
+)
+
+// Names maps every declared lock id to its name.
+var Names = map[LockID]string{
+{{- range .Locks}}
+	{{.Name}}: {{printf "%q" .Name}},
+{{- end}}
+}
+
+// IDNames maps every declared lock id to its name, as the untyped map pglock.Snapshot takes.
+var IDNames = map[int64]string{
+{{- range .Locks}}
+	int64({{.Name}}): {{printf "%q" .Name}},
+{{- end}}
+}
+
+// String returns the name of the lock id.
+func (id LockID) String() string {
+	if name, ok := Names[id]; ok {
+		return name
+	}
+	return "LockID(" + strconv.FormatInt(int64(id), 10) + ")"
+}
+`))
+
+func main() {
+	in := flag.String("in", "locks.yaml", "manifest file")
+	out := flag.String("out", "locks_gen.go", "generated Go file")
+	pkg := flag.String("package", "", "package name, overrides the manifest package")
+	flag.Parse()
+
+	data, err := os.ReadFile(*in)
+	if err != nil {
+		log.Fatal(err)
+	}
+	src, err := generate(data, *pkg)
+	if err != nil {
+		log.Fatalf("%s: %v", *in, err)
+	}
+	if err := os.WriteFile(*out, src, 0o644); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// generate returns the formatted Go source for a manifest.
+func generate(data []byte, pkg string) ([]byte, error) {
+	m := manifest{}
+	if err := yaml.Unmarshal(data, &m); err != nil {
+		return nil, err
+	}
+	if pkg != "" {
+		m.Package = pkg
+	}
+	if !token.IsIdentifier(m.Package) {
+		return nil, fmt.Errorf("invalid package name %q", m.Package)
+	}
+
+	locks := make([]generatedLock, 0, len(m.Locks))
+	names := map[string]bool{}
+	ids := map[int64]string{}
+	for _, lock := range m.Locks {
+		if !token.IsIdentifier(lock.Name) || !token.IsExported(lock.Name) {
+			return nil, fmt.Errorf("lock name %q must be an exported Go identifier", lock.Name)
+		}
+		if names[lock.Name] {
+			return nil, fmt.Errorf("duplicate lock name %q", lock.Name)
+		}
+		names[lock.Name] = true
+
+		id, err := lockID(lock)
+		if err != nil {
+			return nil, fmt.Errorf("lock %s: %w", lock.Name, err)
+		}
+		if other, ok := ids[id]; ok {
+			return nil, fmt.Errorf("locks %s and %s have the same id %d", other, lock.Name, id)
+		}
+		ids[id] = lock.Name
+
+		locks = append(locks, generatedLock{
+			Name:        lock.Name,
+			Key:         lock.Key,
+			ID:          id,
+			Description: oneLine(lock.Description),
+			Owner:       oneLine(lock.Owner),
+		})
+	}
+
+	buf := bytes.Buffer{}
+	if err := fileTemplate.Execute(&buf, struct {
+		Package string
+		Locks   []generatedLock
+	}{m.Package, locks}); err != nil {
+		return nil, err
+	}
+	return format.Source(buf.Bytes())
+}
+
+// lockID returns the explicit id of a lock, or derives it from its key.
+func lockID(lock manifestLock) (int64, error) {
+	switch {
+	case lock.ID != nil && lock.Key != "":
+		return 0, fmt.Errorf("id and key are mutually exclusive")
+	case lock.ID != nil:
+		return *lock.ID, nil
+	case lock.Key == "":
+		return 0, fmt.Errorf("either id or key is required")
+	}
+	key, err := pglock.ParseKey(lock.Key)
+	if err != nil {
+		return 0, err
+	}
+	return key.ID, nil
+}
+
+// oneLine collapses whitespace so text fits in a line comment.
+func oneLine(text string) string {
+	return strings.Join(strings.Fields(text), " ")
+}
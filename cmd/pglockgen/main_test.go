@@ -0,0 +1,121 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/allisson/go-pglock/v3"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGenerate(t *testing.T) {
+	manifest := `
+package: locks
+locks:
+  - name: InvoiceRun
+    key: billing/invoices/run
+    description: Nightly invoice generation.
+    owner: billing-team
+  - name: Migrations
+    id: 1
+    description: Schema migrations.
+`
+	src, err := generate([]byte(manifest), "")
+	assert.Nil(t, err)
+
+	expected := `// Code generated by pglockgen. DO NOT EDIT.
+
+package locks
+
+import "strconv"
+
+// LockID is an advisory lock id declared in the lock manifest.
+type LockID int64
+
+const (
+	// InvoiceRun Nightly invoice generation.
+	// Key: billing/invoices/run.
+	// Owner: billing-team.
+	InvoiceRun LockID = 1967617716387303423
+	// Migrations Schema migrations.
+	Migrations LockID = 1
+)
+
+// Names maps every declared lock id to its name.
+var Names = map[LockID]string{
+	InvoiceRun: "InvoiceRun",
+	Migrations: "Migrations",
+}
+
+// IDNames maps every declared lock id to its name, as the untyped map pglock.Snapshot takes.
+var IDNames = map[int64]string{
+	int64(InvoiceRun): "InvoiceRun",
+	int64(Migrations): "Migrations",
+}
+
+// String returns the name of the lock id.
+func (id LockID) String() string {
+	if name, ok := Names[id]; ok {
+		return name
+	}
+	return "LockID(" + strconv.FormatInt(int64(id), 10) + ")"
+}
+`
+	assert.Equal(t, expected, string(src))
+	assert.Equal(t, int64(1967617716387303423), pglock.NS("billing").NS("invoices").Key("run").ID)
+}
+
+func TestGenerateCompiles(t *testing.T) {
+	src, err := generate([]byte("package: locks\nlocks: [{name: Run, key: a/run}, {name: Walk, id: 2}]"), "")
+	assert.Nil(t, err)
+
+	// The directory is inside the module, so that the generated package builds against this pglock.
+	dir, err := os.MkdirTemp(".", "_locks")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	use := `package locks
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/allisson/go-pglock/v3"
+)
+
+func snapshot(ctx context.Context, db *sql.DB) ([]pglock.KeySnapshot, error) {
+	return pglock.Snapshot(ctx, db, IDNames)
+}
+
+func lock(ctx context.Context, db *sql.DB) (pglock.Lock, error) {
+	return pglock.NewLock(ctx, int64(Run), db)
+}
+`
+	assert.Nil(t, os.WriteFile(filepath.Join(dir, "locks_gen.go"), src, 0o644))
+	assert.Nil(t, os.WriteFile(filepath.Join(dir, "use.go"), []byte(use), 0o644))
+	out, err := exec.Command("go", "vet", "./"+dir).CombinedOutput()
+	assert.Nil(t, err, string(out))
+}
+
+func TestGenerateErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		manifest string
+	}{
+		{"invalid package", "package: 1locks\nlocks: []"},
+		{"unexported name", "package: locks\nlocks: [{name: run, id: 1}]"},
+		{"duplicate name", "package: locks\nlocks: [{name: Run, id: 1}, {name: Run, id: 2}]"},
+		{"duplicate id", "package: locks\nlocks: [{name: Run, id: 1}, {name: Walk, id: 1}]"},
+		{"id and key", "package: locks\nlocks: [{name: Run, id: 1, key: a/b}]"},
+		{"no id nor key", "package: locks\nlocks: [{name: Run}]"},
+		{"key without namespace", "package: locks\nlocks: [{name: Run, key: run}]"},
+		{"key with invalid escape", "package: locks\nlocks: [{name: Run, key: 'a/b\\c'}]"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := generate([]byte(tt.manifest), "")
+			assert.NotNil(t, err)
+		})
+	}
+}
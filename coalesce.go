@@ -0,0 +1,117 @@
+package pglock
+
+import (
+	"context"
+	"sync"
+)
+
+// Coalescer queues the exclusive WaitAndLock calls of a process on the same lock id locally, so that only one of
+// them waits on the server at a time and the others wait behind it without taking part in the server wait queue.
+// Share one Coalescer between locks with WithCoalescer.
+type Coalescer struct {
+	mu     sync.Mutex
+	queues map[int64]*coalesceQueue
+}
+
+// coalesceQueue is the local queue of a lock id.
+type coalesceQueue struct {
+	// slot is full while a call waits on the server or holds the lock.
+	slot chan struct{}
+	// calls is the number of calls in the queue, including the one holding the slot.
+	calls int
+}
+
+// NewCoalescer returns an empty Coalescer.
+func NewCoalescer() *Coalescer {
+	return &Coalescer{queues: make(map[int64]*coalesceQueue)}
+}
+
+// WithCoalescer makes WaitAndLock and WaitAndLockWithResult queue in coalescer behind the other calls of the process
+// on the same lock id, before waiting on the server.
+// The call leaves the queue when the session stops holding the lock exclusively, or when the acquisition fails.
+// Acquisitions by a session that already holds the lock exclusively, shared acquisitions and Lock do not queue.
+func WithCoalescer(coalescer *Coalescer) Option {
+	return func(l *Lock) {
+		l.coalescer = coalescer
+	}
+}
+
+// Waiting returns the number of calls on id queued behind the one waiting on the server or holding the lock.
+func (c *Coalescer) Waiting(id int64) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	q, ok := c.queues[id]
+	if !ok {
+		return 0
+	}
+	return q.calls - len(q.slot)
+}
+
+// enter waits for the slot of id or until ctx is done, and returns the function leaving the queue.
+func (c *Coalescer) enter(ctx context.Context, id int64) (func(), error) {
+	c.mu.Lock()
+	q, ok := c.queues[id]
+	if !ok {
+		q = &coalesceQueue{slot: make(chan struct{}, 1)}
+		c.queues[id] = q
+	}
+	q.calls++
+	c.mu.Unlock()
+
+	select {
+	case q.slot <- struct{}{}:
+		return func() {
+			<-q.slot
+			c.leave(id, q)
+		}, nil
+	case <-ctx.Done():
+		c.leave(id, q)
+		return nil, ctx.Err()
+	}
+}
+
+// leave removes a call from the queue of id, and the queue once it is empty.
+func (c *Coalescer) leave(id int64, q *coalesceQueue) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	q.calls--
+	if q.calls == 0 {
+		delete(c.queues, id)
+	}
+}
+
+// coalesce queues an exclusive acquisition in the Coalescer of the lock, unless the session already has its slot.
+func (l *Lock) coalesce(ctx context.Context, shared bool) error {
+	if l.coalescer == nil || shared {
+		return nil
+	}
+	l.state.mu.Lock()
+	held := l.state.dequeue != nil || l.state.depth > 0
+	l.state.mu.Unlock()
+	if held {
+		return nil
+	}
+
+	dequeue, err := l.coalescer.enter(ctx, l.id)
+	if err != nil {
+		return err
+	}
+	l.state.mu.Lock()
+	defer l.state.mu.Unlock()
+	if l.state.state == Closed || l.state.state == Lost {
+		// No state change will come to leave the queue.
+		dequeue()
+		return nil
+	}
+	l.state.dequeue = dequeue
+	return nil
+}
+
+// leaveQueue leaves the Coalescer queue of the session, once it neither acquires nor holds the lock exclusively.
+// The caller must hold mu.
+func (s *lockState) leaveQueue() {
+	if s.dequeue != nil && s.depth == 0 && s.state != Acquiring {
+		s.dequeue()
+		s.dequeue = nil
+	}
+}
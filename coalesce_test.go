@@ -0,0 +1,92 @@
+package pglock
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCoalescerQueue(t *testing.T) {
+	ctx := context.Background()
+	coalescer := NewCoalescer()
+	l := &Lock{id: 1, coalescer: coalescer, state: &lockState{clock: systemClock{}}}
+	l.state.beginAcquire()
+	assert.Nil(t, l.coalesce(ctx, false))
+	assert.Equal(t, 0, coalescer.Waiting(1))
+
+	// A second call waits behind the first one until it stops holding the lock.
+	timeoutCtx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	_, err := coalescer.enter(timeoutCtx, 1)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	l.state.endAcquire(false, AcquireResult{Acquired: true}, nil)
+	assert.Nil(t, l.coalesce(ctx, false), "a session holding the lock does not queue again")
+	l.state.endRelease(false, true, nil)
+	dequeue, err := coalescer.enter(ctx, 1)
+	assert.Nil(t, err)
+	dequeue()
+	assert.Len(t, coalescer.queues, 0)
+
+	// A failed acquisition leaves the queue, so does a shared one never enter it.
+	l.state.beginAcquire()
+	assert.Nil(t, l.coalesce(ctx, false))
+	l.state.endAcquire(false, AcquireResult{}, context.Canceled)
+	assert.Len(t, coalescer.queues, 0)
+	assert.Nil(t, l.coalesce(ctx, true))
+	assert.Len(t, coalescer.queues, 0)
+}
+
+func TestWithCoalescer(t *testing.T) {
+	db1, err := newDB()
+	assert.Nil(t, err)
+	defer closeDB(db1)
+	db2, err := newDB()
+	assert.Nil(t, err)
+	defer closeDB(db2)
+
+	ctx := context.Background()
+	id := int64(62)
+	holder, err := NewLock(ctx, id, db1)
+	assert.Nil(t, err)
+	defer holder.Close()
+	assert.Nil(t, holder.WaitAndLock(ctx))
+
+	coalescer := NewCoalescer()
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			lock, err := NewLock(ctx, id, db2, WithCoalescer(coalescer))
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			defer lock.Close()
+			if err := lock.WaitAndLock(ctx); err != nil {
+				t.Error(err)
+				return
+			}
+			if err := lock.Unlock(ctx); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+
+	// One session waits on the server, the other calls wait in the process.
+	assert.Eventually(t, func() bool { return coalescer.Waiting(id) == 3 }, 5*time.Second, 10*time.Millisecond)
+	assert.Eventually(t, func() bool {
+		waiters, err := holder.waiters(ctx)
+		return err == nil && waiters == 1
+	}, 5*time.Second, 10*time.Millisecond)
+	waiters, err := holder.waiters(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, waiters)
+
+	assert.Nil(t, holder.Unlock(ctx))
+	wg.Wait()
+	assert.Equal(t, 0, coalescer.Waiting(id))
+}
@@ -0,0 +1,62 @@
+package pglock
+
+import "context"
+
+// Compatibility selects how WithCompatibility adapts a lock to the engine and the connection proxy in front of it.
+type Compatibility int
+
+const (
+	// CompatAuto adapts the lock to the engine detected by CheckServer. On Aurora, which is commonly reached through
+	// Amazon RDS Proxy, the session is pinned like with CompatPinned.
+	CompatAuto Compatibility = iota
+	// CompatPinned always pins the session, for proxies that multiplex client connections over server sessions such
+	// as Amazon RDS Proxy. A session level lock is only safe if every statement of the lock runs on the same backend.
+	CompatPinned
+)
+
+// pinStatement pins the session on proxies that pin a client connection once it runs a SET statement, such as
+// Amazon RDS Proxy. The setting itself has no effect.
+const pinStatement = "SET pglock.pinned = on"
+
+// WithCompatibility makes NewLock check the server like WithServerCheck and adjust the lock to it. Engines that
+// accept but do not enforce advisory locks, CockroachDB and YugabyteDB without yb_enable_advisory_locks, are
+// rejected with an *UnsupportedServerError since their locks would not exclude anything. Features relying on wait
+// events are disabled on servers without them. When mode asks for it the session is pinned and NewLock checks that
+// consecutive statements run on the same backend, failing with an *UnsupportedServerError for "session pinning"
+// otherwise, for example behind a transaction pooler that cannot pin. The check is best effort: a proxy may still
+// move the session later.
+func WithCompatibility(mode Compatibility) Option {
+	return func(l *Lock) {
+		l.serverCheck = true
+		l.compat = &mode
+	}
+}
+
+// needsPinning reports whether NewLock must pin the session.
+func (l *Lock) needsPinning() bool {
+	if l.compat == nil {
+		return false
+	}
+	return *l.compat == CompatPinned || (l.server != nil && l.server.Engine == EngineAurora)
+}
+
+// pinSession pins the session and checks that two consecutive statements run on the same backend.
+func (l *Lock) pinSession(ctx context.Context) error {
+	if _, err := l.conn.ExecContext(ctx, pinStatement); err != nil {
+		return err
+	}
+	var first, second int
+	if err := l.conn.QueryRowContext(ctx, "SELECT pg_backend_pid()").Scan(&first); err != nil {
+		return err
+	}
+	if err := l.conn.QueryRowContext(ctx, "SELECT pg_backend_pid()").Scan(&second); err != nil {
+		return err
+	}
+	if first != second {
+		return &UnsupportedServerError{Engine: l.server.Engine, Version: l.server.Version, Feature: "session pinning"}
+	}
+	l.state.mu.Lock()
+	l.state.pid = first
+	l.state.mu.Unlock()
+	return nil
+}
@@ -0,0 +1,42 @@
+package pglock
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNeedsPinning(t *testing.T) {
+	assert.False(t, (&Lock{server: &ServerInfo{Engine: EngineAurora}}).needsPinning())
+
+	auto, pinned := CompatAuto, CompatPinned
+	assert.False(t, (&Lock{compat: &auto, server: &ServerInfo{Engine: EnginePostgres}}).needsPinning())
+	assert.True(t, (&Lock{compat: &auto, server: &ServerInfo{Engine: EngineAurora}}).needsPinning())
+	assert.True(t, (&Lock{compat: &pinned, server: &ServerInfo{Engine: EnginePostgres}}).needsPinning())
+}
+
+func TestWithCompatibility(t *testing.T) {
+	db, err := newDB()
+	assert.Nil(t, err)
+	defer closeDB(db)
+
+	ctx := context.Background()
+	lock, err := NewLock(ctx, 57, db, WithCompatibility(CompatPinned))
+	assert.Nil(t, err)
+	defer lock.Close()
+	assert.Equal(t, EnginePostgres, lock.server.Engine)
+
+	pinned := ""
+	err = lock.conn.QueryRowContext(ctx, "SELECT current_setting('pglock.pinned')").Scan(&pinned)
+	assert.Nil(t, err)
+	assert.Equal(t, "on", pinned)
+	pid, err := lock.PID(ctx)
+	assert.Nil(t, err)
+	assert.NotZero(t, pid)
+
+	ok, err := lock.Lock(ctx)
+	assert.True(t, ok)
+	assert.Nil(t, err)
+	assert.Nil(t, lock.Unlock(ctx))
+}
@@ -0,0 +1,36 @@
+package pglock
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrNotHeld is returned by RequireHeld when the lock is not held.
+var ErrNotHeld = errors.New("pglock: lock not held")
+
+type lockKey struct {
+	id int64
+}
+
+// WithLock returns a copy of ctx carrying l, so middleware can acquire a lock and code deeper in the call stack can
+// find it with FromContext or check it with RequireHeld without threading it through every function.
+// A ctx can carry several locks, they are looked up by id.
+func WithLock(ctx context.Context, l *Lock) context.Context {
+	return context.WithValue(ctx, lockKey{id: l.id}, l)
+}
+
+// FromContext returns the lock for id carried by ctx, if any.
+func FromContext(ctx context.Context, id int64) (*Lock, bool) {
+	l, ok := ctx.Value(lockKey{id: id}).(*Lock)
+	return l, ok
+}
+
+// RequireHeld returns ErrNotHeld unless ctx carries the lock for id and the lock is held, in exclusive or shared mode.
+// It checks the tracked state of the lock and does not query the server.
+func RequireHeld(ctx context.Context, id int64) error {
+	l, ok := FromContext(ctx, id)
+	if !ok || !l.Status().held() {
+		return ErrNotHeld
+	}
+	return nil
+}
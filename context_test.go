@@ -0,0 +1,33 @@
+package pglock
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWithLock(t *testing.T) {
+	ctx := context.Background()
+	assert.True(t, errors.Is(RequireHeld(ctx, 1), ErrNotHeld))
+
+	l1 := &Lock{id: 1, state: &lockState{clock: systemClock{}}}
+	l2 := &Lock{id: 2, state: &lockState{clock: systemClock{}}}
+	ctx = WithLock(WithLock(ctx, l1), l2)
+
+	found, ok := FromContext(ctx, 1)
+	assert.True(t, ok)
+	assert.Same(t, l1, found)
+	found, ok = FromContext(ctx, 2)
+	assert.True(t, ok)
+	assert.Same(t, l2, found)
+	_, ok = FromContext(ctx, 3)
+	assert.False(t, ok)
+
+	assert.Equal(t, ErrNotHeld, RequireHeld(ctx, 1))
+	l1.state.endAcquire(false, AcquireResult{Acquired: true}, nil)
+	assert.Nil(t, RequireHeld(ctx, 1))
+	l2.state.endAcquire(true, AcquireResult{Acquired: true}, nil)
+	assert.Nil(t, RequireHeld(ctx, 2))
+}
@@ -0,0 +1,22 @@
+package pglock
+
+import "context"
+
+// WithCorrelationID makes the lock read a correlation id, such as a request or trace id, from the ctx of NewLock and
+// of every acquisition with extract.
+// The id is published in the session application_name, see WithPurpose and HolderInfo, so that a lock stuck in
+// pg_stat_activity can be traced back to the request holding it.
+// It is also set on the *AlreadyProcessingError, *TooManyWaitersError and *SessionLimitError returned for the lock.
+func WithCorrelationID(extract func(ctx context.Context) string) Option {
+	return func(l *Lock) {
+		l.correlate = extract
+	}
+}
+
+// correlationID returns the correlation id carried by ctx, empty without WithCorrelationID.
+func (l *Lock) correlationID(ctx context.Context) string {
+	if l.correlate == nil {
+		return ""
+	}
+	return l.correlate(ctx)
+}
@@ -0,0 +1,60 @@
+package pglock
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type requestIDKey struct{}
+
+func requestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+func TestWithCorrelationID(t *testing.T) {
+	db1, err := newDB()
+	assert.Nil(t, err)
+	defer closeDB(db1)
+	db2, err := newDB()
+	assert.Nil(t, err)
+	defer closeDB(db2)
+
+	ctx := context.Background()
+	id := int64(61)
+	lock1, err := NewLock(context.WithValue(ctx, requestIDKey{}, "req-1"), id, db1, WithApplicationName("worker-1", nil), WithCorrelationID(requestID))
+	assert.Nil(t, err)
+	defer lock1.Close()
+	lock2, err := NewLock(ctx, id, db2, WithCorrelationID(requestID))
+	assert.Nil(t, err)
+	defer lock2.Close()
+
+	// The session publishes the id of the request that opened it, then of the one holding the lock.
+	name := ""
+	assert.Nil(t, lock1.conn.QueryRowContext(ctx, "SELECT current_setting('application_name')").Scan(&name))
+	info, ok := CompactAppNameCodec{}.Decode(name)
+	assert.True(t, ok)
+	assert.Equal(t, HolderInfo{ID: id, Instance: "worker-1", CorrelationID: "req-1"}, info)
+	ok, err = lock1.Lock(context.WithValue(ctx, requestIDKey{}, "req-2"))
+	assert.True(t, ok)
+	assert.Nil(t, err)
+	sessions, err := HolderSessions(ctx, db2, id)
+	assert.Nil(t, err)
+	assert.Len(t, sessions, 1)
+	info, ok = sessions[0].Info(nil)
+	assert.True(t, ok)
+	assert.Equal(t, HolderInfo{ID: id, Instance: "worker-1", CorrelationID: "req-2"}, info)
+
+	err = lock2.TryLockOrError(context.WithValue(ctx, requestIDKey{}, "req-3"))
+	var processingErr *AlreadyProcessingError
+	assert.ErrorAs(t, err, &processingErr)
+	assert.Equal(t, "req-3", processingErr.CorrelationID)
+
+	assert.Nil(t, lock1.Unlock(ctx))
+	assert.Nil(t, lock1.conn.QueryRowContext(ctx, "SELECT current_setting('application_name')").Scan(&name))
+	info, ok = CompactAppNameCodec{}.Decode(name)
+	assert.True(t, ok)
+	assert.Equal(t, HolderInfo{ID: id, Instance: "worker-1", CorrelationID: "req-1"}, info)
+}
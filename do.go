@@ -0,0 +1,51 @@
+package pglock
+
+import (
+	"context"
+	"database/sql"
+)
+
+// Hooks run on the lock session around the critical section of DoWithHooks, so their statements are ordered with
+// respect to the lock: nobody else can acquire it between AfterAcquire and the critical section, or between the
+// critical section and BeforeRelease. The connection must not be used to lock or unlock.
+type Hooks struct {
+	// AfterAcquire runs right after the lock is acquired, before the critical section.
+	AfterAcquire func(ctx context.Context, conn *sql.Conn) error
+	// BeforeRelease runs after the critical section succeeded, right before the lock is released,
+	// for example to write a completion marker before anyone else can start.
+	BeforeRelease func(ctx context.Context, conn *sql.Conn) error
+}
+
+// Do obtains the exclusive lock like WaitAndLock, runs fn and releases the lock.
+func (l *Lock) Do(ctx context.Context, fn func(ctx context.Context) error) error {
+	return l.DoWithHooks(ctx, Hooks{}, fn)
+}
+
+// DoWithHooks is like Do, but runs hooks on the lock session around fn. If a hook or fn fails, the following
+// steps are skipped and the lock is released. The lock is released with a background context, since ctx may be
+// done by then, and the first error is returned.
+func (l *Lock) DoWithHooks(ctx context.Context, hooks Hooks, fn func(ctx context.Context) error) error {
+	if err := l.WaitAndLock(ctx); err != nil {
+		return err
+	}
+	err := l.critical(ctx, hooks, fn)
+	if unlockErr := l.Unlock(context.Background()); err == nil {
+		err = unlockErr
+	}
+	return err
+}
+
+func (l *Lock) critical(ctx context.Context, hooks Hooks, fn func(ctx context.Context) error) error {
+	if hooks.AfterAcquire != nil {
+		if err := hooks.AfterAcquire(ctx, l.conn); err != nil {
+			return err
+		}
+	}
+	if err := fn(ctx); err != nil {
+		return err
+	}
+	if hooks.BeforeRelease != nil {
+		return hooks.BeforeRelease(ctx, l.conn)
+	}
+	return nil
+}
@@ -0,0 +1,60 @@
+package pglock
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDoWithHooks(t *testing.T) {
+	db1, err := newDB()
+	assert.Nil(t, err)
+	defer closeDB(db1)
+	db2, err := newDB()
+	assert.Nil(t, err)
+	defer closeDB(db2)
+
+	ctx := context.Background()
+	id := int64(36)
+	lock, err := NewLock(ctx, id, db1)
+	assert.Nil(t, err)
+	defer lock.Close()
+	pid, err := lock.PID(ctx)
+	assert.Nil(t, err)
+
+	steps := []string{}
+	hooks := Hooks{
+		AfterAcquire: func(ctx context.Context, conn *sql.Conn) error {
+			sessionPID := 0
+			err := conn.QueryRowContext(ctx, "SELECT pg_backend_pid()").Scan(&sessionPID)
+			assert.Equal(t, pid, sessionPID)
+			steps = append(steps, "after-acquire")
+			return err
+		},
+		BeforeRelease: func(ctx context.Context, conn *sql.Conn) error {
+			pids, err := Holders(ctx, db2, id)
+			assert.Equal(t, []int{pid}, pids)
+			steps = append(steps, "before-release")
+			return err
+		},
+	}
+	err = lock.DoWithHooks(ctx, hooks, func(ctx context.Context) error {
+		steps = append(steps, "critical")
+		return nil
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"after-acquire", "critical", "before-release"}, steps)
+	assert.Equal(t, Idle, lock.Status())
+
+	failure := errors.New("failure")
+	steps = nil
+	err = lock.DoWithHooks(ctx, hooks, func(ctx context.Context) error { return failure })
+	assert.Equal(t, failure, err)
+	assert.Equal(t, []string{"after-acquire"}, steps)
+	assert.Equal(t, Idle, lock.Status())
+
+	assert.Nil(t, lock.Do(ctx, func(ctx context.Context) error { return nil }))
+}
@@ -0,0 +1,58 @@
+package pglock
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ErrAlreadyProcessing is matched by errors.Is for an *AlreadyProcessingError.
+var ErrAlreadyProcessing = errors.New("pglock: already processing")
+
+// ErrLockLost is reported by Maintain when a held lock stops being held.
+var ErrLockLost = errors.New("pglock: lock lost")
+
+// ErrLockClosed is returned by Maintain when the lock is closed while it runs.
+var ErrLockClosed = errors.New("pglock: lock closed")
+
+// AlreadyProcessingError is returned by TryLockOrError when another session holds the lock.
+type AlreadyProcessingError struct {
+	// ID is the advisory lock id.
+	ID int64
+	// HolderPIDs are the backend PIDs holding the lock, empty if they could not be resolved.
+	HolderPIDs []int
+	// RetryAfter is a hint of when to retry, zero if unknown. See WithRetryAfterHint.
+	RetryAfter time.Duration
+	// CorrelationID is the correlation id of the acquisition, see WithCorrelationID.
+	CorrelationID string
+}
+
+// Error implements the error interface.
+func (e *AlreadyProcessingError) Error() string {
+	msg := fmt.Sprintf("pglock: lock %d is already being processed", e.ID)
+	if len(e.HolderPIDs) > 0 {
+		pids := make([]string, len(e.HolderPIDs))
+		for i, pid := range e.HolderPIDs {
+			pids[i] = fmt.Sprint(pid)
+		}
+		msg += " by pid " + strings.Join(pids, ", ")
+	}
+	if e.RetryAfter > 0 {
+		msg += fmt.Sprintf(", retry after %s", e.RetryAfter)
+	}
+	return msg + correlationSuffix(e.CorrelationID)
+}
+
+// correlationSuffix formats the correlation id of an error message, if there is one.
+func correlationSuffix(correlationID string) string {
+	if correlationID == "" {
+		return ""
+	}
+	return fmt.Sprintf(" (correlation id %s)", correlationID)
+}
+
+// Is reports whether target is ErrAlreadyProcessing.
+func (e *AlreadyProcessingError) Is(target error) bool {
+	return target == ErrAlreadyProcessing
+}
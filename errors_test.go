@@ -0,0 +1,21 @@
+package pglock
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAlreadyProcessingError(t *testing.T) {
+	err := error(&AlreadyProcessingError{ID: 42})
+	assert.True(t, errors.Is(err, ErrAlreadyProcessing))
+	assert.Equal(t, "pglock: lock 42 is already being processed", err.Error())
+
+	err = &AlreadyProcessingError{ID: 42, HolderPIDs: []int{100, 200}, RetryAfter: time.Minute}
+	assert.Equal(t, "pglock: lock 42 is already being processed by pid 100, 200, retry after 1m0s", err.Error())
+
+	err = &AlreadyProcessingError{ID: 42, HolderPIDs: []int{100}, CorrelationID: "req-1"}
+	assert.Equal(t, "pglock: lock 42 is already being processed by pid 100 (correlation id req-1)", err.Error())
+}
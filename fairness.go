@@ -0,0 +1,183 @@
+package pglock
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"sync"
+	"time"
+)
+
+// FairnessBuckets are the upper bounds of the wait time histogram in FairnessStats.WaitBuckets.
+var FairnessBuckets = []time.Duration{
+	time.Millisecond,
+	10 * time.Millisecond,
+	100 * time.Millisecond,
+	time.Second,
+	10 * time.Second,
+	time.Minute,
+}
+
+// FairnessLimit caps the number of lock id and waiter pairs kept by Fairness, so processes locking many distinct
+// ids do not grow without bound. When a new pair is recorded at the limit, the least recently updated pair is
+// evicted. Set it before the locks are used.
+var FairnessLimit = 1000
+
+// FairnessStats are the acquisition outcomes of one waiter for one lock key.
+// Durations are encoded to JSON as strings, for example "1.5ms".
+type FairnessStats struct {
+	// ID is the advisory lock id.
+	ID int64 `json:"id"`
+	// Waiter is the waiter identity given to WithFairnessTracking.
+	Waiter string `json:"waiter"`
+	// Immediate is the number of acquisitions that obtained the lock without waiting.
+	Immediate int64 `json:"immediate"`
+	// Waited is the number of acquisitions that obtained the lock after waiting.
+	Waited int64 `json:"waited"`
+	// Missed is the number of non waiting acquisitions, such as Lock, that found the lock held.
+	Missed int64 `json:"missed"`
+	// Failed is the number of acquisitions that returned an error, including deadlines.
+	Failed int64 `json:"failed"`
+	// WaitBuckets counts the waited acquisitions by wait time: WaitBuckets[i] counts waits up to FairnessBuckets[i],
+	// the last element counts longer waits.
+	WaitBuckets []int64 `json:"wait_buckets"`
+	// TotalWait is the time spent waiting by the waited acquisitions.
+	TotalWait time.Duration `json:"total_wait"`
+	// MaxWait is the longest wait of the waited acquisitions.
+	MaxWait time.Duration `json:"max_wait"`
+}
+
+// MarshalJSON implements json.Marshaler.
+func (s FairnessStats) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		ID          int64   `json:"id"`
+		Waiter      string  `json:"waiter"`
+		Immediate   int64   `json:"immediate"`
+		Waited      int64   `json:"waited"`
+		Missed      int64   `json:"missed"`
+		Failed      int64   `json:"failed"`
+		WaitBuckets []int64 `json:"wait_buckets"`
+		TotalWait   string  `json:"total_wait"`
+		MaxWait     string  `json:"max_wait"`
+	}{s.ID, s.Waiter, s.Immediate, s.Waited, s.Missed, s.Failed, s.WaitBuckets, s.TotalWait.String(), s.MaxWait.String()})
+}
+
+type fairnessKey struct {
+	id     int64
+	waiter string
+}
+
+// fairnessEntry is the recorded stats of a pair, with the sequence number of its last update for eviction.
+type fairnessEntry struct {
+	stats FairnessStats
+	seq   uint64
+}
+
+// Package wide fairness stats, guarded by fairnessMu.
+var (
+	fairnessMu  sync.Mutex
+	fairness    = map[fairnessKey]*fairnessEntry{}
+	fairnessSeq uint64
+)
+
+// WithFairnessTracking records the acquisition outcomes of the lock under the waiter identity, for example the
+// service name, so Fairness can show whether one waiter is starving another. Waiting acquisitions first try the
+// lock without waiting to tell immediate acquisitions from waited ones, so they report two attempts when they wait.
+func WithFairnessTracking(waiter string) Option {
+	return func(l *Lock) {
+		l.trackFairness = true
+		l.fairnessWaiter = waiter
+	}
+}
+
+// Fairness returns the acquisition outcomes recorded by the locks created with WithFairnessTracking in this process,
+// ordered by lock id and waiter.
+func Fairness() []FairnessStats {
+	fairnessMu.Lock()
+	defer fairnessMu.Unlock()
+	stats := make([]FairnessStats, 0, len(fairness))
+	for _, entry := range fairness {
+		copied := entry.stats
+		copied.WaitBuckets = append([]int64(nil), entry.stats.WaitBuckets...)
+		stats = append(stats, copied)
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].ID != stats[j].ID {
+			return stats[i].ID < stats[j].ID
+		}
+		return stats[i].Waiter < stats[j].Waiter
+	})
+	return stats
+}
+
+// ResetFairness discards the recorded acquisition outcomes.
+func ResetFairness() {
+	fairnessMu.Lock()
+	defer fairnessMu.Unlock()
+	fairness = map[fairnessKey]*fairnessEntry{}
+}
+
+// tryFirst reports whether the lock was acquired without waiting, when fairness is tracked.
+func (l *Lock) tryFirst(ctx context.Context, shared bool) (bool, error) {
+	if !l.trackFairness {
+		return false, nil
+	}
+	sqlQuery := "SELECT pg_try_advisory_lock($1)"
+	if shared {
+		sqlQuery = "SELECT pg_try_advisory_lock_shared($1)"
+	}
+	acquired := false
+	err := l.conn.QueryRowContext(ctx, sqlQuery, l.id).Scan(&acquired)
+	return acquired, err
+}
+
+// recordFairness records the outcome of an acquisition, waited tells whether it waited on the server.
+func (l *Lock) recordFairness(waited bool, result AcquireResult, err error) {
+	if !l.trackFairness {
+		return
+	}
+	fairnessMu.Lock()
+	defer fairnessMu.Unlock()
+	key := fairnessKey{id: l.id, waiter: l.fairnessWaiter}
+	entry, ok := fairness[key]
+	if !ok {
+		evictFairness()
+		entry = &fairnessEntry{stats: FairnessStats{ID: l.id, Waiter: key.waiter, WaitBuckets: make([]int64, len(FairnessBuckets)+1)}}
+		fairness[key] = entry
+	}
+	fairnessSeq++
+	entry.seq = fairnessSeq
+	stats := &entry.stats
+	switch {
+	case err != nil:
+		stats.Failed++
+	case !result.Acquired:
+		stats.Missed++
+	case !waited:
+		stats.Immediate++
+	default:
+		stats.Waited++
+		stats.TotalWait += result.ServerWait
+		if result.ServerWait > stats.MaxWait {
+			stats.MaxWait = result.ServerWait
+		}
+		bucket := sort.Search(len(FairnessBuckets), func(i int) bool { return result.ServerWait <= FairnessBuckets[i] })
+		stats.WaitBuckets[bucket]++
+	}
+}
+
+// evictFairness makes room for a new pair under FairnessLimit, evicting the least recently updated pairs.
+// The caller must hold fairnessMu.
+func evictFairness() {
+	for len(fairness) > 0 && len(fairness) >= FairnessLimit {
+		var oldest fairnessKey
+		var oldestSeq uint64
+		first := true
+		for key, entry := range fairness {
+			if first || entry.seq < oldestSeq {
+				oldest, oldestSeq, first = key, entry.seq, false
+			}
+		}
+		delete(fairness, oldest)
+	}
+}
@@ -0,0 +1,107 @@
+package pglock
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecordFairness(t *testing.T) {
+	ResetFairness()
+	defer ResetFairness()
+
+	l := Lock{id: 1, trackFairness: true, fairnessWaiter: "billing"}
+	l.recordFairness(false, AcquireResult{Acquired: true, Attempts: 1}, nil)
+	l.recordFairness(false, AcquireResult{Attempts: 1}, nil)
+	l.recordFairness(false, AcquireResult{Acquired: true, Attempts: 2}, nil)
+	l.recordFairness(true, AcquireResult{Acquired: true, Attempts: 1, ServerWait: 5 * time.Millisecond}, nil)
+	l.recordFairness(true, AcquireResult{Acquired: true, Attempts: 1, ServerWait: 2 * time.Minute}, nil)
+	l.recordFairness(true, AcquireResult{Attempts: 1}, errors.New("timeout"))
+	untracked := Lock{id: 1}
+	untracked.recordFairness(false, AcquireResult{Acquired: true}, nil)
+
+	stats := Fairness()
+	assert.Len(t, stats, 1)
+	assert.Equal(t, FairnessStats{
+		ID:          1,
+		Waiter:      "billing",
+		Immediate:   2,
+		Waited:      2,
+		Missed:      1,
+		Failed:      1,
+		WaitBuckets: []int64{0, 1, 0, 0, 0, 0, 1},
+		TotalWait:   2*time.Minute + 5*time.Millisecond,
+		MaxWait:     2 * time.Minute,
+	}, stats[0])
+
+	data, err := json.Marshal(stats[0])
+	assert.Nil(t, err)
+	assert.JSONEq(t, `{"id":1,"waiter":"billing","immediate":2,"waited":2,"missed":1,"failed":1,
+		"wait_buckets":[0,1,0,0,0,0,1],"total_wait":"2m0.005s","max_wait":"2m0s"}`, string(data))
+}
+
+func TestFairnessLimit(t *testing.T) {
+	ResetFairness()
+	defer ResetFairness()
+	defer func(limit int) { FairnessLimit = limit }(FairnessLimit)
+	FairnessLimit = 2
+
+	record := func(id int64) {
+		l := Lock{id: id, trackFairness: true, fairnessWaiter: "billing"}
+		l.recordFairness(false, AcquireResult{Acquired: true, Attempts: 1}, nil)
+	}
+	record(1)
+	record(2)
+	record(1)
+	// The least recently updated pair makes room for the new one.
+	record(3)
+
+	stats := Fairness()
+	assert.Len(t, stats, 2)
+	assert.Equal(t, int64(1), stats[0].ID)
+	assert.Equal(t, int64(2), stats[0].Immediate)
+	assert.Equal(t, int64(3), stats[1].ID)
+}
+
+func TestWithFairnessTracking(t *testing.T) {
+	ResetFairness()
+	defer ResetFairness()
+
+	db1, err := newDB()
+	assert.Nil(t, err)
+	defer closeDB(db1)
+	db2, err := newDB()
+	assert.Nil(t, err)
+	defer closeDB(db2)
+
+	ctx := context.Background()
+	id := int64(35)
+	lock1, err := NewLock(ctx, id, db1, WithFairnessTracking("a"))
+	assert.Nil(t, err)
+	defer lock1.Close()
+	lock2, err := NewLock(ctx, id, db2, WithFairnessTracking("b"))
+	assert.Nil(t, err)
+	defer lock2.Close()
+
+	assert.Nil(t, lock1.WaitAndLock(ctx))
+	done := make(chan AcquireResult)
+	go func() {
+		result, err := lock2.WaitAndLockWithResult(ctx)
+		assert.Nil(t, err)
+		done <- result
+	}()
+	time.Sleep(50 * time.Millisecond)
+	assert.Nil(t, lock1.Unlock(ctx))
+	// The probe is part of the first attempt.
+	assert.Equal(t, 1, (<-done).Attempts)
+	assert.Nil(t, lock2.Unlock(ctx))
+
+	stats := Fairness()
+	assert.Len(t, stats, 2)
+	assert.Equal(t, int64(1), stats[0].Immediate)
+	assert.Equal(t, int64(1), stats[1].Waited)
+}
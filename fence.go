@@ -0,0 +1,83 @@
+package pglock
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+// ErrStaleToken is matched by errors.Is for a *StaleTokenError.
+var ErrStaleToken = errors.New("pglock: stale fencing token")
+
+// StaleTokenError is returned by Fence.Do when a newer token was issued for the key.
+type StaleTokenError struct {
+	// Key is the fenced key.
+	Key string
+	// Token is the token passed to Do.
+	Token int64
+	// Latest is the latest token issued for the key.
+	Latest int64
+}
+
+// Error implements the error interface.
+func (e *StaleTokenError) Error() string {
+	return fmt.Sprintf("pglock: fencing token %d of %q is stale, latest is %d", e.Token, e.Key, e.Latest)
+}
+
+// Is reports whether target is ErrStaleToken.
+func (e *StaleTokenError) Is(target error) bool {
+	return target == ErrStaleToken
+}
+
+// Fence issues fencing tokens and guards external side effects with them, for storage systems that cannot check
+// tokens themselves. A lock holder takes a token with Next right after acquiring the lock and wraps each write in
+// Do, so a holder that lost the lock without noticing, for example after a long pause, cannot write after a newer
+// holder took its token. Tokens are stored per key in a table.
+type Fence struct {
+	db    *sql.DB
+	table string
+}
+
+// NewFence returns a Fence that stores its tokens in table, see FenceTable.
+func NewFence(ctx context.Context, db *sql.DB, table string) (*Fence, error) {
+	name, err := openTable(ctx, db, FenceTable(table))
+	if err != nil {
+		return nil, err
+	}
+	return &Fence{db: db, table: name}, nil
+}
+
+// Next issues a token for key greater than every token issued before for it.
+// It waits for running Do calls of the key to finish, so their side effects happen before the token is issued.
+func (f *Fence) Next(ctx context.Context, key string) (int64, error) {
+	sqlQuery := fmt.Sprintf(`INSERT INTO %s AS f (key, token) VALUES ($1, 1)
+		ON CONFLICT (key) DO UPDATE SET token = f.token + 1 RETURNING token`, f.table)
+	token := int64(0)
+	err := f.db.QueryRowContext(ctx, sqlQuery, key).Scan(&token)
+	return token, err
+}
+
+// Do runs fn only if token is still the latest token of key, and returns a *StaleTokenError otherwise.
+// The token row is share locked while fn runs, so Next cannot issue a newer token until fn returns.
+func (f *Fence) Do(ctx context.Context, key string, token int64, fn func(ctx context.Context) error) error {
+	tx, err := f.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	latest := int64(0)
+	sqlQuery := fmt.Sprintf("SELECT token FROM %s WHERE key = $1 FOR SHARE", f.table)
+	err = tx.QueryRowContext(ctx, sqlQuery, key).Scan(&latest)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+	if latest != token {
+		return &StaleTokenError{Key: key, Token: token, Latest: latest}
+	}
+	if err := fn(ctx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
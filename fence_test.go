@@ -0,0 +1,52 @@
+package pglock
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStaleTokenError(t *testing.T) {
+	err := error(&StaleTokenError{Key: "invoices", Token: 3, Latest: 4})
+	assert.True(t, errors.Is(err, ErrStaleToken))
+	assert.Equal(t, `pglock: fencing token 3 of "invoices" is stale, latest is 4`, err.Error())
+}
+
+func TestFence(t *testing.T) {
+	db, err := newDB()
+	assert.Nil(t, err)
+	defer closeDB(db)
+
+	ctx := context.Background()
+	assert.Nil(t, Migrate(ctx, db, FenceTable("pglock_test_fence")))
+	fence, err := NewFence(ctx, db, "pglock_test_fence")
+	assert.Nil(t, err)
+	defer func() {
+		_, err := db.ExecContext(ctx, "DROP TABLE pglock_test_fence")
+		assert.Nil(t, err)
+	}()
+
+	old, err := fence.Next(ctx, "invoices")
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), old)
+
+	writes := 0
+	write := func(ctx context.Context) error {
+		writes++
+		return nil
+	}
+	assert.Nil(t, fence.Do(ctx, "invoices", old, write))
+
+	latest, err := fence.Next(ctx, "invoices")
+	assert.Nil(t, err)
+	assert.Equal(t, int64(2), latest)
+	err = fence.Do(ctx, "invoices", old, write)
+	assert.True(t, errors.Is(err, ErrStaleToken))
+	assert.Nil(t, fence.Do(ctx, "invoices", latest, write))
+	assert.Equal(t, 2, writes)
+
+	err = fence.Do(ctx, "unknown", 1, write)
+	assert.Equal(t, &StaleTokenError{Key: "unknown", Token: 1, Latest: 0}, err)
+}
@@ -5,11 +5,11 @@ go 1.17
 require (
 	github.com/lib/pq v1.10.6
 	github.com/stretchr/testify v1.8.0
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
 	github.com/davecgh/go-spew v1.1.1 // indirect
 	github.com/pmezard/go-difflib v1.0.0 // indirect
 	github.com/stretchr/objx v0.4.0 // indirect
-	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
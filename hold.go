@@ -0,0 +1,54 @@
+package pglock
+
+import (
+	"context"
+	"time"
+)
+
+// defaultHoldCheckInterval is how often WaitAndHold checks that the lock session is alive.
+const defaultHoldCheckInterval = time.Second
+
+// WithHoldCheckInterval sets how often the session is checked while a context returned by WaitAndHold is live.
+func WithHoldCheckInterval(interval time.Duration) Option {
+	return func(l *Lock) {
+		l.holdCheckInterval = interval
+	}
+}
+
+// WaitAndHold obtains exclusive session level advisory lock like WaitAndLock, ctx only bounds the wait for the lock.
+// It returns a hold context for the critical section, which is not affected by ctx and is canceled when the lock stops being held:
+// when it is fully unlocked, when the lock is closed, or when the session is lost.
+func (l *Lock) WaitAndHold(ctx context.Context) (context.Context, error) {
+	if err := l.WaitAndLock(ctx); err != nil {
+		return nil, err
+	}
+	holdCtx, cancel := context.WithCancel(context.Background())
+	if !l.state.addHold(cancel) {
+		cancel()
+		return holdCtx, nil
+	}
+	go l.watchSession(holdCtx)
+	return holdCtx, nil
+}
+
+// watchSession checks the lock session until holdCtx is done, so a lost session cancels the hold contexts.
+func (l *Lock) watchSession(holdCtx context.Context) {
+	interval := l.holdCheckInterval
+	if interval <= 0 {
+		interval = defaultHoldCheckInterval
+	}
+	ticker := l.state.clock.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-holdCtx.Done():
+			return
+		case <-ticker.C():
+			// holdCtx is not used for the query, canceling it must not interrupt the session.
+			_, err := l.conn.ExecContext(context.Background(), "SELECT 1")
+			if holdCtx.Err() == nil {
+				l.state.observe(err)
+			}
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package pglock
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWaitAndHold(t *testing.T) {
+	db, err := newDB()
+	assert.Nil(t, err)
+	defer closeDB(db)
+
+	ctx := context.Background()
+	lock, err := NewLock(ctx, int64(11), db)
+	assert.Nil(t, err)
+	defer lock.Close()
+
+	acquireCtx, cancel := context.WithTimeout(ctx, time.Second)
+	holdCtx, err := lock.WaitAndHold(acquireCtx)
+	assert.Nil(t, err)
+	cancel()
+	// The hold context outlives the acquire context.
+	assert.Nil(t, holdCtx.Err())
+
+	err = lock.Unlock(ctx)
+	assert.Nil(t, err)
+	assert.ErrorIs(t, holdCtx.Err(), context.Canceled)
+}
+
+func TestWaitAndHoldSessionLost(t *testing.T) {
+	db1, err := newDB()
+	assert.Nil(t, err)
+	defer closeDB(db1)
+	db2, err := newDB()
+	assert.Nil(t, err)
+	defer closeDB(db2)
+
+	ctx := context.Background()
+	lock, err := NewLock(ctx, int64(12), db1, WithHoldCheckInterval(50*time.Millisecond))
+	assert.Nil(t, err)
+	defer lock.Close()
+
+	holdCtx, err := lock.WaitAndHold(ctx)
+	assert.Nil(t, err)
+	pid, err := lock.PID(ctx)
+	assert.Nil(t, err)
+	_, err = db2.ExecContext(ctx, "SELECT pg_terminate_backend($1)", pid)
+	assert.Nil(t, err)
+
+	select {
+	case <-holdCtx.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("hold context was not canceled after the session was lost")
+	}
+	assert.Equal(t, Lost, lock.Status())
+}
@@ -0,0 +1,106 @@
+package pglock
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// Holders returns the backend PIDs currently holding the session level advisory lock for id.
+// Both exclusive and shared holders are returned, waiters are not.
+func Holders(ctx context.Context, db *sql.DB, id int64) ([]int, error) {
+	// A bigint advisory lock key is stored in pg_locks split into classid (high 32 bits) and objid (low 32 bits), with objsubid = 1.
+	sqlQuery := `SELECT pid FROM pg_locks
+		WHERE locktype = 'advisory' AND granted AND objsubid = 1 AND classid = $1 AND objid = $2
+		AND database = (SELECT oid FROM pg_database WHERE datname = current_database())
+		ORDER BY pid`
+	rows, err := db.QueryContext(ctx, sqlQuery, int64(uint32(id>>32)), int64(uint32(id)))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	pids := []int{}
+	for rows.Next() {
+		var pid int
+		if err := rows.Scan(&pid); err != nil {
+			return nil, err
+		}
+		pids = append(pids, pid)
+	}
+	return pids, rows.Err()
+}
+
+// Readers returns how many sessions currently hold the session level advisory lock for id in shared mode,
+// so writers can report how many readers they are waiting behind.
+func Readers(ctx context.Context, db *sql.DB, id int64) (int, error) {
+	sqlQuery := `SELECT count(*) FROM pg_locks
+		WHERE locktype = 'advisory' AND granted AND mode = 'ShareLock' AND objsubid = 1 AND classid = $1 AND objid = $2
+		AND database = (SELECT oid FROM pg_database WHERE datname = current_database())`
+	count := 0
+	err := db.QueryRowContext(ctx, sqlQuery, int64(uint32(id>>32)), int64(uint32(id))).Scan(&count)
+	return count, err
+}
+
+// HolderSessions returns the sessions currently holding the session level advisory lock for id, ordered by PID.
+func HolderSessions(ctx context.Context, db *sql.DB, id int64) ([]SessionSnapshot, error) {
+	sqlQuery := `SELECT l.pid, l.mode = 'ShareLock', coalesce(a.application_name, '')
+		FROM pg_locks l LEFT JOIN pg_stat_activity a ON a.pid = l.pid
+		WHERE l.locktype = 'advisory' AND l.granted AND l.objsubid = 1 AND l.classid = $1 AND l.objid = $2
+		AND l.database = (SELECT oid FROM pg_database WHERE datname = current_database())
+		ORDER BY l.pid`
+	rows, err := db.QueryContext(ctx, sqlQuery, int64(uint32(id>>32)), int64(uint32(id)))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sessions := []SessionSnapshot{}
+	for rows.Next() {
+		session := SessionSnapshot{}
+		if err := rows.Scan(&session.PID, &session.Shared, &session.ApplicationName); err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, session)
+	}
+	return sessions, rows.Err()
+}
+
+// WithHolderSnapshot makes Lock and RLock resolve who holds the lock when they fail to acquire it, and report
+// it in AcquireResult.Holders of LockWithResult and RLockWithResult, so callers can log an actionable message.
+// The holders are resolved with a separate connection from the pool, on a best effort basis.
+func WithHolderSnapshot() Option {
+	return func(l *Lock) {
+		l.holderSnapshot = true
+	}
+}
+
+// WithRetryAfterHint sets the RetryAfter hint reported by TryLockOrError, typically the expected duration of the work guarded by the lock.
+func WithRetryAfterHint(d time.Duration) Option {
+	return func(l *Lock) {
+		l.retryAfter = d
+	}
+}
+
+// TryLockOrError is like Lock, but returns an *AlreadyProcessingError instead of false when the lock is held by another session.
+// The holders are resolved with a separate connection from the pool, on a best effort basis.
+func (l *Lock) TryLockOrError(ctx context.Context) error {
+	ok, err := l.Lock(ctx)
+	if err != nil || ok {
+		return err
+	}
+	pids, _ := Holders(ctx, l.inspectDB(), l.id)
+	return &AlreadyProcessingError{ID: l.id, HolderPIDs: pids, RetryAfter: l.retryAfterHint(ctx), CorrelationID: l.correlationID(ctx)}
+}
+
+// waitersQuery counts the sessions waiting for the session level advisory lock for a bigint key.
+const waitersQuery = `SELECT count(*) FROM pg_locks
+	WHERE locktype = 'advisory' AND NOT granted AND objsubid = 1 AND classid = $1 AND objid = $2
+	AND database = (SELECT oid FROM pg_database WHERE datname = current_database())`
+
+// Waiters returns how many sessions are currently waiting for the session level advisory lock for id.
+func Waiters(ctx context.Context, db *sql.DB, id int64) (int, error) {
+	count := 0
+	err := db.QueryRowContext(ctx, waitersQuery, int64(uint32(id>>32)), int64(uint32(id))).Scan(&count)
+	return count, err
+}
@@ -0,0 +1,115 @@
+package pglock
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTryLockOrError(t *testing.T) {
+	db1, err := newDB()
+	assert.Nil(t, err)
+	defer closeDB(db1)
+	db2, err := newDB()
+	assert.Nil(t, err)
+	defer closeDB(db2)
+
+	ctx := context.Background()
+	id := int64(13)
+	lock1, err := NewLock(ctx, id, db1)
+	assert.Nil(t, err)
+	defer lock1.Close()
+	lock2, err := NewLock(ctx, id, db2, WithRetryAfterHint(time.Minute))
+	assert.Nil(t, err)
+	defer lock2.Close()
+
+	err = lock1.TryLockOrError(ctx)
+	assert.Nil(t, err)
+	pid1, err := lock1.PID(ctx)
+	assert.Nil(t, err)
+
+	pids, err := Holders(ctx, db2, id)
+	assert.Nil(t, err)
+	assert.Equal(t, []int{pid1}, pids)
+
+	err = lock2.TryLockOrError(ctx)
+	assert.True(t, errors.Is(err, ErrAlreadyProcessing))
+	var processingErr *AlreadyProcessingError
+	assert.True(t, errors.As(err, &processingErr))
+	assert.Equal(t, id, processingErr.ID)
+	assert.Equal(t, []int{pid1}, processingErr.HolderPIDs)
+	assert.Equal(t, time.Minute, processingErr.RetryAfter)
+
+	err = lock1.Unlock(ctx)
+	assert.Nil(t, err)
+}
+
+func TestReaders(t *testing.T) {
+	db1, err := newDB()
+	assert.Nil(t, err)
+	defer closeDB(db1)
+	db2, err := newDB()
+	assert.Nil(t, err)
+	defer closeDB(db2)
+
+	ctx := context.Background()
+	id := int64(23)
+	reader1, err := NewLock(ctx, id, db1)
+	assert.Nil(t, err)
+	defer reader1.Close()
+	reader2, err := NewLock(ctx, id, db2)
+	assert.Nil(t, err)
+	defer reader2.Close()
+
+	count, err := Readers(ctx, db1, id)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, count)
+
+	assert.Nil(t, reader1.WaitAndRLock(ctx))
+	assert.Nil(t, reader2.WaitAndRLock(ctx))
+	count, err = Readers(ctx, db1, id)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, count)
+
+	assert.Nil(t, reader1.RUnlock(ctx))
+	assert.Nil(t, reader2.RUnlock(ctx))
+}
+
+func TestWithHolderSnapshot(t *testing.T) {
+	db1, err := newDB()
+	assert.Nil(t, err)
+	defer closeDB(db1)
+	db2, err := newDB()
+	assert.Nil(t, err)
+	defer closeDB(db2)
+
+	ctx := context.Background()
+	id := int64(40)
+	lock1, err := NewLock(ctx, id, db1, WithSessionSetup("SET application_name = 'holder'"))
+	assert.Nil(t, err)
+	defer lock1.Close()
+	lock2, err := NewLock(ctx, id, db2, WithHolderSnapshot())
+	assert.Nil(t, err)
+	defer lock2.Close()
+
+	ok, err := lock1.RLock(ctx)
+	assert.True(t, ok)
+	assert.Nil(t, err)
+	pid1, err := lock1.PID(ctx)
+	assert.Nil(t, err)
+
+	result, err := lock2.LockWithResult(ctx)
+	assert.Nil(t, err)
+	assert.False(t, result.Acquired)
+	assert.Equal(t, []SessionSnapshot{{PID: pid1, Shared: true, ApplicationName: "holder"}}, result.Holders)
+
+	result, err = lock2.RLockWithResult(ctx)
+	assert.Nil(t, err)
+	assert.True(t, result.Acquired)
+	assert.Nil(t, result.Holders)
+	assert.Nil(t, lock2.RUnlock(ctx))
+	assert.Nil(t, lock1.RUnlock(ctx))
+}
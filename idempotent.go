@@ -0,0 +1,92 @@
+package pglock
+
+import "context"
+
+// WaitAndLockIdempotent obtains the exclusive lock like WaitAndLock, for one logical acquisition identified by token.
+// Retrying it with the same token after an ambiguous error, such as a canceled context or a network error, does not
+// stack the lock twice: a token that already acquired the lock returns nil, and a grant the session received without
+// the caller seeing it is detected in pg_locks and adopted instead of acquiring again.
+// Release the acquisition with UnlockIdempotent.
+//
+// A missed grant is only detected while the lock is not otherwise held by the session. pg_locks shows one row per
+// held key whatever the number of stacked grants, and PostgreSQL exposes no grant count, so once the session holds
+// the lock a missed grant cannot be told apart from the known ones: it stays stacked and the session keeps the lock
+// after every known acquisition is released. Use UnlockAll, or Close the lock, to recover from that case.
+func (l *Lock) WaitAndLockIdempotent(ctx context.Context, token string) error {
+	if l.state.hasToken(token) {
+		return nil
+	}
+	adopted, err := l.adoptGrant(ctx)
+	if err != nil {
+		return err
+	}
+	if !adopted {
+		if err := l.WaitAndLock(ctx); err != nil {
+			return err
+		}
+	}
+	l.state.addToken(token)
+	return nil
+}
+
+// UnlockIdempotent releases the acquisition of token, retrying it after it succeeded does nothing.
+func (l *Lock) UnlockIdempotent(ctx context.Context, token string) error {
+	if !l.state.hasToken(token) {
+		return nil
+	}
+	if err := l.unlock(ctx, false); err != nil {
+		return err
+	}
+	l.state.removeToken(token)
+	return nil
+}
+
+// adoptGrant records the exclusive lock as held if the session holds it in pg_locks while no acquisition is known,
+// which happens when the grant raced with an error. It reports whether the grant was adopted.
+// With known acquisitions pg_locks cannot tell whether one more grant is there, see WaitAndLockIdempotent.
+func (l *Lock) adoptGrant(ctx context.Context) (bool, error) {
+	l.state.mu.Lock()
+	depth := l.state.depth
+	l.state.mu.Unlock()
+	if depth > 0 {
+		return false, nil
+	}
+
+	// See Holders for how bigint keys are stored in pg_locks.
+	sqlQuery := `SELECT EXISTS (SELECT 1 FROM pg_locks
+		WHERE locktype = 'advisory' AND granted AND mode = 'ExclusiveLock' AND objsubid = 1 AND classid = $1 AND objid = $2
+		AND database = (SELECT oid FROM pg_database WHERE datname = current_database()) AND pid = pg_backend_pid())`
+	held := false
+	if err := l.conn.QueryRowContext(ctx, sqlQuery, int64(uint32(l.id>>32)), int64(uint32(l.id))).Scan(&held); err != nil {
+		return false, err
+	}
+	if held {
+		l.state.endAcquire(false, AcquireResult{Acquired: true, ConnWait: l.connWait}, nil)
+	}
+	return held, nil
+}
+
+func (s *lockState) hasToken(token string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.tokens[token]
+}
+
+// addToken records token as held, tokens are dropped when the lock stops being held in exclusive mode.
+func (s *lockState) addToken(token string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.state != HeldExclusive {
+		return
+	}
+	if s.tokens == nil {
+		s.tokens = map[string]bool{}
+	}
+	s.tokens[token] = true
+}
+
+func (s *lockState) removeToken(token string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.tokens, token)
+}
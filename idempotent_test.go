@@ -0,0 +1,63 @@
+package pglock
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWaitAndLockIdempotent(t *testing.T) {
+	db1, err := newDB()
+	assert.Nil(t, err)
+	defer closeDB(db1)
+	db2, err := newDB()
+	assert.Nil(t, err)
+	defer closeDB(db2)
+
+	ctx := context.Background()
+	id := int64(37)
+	lock, err := NewLock(ctx, id, db1)
+	assert.Nil(t, err)
+	defer lock.Close()
+
+	assert.Nil(t, lock.WaitAndLockIdempotent(ctx, "job-1"))
+	assert.Nil(t, lock.WaitAndLockIdempotent(ctx, "job-1"))
+	assert.Equal(t, 1, lock.Describe().Depth)
+	assert.Nil(t, lock.WaitAndLockIdempotent(ctx, "job-2"))
+	assert.Equal(t, 2, lock.Describe().Depth)
+
+	assert.Nil(t, lock.UnlockIdempotent(ctx, "job-2"))
+	assert.Nil(t, lock.UnlockIdempotent(ctx, "job-2"))
+	assert.Nil(t, lock.UnlockIdempotent(ctx, "job-1"))
+	assert.Equal(t, Idle, lock.Status())
+	pids, err := Holders(ctx, db2, id)
+	assert.Nil(t, err)
+	assert.Empty(t, pids)
+
+	// A grant the caller did not see, as after a canceled acquisition, is adopted instead of stacked.
+	_, err = lock.conn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", id)
+	assert.Nil(t, err)
+	assert.Nil(t, lock.WaitAndLockIdempotent(ctx, "job-3"))
+	assert.Equal(t, 1, lock.Describe().Depth)
+	assert.Nil(t, lock.UnlockIdempotent(ctx, "job-3"))
+	pids, err = Holders(ctx, db2, id)
+	assert.Nil(t, err)
+	assert.Empty(t, pids)
+
+	// With a known acquisition, a missed grant cannot be detected and stays stacked.
+	assert.Nil(t, lock.WaitAndLockIdempotent(ctx, "job-4"))
+	_, err = lock.conn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", id)
+	assert.Nil(t, err)
+	assert.Nil(t, lock.WaitAndLockIdempotent(ctx, "job-5"))
+	assert.Equal(t, 2, lock.Describe().Depth)
+	assert.Nil(t, lock.UnlockIdempotent(ctx, "job-5"))
+	assert.Nil(t, lock.UnlockIdempotent(ctx, "job-4"))
+	assert.Equal(t, Idle, lock.Status())
+	pids, err = Holders(ctx, db2, id)
+	assert.Nil(t, err)
+	assert.Len(t, pids, 1)
+	held, err := lock.UnlockAll(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, []HeldLock{{ID: id}}, held)
+}
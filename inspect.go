@@ -0,0 +1,21 @@
+package pglock
+
+import "database/sql"
+
+// WithInspectDB makes the introspection queries of the lock use db instead of the pool passed to NewLock.
+// That covers Activity, the holders lookup of TryLockOrError, WithBlockerSampling, WithStarvationDetection and
+// WithServerCheck, so they can run on a separate pool with read-only credentials and never consume the
+// application's connections. The queries only read pg_locks, pg_stat_activity and server settings.
+func WithInspectDB(db *sql.DB) Option {
+	return func(l *Lock) {
+		l.inspect = db
+	}
+}
+
+// inspectDB returns the pool used for introspection queries.
+func (l *Lock) inspectDB() *sql.DB {
+	if l.inspect != nil {
+		return l.inspect
+	}
+	return l.db
+}
@@ -0,0 +1,39 @@
+package pglock
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWithInspectDB(t *testing.T) {
+	db, inspect := &sql.DB{}, &sql.DB{}
+	l := Lock{db: db}
+	assert.Same(t, db, l.inspectDB())
+	WithInspectDB(inspect)(&l)
+	assert.Same(t, inspect, l.inspectDB())
+}
+
+func TestInspectDBActivity(t *testing.T) {
+	db, err := newDB()
+	assert.Nil(t, err)
+	defer closeDB(db)
+	inspect, err := newDB()
+	assert.Nil(t, err)
+	defer closeDB(inspect)
+	db.SetMaxOpenConns(1)
+
+	ctx := context.Background()
+	lock, err := NewLock(ctx, 31, db, WithInspectDB(inspect))
+	assert.Nil(t, err)
+	defer lock.Close()
+
+	// The only connection of db is pinned by the lock session, so Activity must use the inspect pool.
+	_, err = lock.PID(ctx)
+	assert.Nil(t, err)
+	activity, err := lock.Activity(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, "idle", activity.State)
+}
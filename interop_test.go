@@ -0,0 +1,127 @@
+package pglock
+
+import (
+	"context"
+	"encoding/json"
+	"hash/crc32"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type interopFixtures struct {
+	Namespaced []struct {
+		Name string `json:"name"`
+		ID   int64  `json:"id"`
+	} `json:"namespaced"`
+	References []interopReference `json:"references"`
+	Pairs      []struct {
+		Hi int32 `json:"hi"`
+		Lo int32 `json:"lo"`
+		ID int64 `json:"id"`
+	} `json:"pairs"`
+}
+
+// interopReference is a key derived by an advisory lock library of another language.
+type interopReference struct {
+	Library string `json:"library"`
+	Name    string `json:"name"`
+	Form    string `json:"form"`
+	ID      int64  `json:"id"`
+	Hi      int32  `json:"hi"`
+	Lo      int32  `json:"lo"`
+}
+
+// referenceKeys are the key derivations of the reference libraries, see testdata/interop/README.md.
+var referenceKeys = map[string]func(name string) interopReference{
+	"django-pglocks": func(name string) interopReference {
+		return interopReference{Form: "bigint", ID: int64(int32(crc32.ChecksumIEEE([]byte(name))))}
+	},
+	"with_advisory_lock": func(name string) interopReference {
+		return interopReference{Form: "pair", Hi: int32(crc32.ChecksumIEEE([]byte(name)) & 0x7fffffff)}
+	},
+}
+
+func readInteropFixtures(t *testing.T) interopFixtures {
+	data, err := os.ReadFile("testdata/interop/keys.json")
+	assert.Nil(t, err)
+	fixtures := interopFixtures{}
+	assert.Nil(t, json.Unmarshal(data, &fixtures))
+	return fixtures
+}
+
+func TestInteropFixtures(t *testing.T) {
+	fixtures := readInteropFixtures(t)
+	assert.NotEmpty(t, fixtures.Namespaced)
+	assert.NotEmpty(t, fixtures.References)
+
+	for _, fixture := range fixtures.Namespaced {
+		key, err := ParseKey(fixture.Name)
+		assert.Nil(t, err)
+		assert.Equal(t, fixture.Name, key.Name)
+		assert.Equal(t, fixture.ID, key.ID, fixture.Name)
+	}
+	for _, fixture := range fixtures.References {
+		derive, ok := referenceKeys[fixture.Library]
+		if assert.True(t, ok, fixture.Library) {
+			expected := derive(fixture.Name)
+			expected.Library, expected.Name = fixture.Library, fixture.Name
+			assert.Equal(t, expected, fixture)
+		}
+	}
+	for _, fixture := range fixtures.Pairs {
+		assert.Equal(t, fixture.ID, PairKey(fixture.Hi, fixture.Lo))
+		hi, lo := SplitKey(fixture.ID)
+		assert.Equal(t, fixture.Hi, hi)
+		assert.Equal(t, fixture.Lo, lo)
+	}
+}
+
+func TestInteropSemantics(t *testing.T) {
+	db, err := newDB()
+	assert.Nil(t, err)
+	defer closeDB(db)
+
+	script, err := os.ReadFile("testdata/interop/semantics.sql")
+	assert.Nil(t, err)
+	ctx := context.Background()
+	conn, err := db.Conn(ctx)
+	assert.Nil(t, err)
+	defer conn.Close()
+	_, err = conn.ExecContext(ctx, string(script))
+	assert.Nil(t, err)
+}
+
+func TestInteropReferences(t *testing.T) {
+	db, err := newDB()
+	assert.Nil(t, err)
+	defer closeDB(db)
+
+	ctx := context.Background()
+	conn, err := db.Conn(ctx)
+	assert.Nil(t, err)
+	defer conn.Close()
+	for _, fixture := range readInteropFixtures(t).References {
+		// The other library holds the key, a pglock lock on the same id contends with a bigint key only.
+		id := fixture.ID
+		sqlQuery := "SELECT pg_advisory_lock($1)"
+		args := []interface{}{fixture.ID}
+		if fixture.Form == "pair" {
+			id = PairKey(fixture.Hi, fixture.Lo)
+			sqlQuery = "SELECT pg_advisory_lock($1::int, $2::int)"
+			args = []interface{}{fixture.Hi, fixture.Lo}
+		}
+		_, err := conn.ExecContext(ctx, sqlQuery, args...)
+		assert.Nil(t, err)
+
+		lock, err := NewLock(ctx, id, db)
+		assert.Nil(t, err)
+		ok, err := lock.Lock(ctx)
+		assert.Nil(t, err)
+		assert.Equal(t, fixture.Form == "pair", ok, fixture.Library+" "+fixture.Name)
+		assert.Nil(t, lock.Close())
+		_, err = conn.ExecContext(ctx, "SELECT pg_advisory_unlock_all()")
+		assert.Nil(t, err)
+	}
+}
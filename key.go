@@ -0,0 +1,119 @@
+package pglock
+
+import (
+	"fmt"
+	"hash/fnv"
+	"strings"
+)
+
+const (
+	// keySeparator joins namespace segments into a key name.
+	keySeparator = "/"
+	// keyEscape escapes a separator or itself inside a segment.
+	keyEscape = `\`
+)
+
+// segmentEscaper escapes a segment so that NS("a/b") and NS("a").NS("b") do not share a name.
+var segmentEscaper = strings.NewReplacer(keyEscape, keyEscape+keyEscape, keySeparator, keyEscape+keySeparator)
+
+// Namespace builds hierarchical lock keys, for example NS("billing").NS("invoices").Key("42").
+type Namespace struct {
+	path []string
+}
+
+// Key is a lock id derived from a human-readable name.
+type Key struct {
+	// ID is the advisory lock id to use with NewLock.
+	ID int64
+	// Name is the full key name, segments joined with "/".
+	// A "/" or "\" inside a segment is escaped with a "\".
+	Name string
+}
+
+// NS returns a root namespace.
+func NS(name string) Namespace {
+	return Namespace{path: []string{segmentEscaper.Replace(name)}}
+}
+
+// NS returns a child namespace.
+func (n Namespace) NS(name string) Namespace {
+	path := make([]string, len(n.path), len(n.path)+1)
+	copy(path, n.path)
+	return Namespace{path: append(path, segmentEscaper.Replace(name))}
+}
+
+// Name returns the namespace name, segments joined with "/" and escaped like Key.Name.
+func (n Namespace) Name() string {
+	return strings.Join(n.path, keySeparator)
+}
+
+// Key returns the key for name inside the namespace.
+// The id is the 64-bit FNV-1a hash of the full key name, so it is stable across processes, releases and languages.
+func (n Namespace) Key(name string) Key {
+	fullName := n.Name() + keySeparator + segmentEscaper.Replace(name)
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(fullName))
+	return Key{ID: Uint64Key(h.Sum64()), Name: fullName}
+}
+
+// ParseKey returns the key for a full key name as found in Key.Name, for example "billing/invoices/42".
+// The name needs at least a namespace and a name; "\/" and "\\" stand for a "/" and a "\" inside a segment.
+func ParseKey(name string) (Key, error) {
+	var (
+		segments []string
+		segment  strings.Builder
+	)
+	for i := 0; i < len(name); i++ {
+		switch c := name[i : i+1]; c {
+		case keySeparator:
+			segments = append(segments, segment.String())
+			segment.Reset()
+		case keyEscape:
+			i++
+			if i == len(name) || (name[i:i+1] != keySeparator && name[i:i+1] != keyEscape) {
+				return Key{}, fmt.Errorf("pglock: key %q has an invalid escape at offset %d", name, i-1)
+			}
+			segment.WriteString(name[i : i+1])
+		default:
+			segment.WriteString(c)
+		}
+	}
+	segments = append(segments, segment.String())
+	if len(segments) < 2 {
+		return Key{}, fmt.Errorf("pglock: key %q needs at least a namespace and a name", name)
+	}
+	ns := NS(segments[0])
+	for _, segment := range segments[1 : len(segments)-1] {
+		ns = ns.NS(segment)
+	}
+	return ns.Key(segments[len(segments)-1]), nil
+}
+
+// String returns the key name.
+func (k Key) String() string {
+	return k.Name
+}
+
+// PairKey packs two int32 values into a single lock id, hi in the high 32 bits and lo in the low 32 bits.
+// This is the same layout pg_locks uses to show a bigint advisory lock (classid = hi, objid = lo),
+// so PairKey(hi, lo) == hi::bigint << 32 | (lo::bigint & 4294967295) in SQL.
+// Note that pg_advisory_lock(int, int) uses a separate key space and does not conflict with PairKey(hi, lo).
+func PairKey(hi, lo int32) int64 {
+	return int64(hi)<<32 | int64(uint32(lo))
+}
+
+// SplitKey is the inverse of PairKey.
+func SplitKey(id int64) (hi, lo int32) {
+	return int32(id >> 32), int32(uint32(id))
+}
+
+// Uint64Key maps an unsigned 64-bit value, such as a hash, into the signed lock id space.
+// The bits are kept unchanged (two's complement), so values above math.MaxInt64 become negative ids.
+func Uint64Key(u uint64) int64 {
+	return int64(u)
+}
+
+// KeyUint64 is the inverse of Uint64Key.
+func KeyUint64(id int64) uint64 {
+	return uint64(id)
+}
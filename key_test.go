@@ -0,0 +1,98 @@
+package pglock
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNamespaceKey(t *testing.T) {
+	billing := NS("billing")
+	invoices := billing.NS("invoices")
+	payments := billing.NS("payments")
+
+	assert.Equal(t, "billing", billing.Name())
+	assert.Equal(t, "billing/invoices", invoices.Name())
+	assert.Equal(t, "billing/payments", payments.Name())
+
+	key := invoices.Key("42")
+	assert.Equal(t, "billing/invoices/42", key.Name)
+	assert.Equal(t, "billing/invoices/42", key.String())
+	// The id must never change for a given name.
+	assert.Equal(t, int64(668393017125302924), key.ID)
+	assert.Equal(t, key, NS("billing").NS("invoices").Key("42"))
+	assert.NotEqual(t, key.ID, payments.Key("42").ID)
+}
+
+func TestNamespaceKeyEscapesSeparator(t *testing.T) {
+	keys := []Key{
+		NS("a/b").Key("c"),
+		NS("a").NS("b").Key("c"),
+		NS("a").Key("b/c"),
+		NS(`a\`).Key("b/c"),
+		NS(`a\/b`).Key("c"),
+	}
+	assert.Equal(t, `a\/b/c`, keys[0].Name)
+	assert.Equal(t, "a/b/c", keys[1].Name)
+	assert.Equal(t, `a/b\/c`, keys[2].Name)
+	assert.Equal(t, `a\\/b\/c`, keys[3].Name)
+	assert.Equal(t, `a\\\/b/c`, keys[4].Name)
+	ids := make(map[int64]string)
+	for _, key := range keys {
+		if other, ok := ids[key.ID]; ok {
+			t.Errorf("%q and %q have the same id %d", other, key.Name, key.ID)
+		}
+		ids[key.ID] = key.Name
+	}
+}
+
+func TestParseKey(t *testing.T) {
+	for _, key := range []Key{
+		NS("billing").NS("invoices").Key("42"),
+		NS("a/b").Key("c"),
+		NS("a").Key("b/c"),
+		NS(`a\`).NS("").Key(`\/`),
+	} {
+		parsed, err := ParseKey(key.Name)
+		assert.Nil(t, err)
+		assert.Equal(t, key, parsed)
+	}
+
+	for _, name := range []string{"", "run", `a\/b`, `a/b\`, `a/b\c`} {
+		_, err := ParseKey(name)
+		assert.NotNil(t, err, name)
+	}
+}
+
+func TestPairKey(t *testing.T) {
+	tests := []struct {
+		hi, lo int32
+		id     int64
+	}{
+		{0, 0, 0},
+		{0, 1, 1},
+		{1, 0, 4294967296},
+		{0, -1, 4294967295},
+		{-1, -1, -1},
+		{-1, 0, -4294967296},
+		{math.MaxInt32, math.MaxInt32, 9223372034707292159},
+		{math.MinInt32, math.MinInt32, -9223372034707292160},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.id, PairKey(tt.hi, tt.lo))
+		hi, lo := SplitKey(tt.id)
+		assert.Equal(t, tt.hi, hi)
+		assert.Equal(t, tt.lo, lo)
+	}
+}
+
+func TestUint64Key(t *testing.T) {
+	assert.Equal(t, int64(0), Uint64Key(0))
+	assert.Equal(t, int64(math.MaxInt64), Uint64Key(math.MaxInt64))
+	assert.Equal(t, int64(math.MinInt64), Uint64Key(math.MaxInt64+1))
+	assert.Equal(t, int64(-1), Uint64Key(math.MaxUint64))
+	for _, u := range []uint64{0, 1, math.MaxInt64, math.MaxInt64 + 1, math.MaxUint64} {
+		assert.Equal(t, u, KeyUint64(Uint64Key(u)))
+	}
+}
@@ -0,0 +1,98 @@
+package pglock
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// ErrSessionLimit is matched by errors.Is for a *SessionLimitError.
+var ErrSessionLimit = errors.New("pglock: session limit reached")
+
+// SessionLimitError is returned by NewLock when the lock uses a SessionLimiter created with NewFailFastSessionLimiter
+// and every slot is in use.
+type SessionLimitError struct {
+	// ID is the advisory lock id.
+	ID int64
+	// Max is the number of sessions the limiter allows.
+	Max int
+	// CorrelationID is the correlation id of the NewLock ctx, see WithCorrelationID.
+	CorrelationID string
+}
+
+// Error implements the error interface.
+func (e *SessionLimitError) Error() string {
+	return fmt.Sprintf("pglock: lock %d cannot open a session, the limit of %d sessions is reached", e.ID, e.Max) + correlationSuffix(e.CorrelationID)
+}
+
+// Is reports whether target is ErrSessionLimit.
+func (e *SessionLimitError) Is(target error) bool {
+	return target == ErrSessionLimit
+}
+
+// SessionLimiter caps the number of lock sessions open at the same time.
+// Share one SessionLimiter between locks with WithSessionLimiter to protect the connection pool used by the rest of the application.
+type SessionLimiter struct {
+	slots    chan struct{}
+	failFast bool
+}
+
+// NewSessionLimiter returns a SessionLimiter allowing up to max open sessions.
+// NewLock waits for a free slot when they are all in use.
+// It panics if max is not positive, since no lock could ever open a session.
+func NewSessionLimiter(max int) *SessionLimiter {
+	return &SessionLimiter{slots: newSlots(max)}
+}
+
+// NewFailFastSessionLimiter returns a SessionLimiter allowing up to max open sessions.
+// NewLock does not wait when they are all in use, it returns a *SessionLimitError, so that batch code taking many
+// locks sheds work instead of queuing behind the cap.
+// It panics if max is not positive.
+func NewFailFastSessionLimiter(max int) *SessionLimiter {
+	return &SessionLimiter{slots: newSlots(max), failFast: true}
+}
+
+// newSlots returns the slots of a SessionLimiter allowing up to max open sessions.
+func newSlots(max int) chan struct{} {
+	if max <= 0 {
+		panic("pglock: non-positive max for a SessionLimiter")
+	}
+	return make(chan struct{}, max)
+}
+
+// WithSessionLimiter makes NewLock take a slot in limiter before taking a connection from the pool.
+// The slot is released by Close, or as soon as the session is lost, so that Maintain can open a replacement.
+func WithSessionLimiter(limiter *SessionLimiter) Option {
+	return func(l *Lock) {
+		l.sessionLimiter = limiter
+	}
+}
+
+// InUse returns the number of sessions currently open through the limiter.
+func (s *SessionLimiter) InUse() int {
+	return len(s.slots)
+}
+
+// acquire takes a free slot for the session of lock id, opened for the request correlationID. It waits for one or
+// until ctx is done, unless the limiter fails fast.
+func (s *SessionLimiter) acquire(ctx context.Context, id int64, correlationID string) error {
+	if s.failFast {
+		select {
+		case s.slots <- struct{}{}:
+			return nil
+		default:
+			return &SessionLimitError{ID: id, Max: cap(s.slots), CorrelationID: correlationID}
+		}
+	}
+	select {
+	case s.slots <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// release frees a slot.
+func (s *SessionLimiter) release() {
+	<-s.slots
+}
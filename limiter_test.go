@@ -0,0 +1,76 @@
+package pglock
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSessionLimiter(t *testing.T) {
+	db, err := newDB()
+	assert.Nil(t, err)
+	defer closeDB(db)
+
+	ctx := context.Background()
+	limiter := NewSessionLimiter(1)
+	lock1, err := NewLock(ctx, int64(17), db, WithSessionLimiter(limiter))
+	assert.Nil(t, err)
+	assert.Equal(t, 1, limiter.InUse())
+
+	timeoutCtx, cancel := context.WithTimeout(ctx, 100*time.Millisecond)
+	defer cancel()
+	_, err = NewLock(timeoutCtx, int64(18), db, WithSessionLimiter(limiter))
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		if err := lock1.Close(); err != nil {
+			t.Error(err)
+		}
+	}()
+	lock2, err := NewLock(ctx, int64(18), db, WithSessionLimiter(limiter))
+	assert.Nil(t, err)
+	assert.Equal(t, 1, limiter.InUse())
+	assert.Nil(t, lock2.Close())
+	assert.Equal(t, 0, limiter.InUse())
+}
+
+func TestSessionLimiterLost(t *testing.T) {
+	limiter := NewSessionLimiter(1)
+	assert.Nil(t, limiter.acquire(context.Background(), 1, ""))
+	state := &lockState{clock: systemClock{}, release: limiter.release}
+
+	// A lost session frees its slot right away, and Close does not free it a second time.
+	state.observe(driver.ErrBadConn)
+	assert.Equal(t, Lost, state.current())
+	assert.Equal(t, 0, limiter.InUse())
+	assert.Nil(t, limiter.acquire(context.Background(), 1, ""))
+	state.close()
+	assert.Equal(t, 1, limiter.InUse())
+}
+
+func TestFailFastSessionLimiter(t *testing.T) {
+	limiter := NewFailFastSessionLimiter(1)
+	assert.Nil(t, limiter.acquire(context.Background(), 1, ""))
+
+	// A full limiter refuses the slot instead of waiting.
+	err := limiter.acquire(context.Background(), 2, "")
+	assert.ErrorIs(t, err, ErrSessionLimit)
+	var limitErr *SessionLimitError
+	assert.True(t, errors.As(err, &limitErr))
+	assert.Equal(t, &SessionLimitError{ID: 2, Max: 1}, limitErr)
+	assert.Equal(t, "pglock: lock 2 cannot open a session, the limit of 1 sessions is reached", err.Error())
+
+	limiter.release()
+	assert.Nil(t, limiter.acquire(context.Background(), 2, ""))
+	assert.Equal(t, 1, limiter.InUse())
+}
+
+func TestNewSessionLimiterPanics(t *testing.T) {
+	assert.Panics(t, func() { NewSessionLimiter(0) })
+	assert.Panics(t, func() { NewFailFastSessionLimiter(-1) })
+}
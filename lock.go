@@ -3,6 +3,21 @@ package pglock
 import (
 	"context"
 	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"time"
+)
+
+const (
+	// lockNotAvailable is the SQLSTATE reported when lock_timeout expires, the one set for the ctx deadline or one set on
+	// the session by the application.
+	lockNotAvailable = "55P03"
+	// queryCanceled is the SQLSTATE reported when the driver cancels a query because its context is done.
+	queryCanceled = "57014"
+	// lockTimeoutMargin is how much earlier than the ctx deadline lock_timeout expires, so the server gives up before the driver cancels the query.
+	lockTimeoutMargin = 50 * time.Millisecond
 )
 
 // Locker is an interface for postgresql advisory locks.
@@ -14,48 +29,511 @@ type Locker interface {
 }
 
 // Lock implements the Locker interface.
+// A Lock is safe for concurrent use: its state is synchronized and database/sql serializes the calls on the session,
+// so a lock acquired in one goroutine can be released or closed from another. Copies of a Lock share the same session and state.
 type Lock struct {
-	id   int64
-	conn *sql.Conn
+	id                  int64
+	db                  *sql.DB
+	inspect             *sql.DB
+	conn                *sql.Conn
+	connWait            time.Duration
+	autoUnlock          bool
+	onHeldAtClose       func(id int64, count int)
+	blockerInterval     time.Duration
+	holdCheckInterval   time.Duration
+	retryAfter          time.Duration
+	releaseHints        *ReleaseHints
+	holderSnapshot      bool
+	reservations        *Reservations
+	reservationOwner    string
+	maintenance         *Maintenance
+	maintenanceNS       string
+	appNameCodec        AppNameCodec
+	instance            string
+	correlate           func(context.Context) string
+	order               *LockOrder
+	orderNamespace      string
+	onSection           func(SectionEvent)
+	maxWaiters          int
+	timeSlice           time.Duration
+	tempTables          []tempTable
+	tempSettings        []tempSetting
+	sessionSetup        []string
+	sessionLimiter      *SessionLimiter
+	coalescer           *Coalescer
+	starvationThreshold time.Duration
+	onStarvation        func(StarvationEvent)
+	serverCheck         bool
+	compat              *Compatibility
+	backoff             Backoff
+	onAttempt           func(AttemptEvent)
+	trackFairness       bool
+	fairnessWaiter      string
+	opts                []Option
+	server              *ServerInfo
+	state               *lockState
+}
+
+// AcquireResult describes how a lock acquisition went.
+// Durations are encoded to JSON as strings, for example "1.5ms".
+type AcquireResult struct {
+	// Acquired reports whether the lock was obtained.
+	Acquired bool `json:"acquired"`
+	// ConnWait is the time NewLock spent obtaining the session connection from the pool (pool checkout),
+	// separately from ServerWait. The wait for a WithSessionLimiter slot is not included.
+	ConnWait time.Duration `json:"conn_wait"`
+	// ServerWait is the time spent waiting on the server for the advisory lock call.
+	ServerWait time.Duration `json:"server_wait"`
+	// Total is the time spent in the acquisition call.
+	Total time.Duration `json:"total"`
+	// Attempts is the number of the attempt, counted from 1 like AttemptEvent.Attempt: retries made by Maintain after
+	// failures count up until the lock is acquired. The no-wait probe of WithFairnessTracking is part of its attempt.
+	Attempts int `json:"attempts"`
+	// Blockers are the backend PIDs seen blocking the acquisition, only sampled with WithBlockerSampling.
+	Blockers []int `json:"blockers,omitempty"`
+	// Holders are the sessions holding the lock when a non waiting acquisition failed, only resolved with WithHolderSnapshot.
+	Holders []SessionSnapshot `json:"holders,omitempty"`
+}
+
+// MarshalJSON implements json.Marshaler.
+func (r AcquireResult) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Acquired   bool              `json:"acquired"`
+		ConnWait   string            `json:"conn_wait"`
+		ServerWait string            `json:"server_wait"`
+		Total      string            `json:"total"`
+		Attempts   int               `json:"attempts"`
+		Blockers   []int             `json:"blockers,omitempty"`
+		Holders    []SessionSnapshot `json:"holders,omitempty"`
+	}{r.Acquired, r.ConnWait.String(), r.ServerWait.String(), r.Total.String(), r.Attempts, r.Blockers, r.Holders})
+}
+
+// Option configures a Lock created by NewLock.
+type Option func(*Lock)
+
+// WithAutoUnlockOnClose makes Close call pg_advisory_unlock_all before closing the connection instead of relying on the server to drop the locks.
+// If onHeld is not nil it is called when locks were still held at close time, with the number of locks released, to make leaks visible.
+func WithAutoUnlockOnClose(onHeld func(id int64, count int)) Option {
+	return func(l *Lock) {
+		l.autoUnlock = true
+		l.onHeldAtClose = onHeld
+	}
+}
+
+// WithSessionSetup runs statements on the lock session right after it is obtained from the pool, for example SET ROLE,
+// SET search_path or idle timeouts, since the session is long lived and often needs to match the application's settings.
+// Statements are run in order, NewLock fails if any of them fails.
+func WithSessionSetup(statements ...string) Option {
+	return func(l *Lock) {
+		l.sessionSetup = append(l.sessionSetup, statements...)
+	}
 }
 
 // Lock obtains exclusive session level advisory lock if available.
 // It’s similar to WaitAndLock, except it will not wait for the lock to become available.
 // It will either obtain the lock and return true, or return false if the lock cannot be acquired immediately.
 func (l *Lock) Lock(ctx context.Context) (bool, error) {
-	result := false
-	sqlQuery := "SELECT pg_try_advisory_lock($1)"
-	err := l.conn.QueryRowContext(ctx, sqlQuery, l.id).Scan(&result)
+	result, err := l.LockWithResult(ctx)
+	return result.Acquired, err
+}
+
+// LockWithResult is like Lock, but returns an AcquireResult with a timing breakdown of the acquisition.
+func (l *Lock) LockWithResult(ctx context.Context) (AcquireResult, error) {
+	return l.lockWithResult(ctx, false)
+}
+
+func (l *Lock) lockWithResult(ctx context.Context, shared bool) (AcquireResult, error) {
+	start := l.now()
+	result := AcquireResult{ConnWait: l.connWait, Attempts: attemptOf(ctx).attempt}
+	first := !l.state.current().held()
+	l.state.beginAcquire()
+	err := l.tryLock(ctx, shared, &result)
+	if err == nil && result.Acquired && first {
+		if err = l.setupTempState(ctx, shared); err != nil {
+			result.Acquired = false
+		}
+	}
+	if err == nil && !result.Acquired && l.holderSnapshot {
+		// The snapshot is only a diagnostic, failing to take it does not fail the acquisition.
+		result.Holders, _ = HolderSessions(ctx, l.inspectDB(), l.id)
+	}
+	result.Total = l.since(start)
+	l.state.endAcquire(shared, result, err)
+	if !result.Acquired {
+		if clearErr := l.clearPurpose(context.Background()); clearErr != nil && err == nil {
+			err = clearErr
+		}
+	}
+	l.recordFairness(false, result, err)
+	if result.Acquired {
+		l.enterOrderScope(ctx)
+	}
+	l.reportAttempt(ctx, shared, false, result, err)
 	return result, err
 }
 
+func (l *Lock) tryLock(ctx context.Context, shared bool, result *AcquireResult) error {
+	if err := l.checkMaintenance(ctx); err != nil {
+		return err
+	}
+	if err := l.checkReservation(ctx); err != nil {
+		return err
+	}
+	if err := l.checkOrder(ctx); err != nil {
+		return err
+	}
+	if err := l.setPurpose(ctx); err != nil {
+		return err
+	}
+
+	serverStart := l.now()
+	sqlQuery := "SELECT pg_try_advisory_lock($1)"
+	if shared {
+		sqlQuery = "SELECT pg_try_advisory_lock_shared($1)"
+	}
+	err := l.conn.QueryRowContext(ctx, sqlQuery, l.id).Scan(&result.Acquired)
+	result.ServerWait = l.since(serverStart)
+	return err
+}
+
 // WaitAndLock obtains exclusive session level advisory lock.
 // If another session already holds a lock on the same resource identifier, this function will wait until the resource becomes available.
 // Multiple lock requests stack, so that if the resource is locked three times it must then be unlocked three times.
 func (l *Lock) WaitAndLock(ctx context.Context) error {
+	_, err := l.WaitAndLockWithResult(ctx)
+	return err
+}
+
+// WaitAndLockWithResult is like WaitAndLock, but returns an AcquireResult with a timing breakdown of the acquisition.
+// If ctx has a deadline, the session lock_timeout is set to slightly less than the remaining time while waiting, so the server stops waiting
+// before the caller gives up. In that case context.DeadlineExceeded is returned, and ctx.Err() is returned if ctx is done first.
+func (l *Lock) WaitAndLockWithResult(ctx context.Context) (AcquireResult, error) {
+	return l.waitAndLockWithResult(ctx, false)
+}
+
+func (l *Lock) waitAndLockWithResult(ctx context.Context, shared bool) (AcquireResult, error) {
+	start := l.now()
+	result := AcquireResult{ConnWait: l.connWait, Attempts: attemptOf(ctx).attempt}
+	first := !l.state.current().held()
+	l.state.beginAcquire()
+	waited, err := l.waitAndLock(ctx, shared, &result)
+	if err == nil && first {
+		err = l.setupTempState(ctx, shared)
+	}
+	result.Total = l.since(start)
+	result.Acquired = err == nil
+	if result.Acquired {
+		lockWaited(result.ServerWait)
+		l.enterOrderScope(ctx)
+	}
+	l.state.endAcquire(shared, result, err)
+	if !result.Acquired {
+		// ctx may be done here, the purpose must be cleared anyway.
+		if clearErr := l.clearPurpose(context.Background()); clearErr != nil && err == nil {
+			err = clearErr
+		}
+	}
+	l.recordFairness(waited, result, err)
+	l.reportAttempt(ctx, shared, true, result, err)
+	return result, err
+}
+
+// waitAndLock waits for the lock, and reports whether the session waited on the server.
+func (l *Lock) waitAndLock(ctx context.Context, shared bool, result *AcquireResult) (bool, error) {
+	if err := l.checkMaintenance(ctx); err != nil {
+		return false, err
+	}
+	if err := l.checkReservation(ctx); err != nil {
+		return false, err
+	}
+	if err := l.checkOrder(ctx); err != nil {
+		return false, err
+	}
+	if err := l.coalesce(ctx, shared); err != nil {
+		return false, err
+	}
+	if err := l.setPurpose(ctx); err != nil {
+		return false, err
+	}
+	if acquired, err := l.tryFirst(ctx, shared); acquired || err != nil {
+		return false, err
+	}
+	if err := l.checkWaiters(ctx); err != nil {
+		return false, err
+	}
+	// The session is busy while waiting, cache its PID for Activity and blocker sampling.
+	if _, err := l.PID(ctx); err != nil {
+		return false, err
+	}
+
+	deadline, hasDeadline := ctx.Deadline()
+	previousTimeout := ""
+	if hasDeadline {
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			return false, context.DeadlineExceeded
+		}
+		// lock_timeout is in milliseconds and 0 disables it, so keep at least 1.
+		timeout := (remaining - lockTimeoutMargin).Milliseconds()
+		if timeout < 1 {
+			timeout = 1
+		}
+		sqlQuery := "SELECT current_setting('lock_timeout'), set_config('lock_timeout', $1, false)"
+		var current string
+		if err := l.conn.QueryRowContext(ctx, sqlQuery, strconv.FormatInt(timeout, 10)).Scan(&previousTimeout, &current); err != nil {
+			return false, err
+		}
+	}
+
+	stop := make(chan struct{})
+	blockers := l.sampleBlockers(ctx, stop)
+	starvation := func() {}
+	if !shared {
+		starvation = l.watchStarvation(ctx, stop)
+	}
+	serverStart := l.now()
 	sqlQuery := "SELECT pg_advisory_lock($1)"
+	if shared {
+		sqlQuery = "SELECT pg_advisory_lock_shared($1)"
+	}
 	_, err := l.conn.ExecContext(ctx, sqlQuery, l.id)
-	return err
+	result.ServerWait = l.since(serverStart)
+	close(stop)
+	result.Blockers = blockers()
+	starvation()
+	switch {
+	case err == nil:
+	case hasDeadline && sqlState(err) == lockNotAvailable:
+		err = context.DeadlineExceeded
+	case sqlState(err) == queryCanceled && ctx.Err() != nil:
+		err = ctx.Err()
+	}
+
+	if hasDeadline {
+		// ctx may be already done here, the previous setting must be restored anyway.
+		sqlQuery := "SELECT set_config('lock_timeout', $1, false)"
+		_, resetErr := l.conn.ExecContext(context.Background(), sqlQuery, previousTimeout)
+		if resetErr != nil && err == nil {
+			err = resetErr
+		} else if isConnError(resetErr) {
+			// The driver may drop the connection after canceling the query, the session is lost with the setting.
+			l.state.observe(resetErr)
+		}
+	}
+	return true, err
 }
 
 // Unlock releases the lock.
+// If the session connection died, ErrReleasedBySessionLoss is returned when the server confirms the lock is gone.
 func (l *Lock) Unlock(ctx context.Context) error {
+	return l.unlock(ctx, false)
+}
+
+// UnlockWithin releases the lock like Unlock, bounding the release by d instead of a caller context, for shutdown
+// paths where the request context is already canceled. If the release does not complete within d the session
+// connection is discarded instead of being returned to the pool, so the server drops every lock it holds, and the
+// Lock is closed. The unlock error is returned either way.
+func (l *Lock) UnlockWithin(d time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), d)
+	defer cancel()
+	err := l.Unlock(ctx)
+	if err != nil && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		l.discard()
+	}
+	return err
+}
+
+// discard closes the session connection and the Lock, making the server release its locks.
+func (l *Lock) discard() {
+	defer l.state.close()
+	_ = l.discardConn()
+}
+
+// discardConn closes the session connection instead of returning it to the pool.
+func (l *Lock) discardConn() error {
+	// Returning driver.ErrBadConn from Raw makes database/sql close the underlying connection and the Conn.
+	err := l.conn.Raw(func(interface{}) error { return driver.ErrBadConn })
+	if errors.Is(err, driver.ErrBadConn) {
+		return nil
+	}
+	return err
+}
+
+func (l *Lock) unlock(ctx context.Context, shared bool) error {
+	released := false
 	sqlQuery := "SELECT pg_advisory_unlock($1)"
-	_, err := l.conn.ExecContext(ctx, sqlQuery, l.id)
+	if shared {
+		sqlQuery = "SELECT pg_advisory_unlock_shared($1)"
+	}
+	err := l.conn.QueryRowContext(ctx, sqlQuery, l.id).Scan(&released)
+	l.state.endRelease(shared, released, err)
+	if err == nil && released {
+		err = l.clearPurpose(ctx)
+	}
+	if err == nil && released {
+		err = l.teardownTempState(ctx)
+	}
+	if isConnError(err) && l.releasedBySessionLoss(ctx) {
+		return ErrReleasedBySessionLoss
+	}
 	return err
 }
 
+// PID returns the backend process ID of the lock session, as reported by pg_backend_pid().
+// The value is cached after the first call.
+func (l *Lock) PID(ctx context.Context) (int, error) {
+	l.state.mu.Lock()
+	pid := l.state.pid
+	l.state.mu.Unlock()
+	if pid != 0 {
+		return pid, nil
+	}
+
+	if err := l.conn.QueryRowContext(ctx, "SELECT pg_backend_pid()").Scan(&pid); err != nil {
+		return 0, err
+	}
+	l.state.mu.Lock()
+	l.state.pid = pid
+	l.state.mu.Unlock()
+	return pid, nil
+}
+
 // Close closes the DB connection, consequently releasing all locks.
+// A session in a known state is returned to the pool, after pg_advisory_unlock_all if the state shows locks still
+// held, so locks cannot outlive the Lock on a pooled connection.
+// A session that is lost, in the middle of an acquisition, or changed by WithSessionSetup, WithApplicationName,
+// WithPurpose, WithCorrelationID, WithTempTable, WithTempSetting or session pinning is discarded instead of being
+// returned to the pool.
+// With WithAutoUnlockOnClose the locks are released explicitly first and the session is reset with DISCARD ALL, undoing
+// WithSessionSetup statements and other session settings, and the connection is returned to the pool if that succeeded.
+// Close waits for calls in progress on the session, cancel the context of a waiting acquisition to abort it first.
+// It may be called from any goroutine, and more than once.
 func (l *Lock) Close() error {
+	defer l.state.close()
+	if l.autoUnlock {
+		if err := l.unlockAll(context.Background()); err != nil {
+			_ = l.discardConn()
+			return err
+		}
+		// Session setup statements, application_name, the purpose and temporary state must not leak to the next user
+		// of the pooled connection.
+		if _, err := l.conn.ExecContext(context.Background(), "DISCARD ALL"); err != nil {
+			_ = l.discardConn()
+			return err
+		}
+		return l.conn.Close()
+	}
+	clean, held := l.sessionAtClose()
+	if !clean {
+		return l.discardConn()
+	}
+	if held {
+		if _, err := l.conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock_all()"); err != nil {
+			_ = l.discardConn()
+			return err
+		}
+	}
 	return l.conn.Close()
 }
 
+// sessionAtClose reports whether the session can go back to the pool as it is, and whether it holds locks to release
+// first.
+func (l *Lock) sessionAtClose() (clean bool, held bool) {
+	if len(l.sessionSetup) > 0 || l.appNameCodec != nil || len(l.tempTables) > 0 || len(l.tempSettings) > 0 || l.needsPinning() {
+		return false, false
+	}
+	l.state.mu.Lock()
+	defer l.state.mu.Unlock()
+	switch {
+	case l.state.state != Idle && !l.state.state.held():
+		return false, false
+	case l.state.purpose || l.state.settings != nil:
+		return false, false
+	}
+	return true, l.state.state.held() || l.state.slots > 0
+}
+
+func (l *Lock) unlockAll(ctx context.Context) error {
+	l.state.mu.Lock()
+	slots := l.state.slots
+	l.state.mu.Unlock()
+	held, err := l.UnlockAll(ctx)
+	if err != nil {
+		return err
+	}
+	// The UpgradableRLock slot comes with the shared lock it guards, it is not a leak of its own.
+	count := len(held)
+	if slots > 0 {
+		slot := upgradeSlot(l.id)
+		for _, lock := range held {
+			if lock.ID == slot && !lock.Pair {
+				count--
+			}
+		}
+	}
+	if count > 0 && l.onHeldAtClose != nil {
+		l.onHeldAtClose(l.id, count)
+	}
+	return nil
+}
+
+// sqlState returns the SQLSTATE code of a driver error, if the driver exposes one.
+func sqlState(err error) string {
+	var stateErr interface{ SQLState() string }
+	if errors.As(err, &stateErr) {
+		return stateErr.SQLState()
+	}
+	return ""
+}
+
 // NewLock returns a Lock with *sql.Conn
-func NewLock(ctx context.Context, id int64, db *sql.DB) (Lock, error) {
+func NewLock(ctx context.Context, id int64, db *sql.DB, opts ...Option) (Lock, error) {
+	l := Lock{id: id, db: db, opts: opts, state: &lockState{clock: systemClock{}}}
+	for _, opt := range opts {
+		opt(&l)
+	}
+	if l.serverCheck {
+		if err := l.checkServer(ctx); err != nil {
+			return Lock{}, err
+		}
+	}
+
 	// Obtain a connection from the DB connection pool and store it and use it for lock and unlock operations
+	if l.sessionLimiter != nil {
+		if err := l.sessionLimiter.acquire(ctx, l.id, l.correlationID(ctx)); err != nil {
+			return Lock{}, err
+		}
+		l.state.release = l.sessionLimiter.release
+	}
+	start := l.now()
 	conn, err := db.Conn(ctx)
 	if err != nil {
+		if l.state.release != nil {
+			l.state.release()
+		}
 		return Lock{}, err
 	}
-	return Lock{id: id, conn: conn}, nil
+	l.conn = conn
+	l.connWait = l.since(start)
+	sessionOpened(l.connWait)
+
+	if l.needsPinning() {
+		if err := l.pinSession(ctx); err != nil {
+			_ = l.Close()
+			return Lock{}, err
+		}
+	}
+	if l.appNameCodec != nil {
+		if err := l.setApplicationName(ctx, ""); err != nil {
+			_ = l.Close()
+			return Lock{}, err
+		}
+	}
+	for _, statement := range l.sessionSetup {
+		if _, err := conn.ExecContext(ctx, statement); err != nil {
+			_ = l.Close()
+			return Lock{}, err
+		}
+	}
+	return l, nil
 }
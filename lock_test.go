@@ -118,3 +118,399 @@ func TestWaitAndLock(t *testing.T) {
 	stop := time.Since(start)
 	assert.True(t, stop.Milliseconds() >= 1000)
 }
+
+func TestCloseWithAutoUnlock(t *testing.T) {
+	db, err := newDB()
+	assert.Nil(t, err)
+	defer closeDB(db)
+
+	ctx := context.Background()
+	id := int64(2)
+	heldCount := 0
+	lock, err := NewLock(ctx, id, db, WithAutoUnlockOnClose(func(id int64, count int) {
+		heldCount = count
+	}))
+	assert.Nil(t, err)
+
+	ok, err := lock.Lock(ctx)
+	assert.True(t, ok)
+	assert.Nil(t, err)
+
+	err = lock.Close()
+	assert.Nil(t, err)
+	assert.Equal(t, 1, heldCount)
+}
+
+func TestLockWithResult(t *testing.T) {
+	db1, err := newDB()
+	assert.Nil(t, err)
+	defer closeDB(db1)
+	db2, err := newDB()
+	assert.Nil(t, err)
+	defer closeDB(db2)
+
+	ctx := context.Background()
+	id := int64(3)
+	lock1, err := NewLock(ctx, id, db1)
+	assert.Nil(t, err)
+	defer lock1.Close()
+	lock2, err := NewLock(ctx, id, db2)
+	assert.Nil(t, err)
+	defer lock2.Close()
+
+	result, err := lock1.WaitAndLockWithResult(ctx)
+	assert.Nil(t, err)
+	assert.True(t, result.Acquired)
+	assert.Equal(t, 1, result.Attempts)
+	assert.True(t, result.ConnWait > 0)
+	assert.True(t, result.Total >= result.ServerWait)
+
+	result, err = lock2.LockWithResult(ctx)
+	assert.Nil(t, err)
+	assert.False(t, result.Acquired)
+
+	err = lock1.Unlock(ctx)
+	assert.Nil(t, err)
+}
+
+func TestWaitAndLockDeadline(t *testing.T) {
+	db1, err := newDB()
+	assert.Nil(t, err)
+	defer closeDB(db1)
+	db2, err := newDB()
+	assert.Nil(t, err)
+	defer closeDB(db2)
+
+	ctx := context.Background()
+	id := int64(4)
+	lock1, err := NewLock(ctx, id, db1)
+	assert.Nil(t, err)
+	defer lock1.Close()
+	lock2, err := NewLock(ctx, id, db2)
+	assert.Nil(t, err)
+	defer lock2.Close()
+
+	err = lock1.WaitAndLock(ctx)
+	assert.Nil(t, err)
+
+	timeoutCtx, cancel := context.WithTimeout(ctx, 200*time.Millisecond)
+	defer cancel()
+	err = lock2.WaitAndLock(timeoutCtx)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	lockTimeout := ""
+	err = lock2.conn.QueryRowContext(ctx, "SHOW lock_timeout").Scan(&lockTimeout)
+	assert.Nil(t, err)
+	assert.Equal(t, "0", lockTimeout)
+
+	err = lock1.Unlock(ctx)
+	assert.Nil(t, err)
+}
+
+func TestWaitAndLockSessionLockTimeout(t *testing.T) {
+	db1, err := newDB()
+	assert.Nil(t, err)
+	defer closeDB(db1)
+	db2, err := newDB()
+	assert.Nil(t, err)
+	defer closeDB(db2)
+
+	ctx := context.Background()
+	id := int64(60)
+	lock1, err := NewLock(ctx, id, db1)
+	assert.Nil(t, err)
+	defer lock1.Close()
+	lock2, err := NewLock(ctx, id, db2, WithSessionSetup("SET lock_timeout = 100"))
+	assert.Nil(t, err)
+	defer lock2.Close()
+
+	err = lock1.WaitAndLock(ctx)
+	assert.Nil(t, err)
+
+	// Without a ctx deadline the lock_timeout of the session is the application's, its error is returned as is.
+	err = lock2.WaitAndLock(ctx)
+	assert.NotNil(t, err)
+	assert.NotErrorIs(t, err, context.DeadlineExceeded)
+	assert.Equal(t, lockNotAvailable, sqlState(err))
+
+	err = lock1.Unlock(ctx)
+	assert.Nil(t, err)
+}
+
+func TestWaitAndLockCanceled(t *testing.T) {
+	db1, err := newDB()
+	assert.Nil(t, err)
+	defer closeDB(db1)
+	db2, err := newDB()
+	assert.Nil(t, err)
+	defer closeDB(db2)
+
+	ctx := context.Background()
+	id := int64(53)
+	lock1, err := NewLock(ctx, id, db1)
+	assert.Nil(t, err)
+	defer lock1.Close()
+	lock2, err := NewLock(ctx, id, db2)
+	assert.Nil(t, err)
+	defer lock2.Close()
+
+	err = lock1.WaitAndLock(ctx)
+	assert.Nil(t, err)
+
+	// The driver cancels the query with SQLSTATE 57014, the caller gets the context error.
+	cancelCtx, cancel := context.WithCancel(ctx)
+	time.AfterFunc(100*time.Millisecond, cancel)
+	err = lock2.WaitAndLock(cancelCtx)
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.NotEqual(t, HeldExclusive, lock2.Status())
+
+	// A deadline shorter than the margin still sets a positive lock_timeout.
+	timeoutCtx, cancel := context.WithTimeout(ctx, lockTimeoutMargin/2)
+	defer cancel()
+	lock3, err := NewLock(ctx, id, db2)
+	assert.Nil(t, err)
+	defer lock3.Close()
+	err = lock3.WaitAndLock(timeoutCtx)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	err = lock1.Unlock(ctx)
+	assert.Nil(t, err)
+}
+
+func TestStatus(t *testing.T) {
+	db, err := newDB()
+	assert.Nil(t, err)
+	defer closeDB(db)
+
+	ctx := context.Background()
+	id := int64(5)
+	transitions := []State{}
+	lock, err := NewLock(ctx, id, db, WithStateChange(func(from, to State) {
+		transitions = append(transitions, to)
+	}))
+	assert.Nil(t, err)
+	assert.Equal(t, Idle, lock.Status())
+
+	err = lock.WaitAndLock(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, HeldExclusive, lock.Status())
+	status := lock.Describe()
+	assert.Equal(t, id, status.ID)
+	assert.Equal(t, 1, status.Depth)
+	assert.True(t, status.LastAcquire.Acquired)
+
+	// Stacked acquisition must be unlocked twice.
+	ok, err := lock.Lock(ctx)
+	assert.True(t, ok)
+	assert.Nil(t, err)
+	err = lock.Unlock(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, HeldExclusive, lock.Status())
+	err = lock.Unlock(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, Idle, lock.Status())
+
+	err = lock.Close()
+	assert.Nil(t, err)
+	assert.Equal(t, Closed, lock.Status())
+	assert.Equal(t, []State{Acquiring, HeldExclusive, Idle, Closed}, transitions)
+}
+
+func TestPID(t *testing.T) {
+	db, err := newDB()
+	assert.Nil(t, err)
+	defer closeDB(db)
+
+	ctx := context.Background()
+	lock, err := NewLock(ctx, int64(6), db)
+	assert.Nil(t, err)
+	defer lock.Close()
+
+	pid, err := lock.PID(ctx)
+	assert.Nil(t, err)
+	assert.True(t, pid > 0)
+
+	backendPID := 0
+	err = lock.conn.QueryRowContext(ctx, "SELECT pg_backend_pid()").Scan(&backendPID)
+	assert.Nil(t, err)
+	assert.Equal(t, backendPID, pid)
+
+	cached, err := lock.PID(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, pid, cached)
+}
+
+func TestNewLockWithSessionSetup(t *testing.T) {
+	db, err := newDB()
+	assert.Nil(t, err)
+	defer closeDB(db)
+
+	ctx := context.Background()
+	lock, err := NewLock(ctx, int64(14), db, WithSessionSetup("SET statement_timeout = '5s'", "SET application_name = 'pglock-test'"))
+	assert.Nil(t, err)
+	defer lock.Close()
+
+	statementTimeout, applicationName := "", ""
+	err = lock.conn.QueryRowContext(ctx, "SELECT current_setting('statement_timeout'), current_setting('application_name')").Scan(&statementTimeout, &applicationName)
+	assert.Nil(t, err)
+	assert.Equal(t, "5s", statementTimeout)
+	assert.Equal(t, "pglock-test", applicationName)
+
+	_, err = NewLock(ctx, int64(14), db, WithSessionSetup("SET not_a_setting = 1"))
+	assert.NotNil(t, err)
+}
+
+func TestCloseResetsSession(t *testing.T) {
+	db, err := newDB()
+	assert.Nil(t, err)
+	defer closeDB(db)
+	db.SetMaxOpenConns(1)
+
+	ctx := context.Background()
+	var defaultPath string
+	assert.Nil(t, db.QueryRowContext(ctx, "SELECT current_setting('search_path')").Scan(&defaultPath))
+
+	lock, err := NewLock(ctx, int64(14), db, WithAutoUnlockOnClose(nil), WithSessionSetup("SET search_path = pglock_setup"))
+	assert.Nil(t, err)
+	assert.Nil(t, lock.WaitAndLock(WithPurpose(ctx, "setup")))
+	assert.Nil(t, lock.Close())
+
+	// The single pooled connection was reused, without the settings of the lock session.
+	var path, purpose string
+	err = db.QueryRowContext(ctx, "SELECT current_setting('search_path'), coalesce(current_setting('pglock.purpose', true), '')").Scan(&path, &purpose)
+	assert.Nil(t, err)
+	assert.Equal(t, defaultPath, path)
+	assert.Equal(t, "", purpose)
+}
+
+func TestCloseReturnsSession(t *testing.T) {
+	db, err := newDB()
+	assert.Nil(t, err)
+	defer closeDB(db)
+	db.SetMaxOpenConns(1)
+
+	ctx := context.Background()
+	id := int64(59)
+	lock, err := NewLock(ctx, id, db)
+	assert.Nil(t, err)
+	pid, err := lock.PID(ctx)
+	assert.Nil(t, err)
+	assert.Nil(t, lock.WaitAndLock(ctx))
+	assert.Nil(t, lock.Close())
+
+	// The single pooled connection was reused, without the lock it held.
+	var reused int
+	assert.Nil(t, db.QueryRowContext(ctx, "SELECT pg_backend_pid()").Scan(&reused))
+	assert.Equal(t, pid, reused)
+	pids, err := Holders(ctx, db, id)
+	assert.Nil(t, err)
+	assert.Len(t, pids, 0)
+
+	// A session changed by the lock is discarded.
+	lock, err = NewLock(ctx, id, db, WithSessionSetup("SET search_path = pglock_setup"))
+	assert.Nil(t, err)
+	assert.Nil(t, lock.Close())
+	assert.Nil(t, db.QueryRowContext(ctx, "SELECT pg_backend_pid()").Scan(&reused))
+	assert.NotEqual(t, pid, reused)
+}
+
+func TestSessionAtClose(t *testing.T) {
+	tests := []struct {
+		name  string
+		lock  Lock
+		state *lockState
+		clean bool
+		held  bool
+	}{
+		{"idle", Lock{}, &lockState{state: Idle}, true, false},
+		{"held", Lock{}, &lockState{state: HeldShared, shared: 1}, true, true},
+		{"upgrade slot", Lock{}, &lockState{state: Idle, slots: 1}, true, true},
+		{"acquiring", Lock{}, &lockState{state: Acquiring}, false, false},
+		{"lost", Lock{}, &lockState{state: Lost}, false, false},
+		{"closed", Lock{}, &lockState{state: Closed}, false, false},
+		{"purpose", Lock{}, &lockState{state: HeldExclusive, purpose: true}, false, false},
+		{"session setup", Lock{sessionSetup: []string{"SET search_path = a"}}, &lockState{state: Idle}, false, false},
+		{"application name", Lock{appNameCodec: CompactAppNameCodec{}}, &lockState{state: Idle}, false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := tt.lock
+			l.state = tt.state
+			clean, held := l.sessionAtClose()
+			assert.Equal(t, tt.clean, clean)
+			assert.Equal(t, tt.held, held)
+		})
+	}
+}
+
+func TestUnlockWithin(t *testing.T) {
+	db1, err := newDB()
+	assert.Nil(t, err)
+	defer closeDB(db1)
+	db2, err := newDB()
+	assert.Nil(t, err)
+	defer closeDB(db2)
+
+	ctx := context.Background()
+	id := int64(32)
+	lock, err := NewLock(ctx, id, db1)
+	assert.Nil(t, err)
+	defer lock.Close()
+
+	ok, err := lock.Lock(ctx)
+	assert.True(t, ok)
+	assert.Nil(t, err)
+	assert.Nil(t, lock.UnlockWithin(time.Second))
+	assert.Equal(t, Idle, lock.Status())
+
+	// A release that cannot complete in time discards the session, so the server drops the lock anyway.
+	ok, err = lock.Lock(ctx)
+	assert.True(t, ok)
+	assert.Nil(t, err)
+	assert.NotNil(t, lock.UnlockWithin(0))
+	assert.Equal(t, Closed, lock.Status())
+	assert.Eventually(t, func() bool {
+		pids, err := Holders(ctx, db2, id)
+		return err == nil && len(pids) == 0
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestUnlockFromAnotherGoroutine(t *testing.T) {
+	db1, err := newDB()
+	assert.Nil(t, err)
+	defer closeDB(db1)
+	db2, err := newDB()
+	assert.Nil(t, err)
+	defer closeDB(db2)
+
+	ctx := context.Background()
+	id := int64(38)
+	lock, err := NewLock(ctx, id, db1)
+	assert.Nil(t, err)
+
+	assert.Nil(t, lock.WaitAndLock(ctx))
+	done := make(chan error)
+	go func() { done <- lock.Unlock(ctx) }()
+	assert.Nil(t, <-done)
+	assert.Equal(t, Idle, lock.Status())
+
+	// Concurrent Unlock and Close from different goroutines leave the lock closed and released.
+	assert.Nil(t, lock.WaitAndLock(ctx))
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		_ = lock.Unlock(ctx)
+	}()
+	go func() {
+		defer wg.Done()
+		_ = lock.Close()
+	}()
+	wg.Wait()
+	assert.Equal(t, Closed, lock.Status())
+	assert.NotNil(t, lock.Close())
+	assert.Eventually(t, func() bool {
+		pids, err := Holders(ctx, db2, id)
+		return err == nil && len(pids) == 0
+	}, 5*time.Second, 10*time.Millisecond)
+}
@@ -0,0 +1,125 @@
+package pglock
+
+import (
+	"context"
+	"time"
+)
+
+const (
+	// defaultMaintainMinBackoff is the first delay before Maintain retries after a failure.
+	defaultMaintainMinBackoff = 100 * time.Millisecond
+	// defaultMaintainMaxBackoff caps the delay between Maintain retries.
+	defaultMaintainMaxBackoff = 30 * time.Second
+)
+
+// MaintainEvent is a transition reported by Maintain.
+type MaintainEvent struct {
+	// ID is the advisory lock id.
+	ID int64
+	// Held reports whether the lock was acquired (true) or lost (false).
+	Held bool
+	// Ctx is canceled when the lock stops being held, like the context returned by WaitAndHold. Nil when Held is false.
+	Ctx context.Context
+	// Err is the error that made the lock be lost or a retry be needed, nil when Held is true.
+	Err error
+}
+
+// WithMaintainBackoff sets the delays between the retries of Maintain, doubling from min up to max.
+// It is a shorthand for WithBackoff(ExponentialBackoff(min, max, 0)).
+func WithMaintainBackoff(min, max time.Duration) Option {
+	return WithBackoff(ExponentialBackoff(min, max, 0))
+}
+
+// Maintain holds the exclusive lock whenever possible until ctx is done, for services that must hold a lock for
+// as long as they run. It waits for the lock, reports the acquisition to fn and, when the session is lost, reports
+// the loss, opens a new session with the options given to NewLock and waits again, backing off after failures
+// as set with WithBackoff. When ctx is done the lock is released and replacement sessions are closed. Maintain returns ctx.Err().
+// Closing the lock is not a loss: Maintain returns ErrLockClosed without opening a new session. Once the session
+// was replaced, Close is only noticed on the next retry or loss, so cancel ctx to stop Maintain promptly.
+func (l *Lock) Maintain(ctx context.Context, fn func(MaintainEvent)) error {
+	current := l
+	defer func() {
+		if current != l {
+			_ = current.Close()
+		}
+	}()
+
+	attempt := 0
+	var firstFailure time.Time
+	retry := func() {
+		if attempt == 0 {
+			firstFailure = l.now()
+		}
+		attempt++
+	}
+	closed := func() bool {
+		return l.Status() == Closed || current.Status() == Closed
+	}
+	for {
+		delay := time.Duration(0)
+		if attempt > 0 {
+			delay = l.retryDelay(attempt, l.since(firstFailure))
+			if err := l.sleep(ctx, delay); err != nil {
+				return err
+			}
+		}
+
+		if closed() {
+			return ErrLockClosed
+		}
+		if current.Status() == Lost {
+			if current != l {
+				_ = current.Close()
+			}
+			replacement, err := NewLock(ctx, l.id, l.db, l.opts...)
+			if err != nil {
+				if ctx.Err() != nil {
+					return ctx.Err()
+				}
+				fn(MaintainEvent{ID: l.id, Err: err})
+				retry()
+				continue
+			}
+			current = &replacement
+		}
+
+		holdCtx, err := current.WaitAndHold(withAttempt(ctx, attempt+1, delay))
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			if closed() {
+				return ErrLockClosed
+			}
+			fn(MaintainEvent{ID: l.id, Err: err})
+			retry()
+			continue
+		}
+		attempt = 0
+		fn(MaintainEvent{ID: l.id, Held: true, Ctx: holdCtx})
+
+		select {
+		case <-ctx.Done():
+			_ = current.Unlock(context.Background())
+			return ctx.Err()
+		case <-holdCtx.Done():
+		}
+		if closed() {
+			return ErrLockClosed
+		}
+		fn(MaintainEvent{ID: l.id, Err: ErrLockLost})
+		retry()
+	}
+}
+
+// sleep waits for d on the lock clock, or until ctx is done.
+func (l *Lock) sleep(ctx context.Context, d time.Duration) error {
+	timer := l.state.clock.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C():
+		return nil
+	}
+}
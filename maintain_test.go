@@ -0,0 +1,138 @@
+package pglock
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRetryDelay(t *testing.T) {
+	l := Lock{}
+	assert.Equal(t, defaultMaintainMinBackoff, l.retryDelay(1, 0))
+	assert.Equal(t, 2*defaultMaintainMinBackoff, l.retryDelay(2, 0))
+	assert.Equal(t, defaultMaintainMaxBackoff, l.retryDelay(100, 0))
+
+	WithMaintainBackoff(time.Second, 3*time.Second)(&l)
+	assert.Equal(t, time.Second, l.retryDelay(1, 0))
+	assert.Equal(t, 2*time.Second, l.retryDelay(2, 0))
+	assert.Equal(t, 3*time.Second, l.retryDelay(3, 0))
+}
+
+func TestMaintain(t *testing.T) {
+	db1, err := newDB()
+	assert.Nil(t, err)
+	defer closeDB(db1)
+	db2, err := newDB()
+	assert.Nil(t, err)
+	defer closeDB(db2)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	id := int64(34)
+	lock, err := NewLock(ctx, id, db1, WithHoldCheckInterval(10*time.Millisecond), WithMaintainBackoff(10*time.Millisecond, 50*time.Millisecond))
+	assert.Nil(t, err)
+	defer lock.Close()
+
+	events := make(chan MaintainEvent, 10)
+	done := make(chan error)
+	go func() { done <- lock.Maintain(ctx, func(event MaintainEvent) { events <- event }) }()
+
+	event := <-events
+	assert.True(t, event.Held)
+	pids, err := Holders(ctx, db2, id)
+	assert.Nil(t, err)
+	assert.Len(t, pids, 1)
+
+	// Killing the session loses the lock, Maintain reacquires it on a new session.
+	_, err = db2.ExecContext(ctx, "SELECT pg_terminate_backend($1)", pids[0])
+	assert.Nil(t, err)
+	<-event.Ctx.Done()
+	event = <-events
+	assert.False(t, event.Held)
+	assert.True(t, errors.Is(event.Err, ErrLockLost))
+	event = <-events
+	assert.True(t, event.Held)
+	newPIDs, err := Holders(ctx, db2, id)
+	assert.Nil(t, err)
+	assert.Len(t, newPIDs, 1)
+	assert.NotEqual(t, pids, newPIDs)
+
+	cancel()
+	assert.True(t, errors.Is(<-done, context.Canceled))
+	assert.Eventually(t, func() bool {
+		pids, err := Holders(context.Background(), db2, id)
+		return err == nil && len(pids) == 0
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestMaintainClosed(t *testing.T) {
+	db, err := newDB()
+	assert.Nil(t, err)
+	defer closeDB(db)
+
+	ctx := context.Background()
+	id := int64(52)
+	lock, err := NewLock(ctx, id, db, WithHoldCheckInterval(10*time.Millisecond), WithMaintainBackoff(10*time.Millisecond, 50*time.Millisecond))
+	assert.Nil(t, err)
+
+	events := make(chan MaintainEvent, 10)
+	done := make(chan error)
+	go func() { done <- lock.Maintain(ctx, func(event MaintainEvent) { events <- event }) }()
+
+	event := <-events
+	assert.True(t, event.Held)
+
+	// Closing the lock stops Maintain instead of opening a new session.
+	assert.Nil(t, lock.Close())
+	assert.True(t, errors.Is(<-done, ErrLockClosed))
+	assert.Len(t, events, 0)
+	pids, err := Holders(ctx, db, id)
+	assert.Nil(t, err)
+	assert.Len(t, pids, 0)
+}
+
+func TestMaintainSessionLimiter(t *testing.T) {
+	db1, err := newDB()
+	assert.Nil(t, err)
+	defer closeDB(db1)
+	db2, err := newDB()
+	assert.Nil(t, err)
+	defer closeDB(db2)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	id := int64(58)
+	limiter := NewSessionLimiter(1)
+	lock, err := NewLock(ctx, id, db1, WithSessionLimiter(limiter), WithHoldCheckInterval(10*time.Millisecond), WithMaintainBackoff(10*time.Millisecond, 50*time.Millisecond))
+	assert.Nil(t, err)
+	defer lock.Close()
+
+	events := make(chan MaintainEvent, 10)
+	done := make(chan error)
+	go func() { done <- lock.Maintain(ctx, func(event MaintainEvent) { events <- event }) }()
+
+	event := <-events
+	assert.True(t, event.Held)
+	pids, err := Holders(ctx, db2, id)
+	assert.Nil(t, err)
+	assert.Len(t, pids, 1)
+
+	// The lost session frees its only slot, so the replacement session does not wait for it.
+	_, err = db2.ExecContext(ctx, "SELECT pg_terminate_backend($1)", pids[0])
+	assert.Nil(t, err)
+	event = <-events
+	assert.False(t, event.Held)
+	select {
+	case event = <-events:
+		assert.True(t, event.Held)
+	case <-time.After(5 * time.Second):
+		t.Fatal("the lock was not reacquired")
+	}
+	assert.Equal(t, 1, limiter.InUse())
+
+	cancel()
+	assert.True(t, errors.Is(<-done, context.Canceled))
+	assert.Equal(t, 0, limiter.InUse())
+}
@@ -0,0 +1,126 @@
+package pglock
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// AllNamespaces marks every namespace as under maintenance, see Maintenance.Enable.
+const AllNamespaces = "*"
+
+// ErrMaintenance is matched by errors.Is for a *MaintenanceError.
+var ErrMaintenance = errors.New("pglock: maintenance mode")
+
+// MaintenanceError is returned when a lock is acquired in a namespace under maintenance.
+type MaintenanceError struct {
+	// Namespace is the namespace under maintenance, AllNamespaces if maintenance covers every namespace.
+	Namespace string
+	// Reason is the reason given when maintenance was enabled.
+	Reason string
+	// Since is when maintenance was enabled.
+	Since time.Time
+}
+
+// Error implements the error interface.
+func (e *MaintenanceError) Error() string {
+	return fmt.Sprintf("pglock: namespace %q is under maintenance since %s: %s", e.Namespace, e.Since.Format(time.RFC3339), e.Reason)
+}
+
+// Is reports whether target is ErrMaintenance.
+func (e *MaintenanceError) Is(target error) bool {
+	return target == ErrMaintenance
+}
+
+// Maintenance is a cluster wide switch that pauses lock guarded workloads, letting operators drain them before upgrades.
+// While a namespace is under maintenance, locks created with WithMaintenance for it fail new acquisitions with a
+// *MaintenanceError, acquisitions already held or waiting are not interrupted. The switch is a marker row per namespace
+// in a table, changes are serialized with a transaction level advisory lock on a well known key derived from the table name.
+type Maintenance struct {
+	db    *sql.DB
+	table string
+	key   int64
+}
+
+// NewMaintenance returns a Maintenance that stores its marker rows in table, see MaintenanceTable.
+func NewMaintenance(ctx context.Context, db *sql.DB, table string) (*Maintenance, error) {
+	name, err := openTable(ctx, db, MaintenanceTable(table))
+	if err != nil {
+		return nil, err
+	}
+	return &Maintenance{db: db, table: name, key: NS("pglock").NS("maintenance").Key(table).ID}, nil
+}
+
+// Enable puts namespaces under maintenance for reason, or every namespace if none is given.
+// Enabling a namespace already under maintenance updates its reason and keeps its start time.
+func (m *Maintenance) Enable(ctx context.Context, reason string, namespaces ...string) error {
+	if len(namespaces) == 0 {
+		namespaces = []string{AllNamespaces}
+	}
+	sqlQuery := fmt.Sprintf(`INSERT INTO %s (namespace, reason, since) VALUES ($1, $2, clock_timestamp())
+		ON CONFLICT (namespace) DO UPDATE SET reason = excluded.reason`, m.table)
+	return m.change(ctx, sqlQuery, namespaces, reason)
+}
+
+// Disable ends the maintenance of namespaces, or of every namespace if none is given. Ending AllNamespaces does not
+// end the maintenance of namespaces enabled one by one.
+func (m *Maintenance) Disable(ctx context.Context, namespaces ...string) error {
+	if len(namespaces) == 0 {
+		namespaces = []string{AllNamespaces}
+	}
+	sqlQuery := fmt.Sprintf("DELETE FROM %s WHERE namespace = $1", m.table)
+	return m.change(ctx, sqlQuery, namespaces)
+}
+
+// change runs sqlQuery for each namespace in a transaction holding the maintenance key.
+func (m *Maintenance) change(ctx context.Context, sqlQuery string, namespaces []string, args ...interface{}) error {
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if _, err := tx.ExecContext(ctx, "SELECT pg_advisory_xact_lock($1)", m.key); err != nil {
+		return err
+	}
+	for _, namespace := range namespaces {
+		if _, err := tx.ExecContext(ctx, sqlQuery, append([]interface{}{namespace}, args...)...); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// Check returns a *MaintenanceError if namespace, or every namespace, is under maintenance.
+func (m *Maintenance) Check(ctx context.Context, namespace string) error {
+	sqlQuery := fmt.Sprintf(`SELECT namespace, reason, since FROM %s
+		WHERE namespace IN ($1, $2) ORDER BY namespace = $2 DESC LIMIT 1`, m.table)
+	e := &MaintenanceError{}
+	err := m.db.QueryRowContext(ctx, sqlQuery, namespace, AllNamespaces).Scan(&e.Namespace, &e.Reason, &e.Since)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return nil
+	case err != nil:
+		return err
+	}
+	return e
+}
+
+// WithMaintenance makes lock acquisitions fail with a *MaintenanceError while namespace is under maintenance.
+// Maintenance is checked before acquiring, a waiting acquisition is not interrupted when maintenance starts.
+func WithMaintenance(maintenance *Maintenance, namespace string) Option {
+	return func(l *Lock) {
+		l.maintenance = maintenance
+		l.maintenanceNS = namespace
+	}
+}
+
+// checkMaintenance returns a *MaintenanceError if the lock namespace is under maintenance.
+func (l *Lock) checkMaintenance(ctx context.Context) error {
+	if l.maintenance == nil {
+		return nil
+	}
+	return l.maintenance.Check(ctx, l.maintenanceNS)
+}
@@ -0,0 +1,68 @@
+package pglock
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMaintenanceError(t *testing.T) {
+	since := time.Date(2024, 1, 2, 3, 0, 0, 0, time.UTC)
+	err := error(&MaintenanceError{Namespace: "billing", Reason: "upgrade", Since: since})
+	assert.True(t, errors.Is(err, ErrMaintenance))
+	assert.Equal(t, `pglock: namespace "billing" is under maintenance since 2024-01-02T03:00:00Z: upgrade`, err.Error())
+}
+
+func TestMaintenance(t *testing.T) {
+	db, err := newDB()
+	assert.Nil(t, err)
+	defer closeDB(db)
+
+	ctx := context.Background()
+	assert.Nil(t, Migrate(ctx, db, MaintenanceTable("pglock_test_maintenance")))
+	maintenance, err := NewMaintenance(ctx, db, "pglock_test_maintenance")
+	assert.Nil(t, err)
+	defer func() {
+		_, err := db.ExecContext(ctx, "DROP TABLE pglock_test_maintenance")
+		assert.Nil(t, err)
+	}()
+
+	id := int64(51)
+	billing, err := NewLock(ctx, id, db, WithMaintenance(maintenance, "billing"))
+	assert.Nil(t, err)
+	defer billing.Close()
+	reports, err := NewLock(ctx, id+1, db, WithMaintenance(maintenance, "reports"))
+	assert.Nil(t, err)
+	defer reports.Close()
+
+	assert.Nil(t, maintenance.Enable(ctx, "upgrade", "billing"))
+	_, err = billing.Lock(ctx)
+	var maintenanceErr *MaintenanceError
+	if assert.True(t, errors.As(err, &maintenanceErr)) {
+		assert.Equal(t, "billing", maintenanceErr.Namespace)
+		assert.Equal(t, "upgrade", maintenanceErr.Reason)
+	}
+	assert.True(t, errors.Is(billing.WaitAndLock(ctx), ErrMaintenance))
+	assert.Nil(t, reports.WaitAndLock(ctx))
+	assert.Nil(t, reports.Unlock(ctx))
+
+	// Maintenance of every namespace is reported first.
+	assert.Nil(t, maintenance.Enable(ctx, "major upgrade"))
+	err = maintenance.Check(ctx, "billing")
+	if assert.True(t, errors.As(err, &maintenanceErr)) {
+		assert.Equal(t, AllNamespaces, maintenanceErr.Namespace)
+		assert.Equal(t, "major upgrade", maintenanceErr.Reason)
+	}
+	assert.True(t, errors.Is(reports.WaitAndLock(ctx), ErrMaintenance))
+
+	assert.Nil(t, maintenance.Disable(ctx))
+	assert.Nil(t, reports.WaitAndLock(ctx))
+	assert.Nil(t, reports.Unlock(ctx))
+	assert.True(t, errors.Is(billing.WaitAndLock(ctx), ErrMaintenance))
+	assert.Nil(t, maintenance.Disable(ctx, "billing"))
+	assert.Nil(t, billing.WaitAndLock(ctx))
+	assert.Nil(t, billing.Unlock(ctx))
+}
@@ -0,0 +1,51 @@
+package pglock
+
+import (
+	"context"
+	"time"
+)
+
+// HoldViolation reports a lock held for longer than the limit set with WithMaxHold.
+type HoldViolation struct {
+	// ID is the advisory lock id.
+	ID int64
+	// Limit is the expected maximum hold duration.
+	Limit time.Duration
+	// Released reports whether the lock was released automatically.
+	Released bool
+	// Err is the error of the automatic release, if any.
+	Err error
+}
+
+// WithMaxHold declares the expected maximum duration the lock is held, from the moment it is acquired until it is fully released.
+// When the limit is exceeded fn is called and, if autoRelease is true, every acquisition of the session is released first with pg_advisory_unlock_all,
+// turning a lock that is sometimes held for an hour into a measurable, enforced policy.
+func WithMaxHold(limit time.Duration, autoRelease bool, fn func(HoldViolation)) Option {
+	return func(l *Lock) {
+		l.state.maxHold = limit
+		l.state.onMaxHold = func() {
+			violation := HoldViolation{ID: l.id, Limit: limit}
+			if autoRelease {
+				violation.Err = l.releaseAll(context.Background())
+				violation.Released = violation.Err == nil
+			}
+			if fn != nil {
+				fn(violation)
+			}
+		}
+	}
+}
+
+// releaseAll releases every acquisition of the session.
+func (l *Lock) releaseAll(ctx context.Context) error {
+	_, err := l.conn.ExecContext(ctx, "SELECT pg_advisory_unlock_all()")
+	if err != nil {
+		l.state.observe(err)
+		return err
+	}
+	l.state.releasedAll()
+	if err := l.clearPurpose(ctx); err != nil {
+		return err
+	}
+	return l.teardownTempState(ctx)
+}
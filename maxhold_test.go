@@ -0,0 +1,60 @@
+package pglock
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWithMaxHold(t *testing.T) {
+	db, err := newDB()
+	assert.Nil(t, err)
+	defer closeDB(db)
+
+	ctx := context.Background()
+	violations := make(chan HoldViolation, 1)
+	lock, err := NewLock(ctx, int64(26), db, WithMaxHold(100*time.Millisecond, false, func(violation HoldViolation) {
+		violations <- violation
+	}))
+	assert.Nil(t, err)
+	defer lock.Close()
+
+	// Released in time.
+	assert.Nil(t, lock.WaitAndLock(ctx))
+	assert.Nil(t, lock.Unlock(ctx))
+	time.Sleep(200 * time.Millisecond)
+	assert.Len(t, violations, 0)
+
+	assert.Nil(t, lock.WaitAndLock(ctx))
+	violation := <-violations
+	assert.Equal(t, int64(26), violation.ID)
+	assert.False(t, violation.Released)
+	assert.Equal(t, HeldExclusive, lock.Status())
+	assert.Nil(t, lock.Unlock(ctx))
+}
+
+func TestWithMaxHoldAutoRelease(t *testing.T) {
+	db, err := newDB()
+	assert.Nil(t, err)
+	defer closeDB(db)
+
+	ctx := context.Background()
+	violations := make(chan HoldViolation, 1)
+	lock, err := NewLock(ctx, int64(27), db, WithMaxHold(100*time.Millisecond, true, func(violation HoldViolation) {
+		violations <- violation
+	}))
+	assert.Nil(t, err)
+	defer lock.Close()
+
+	assert.Nil(t, lock.WaitAndLock(ctx))
+	violation := <-violations
+	assert.True(t, violation.Released)
+	assert.Nil(t, violation.Err)
+	assert.Equal(t, Idle, lock.Status())
+
+	pids, err := Holders(ctx, db, int64(27))
+	assert.Nil(t, err)
+	assert.Len(t, pids, 0)
+}
@@ -0,0 +1,65 @@
+package pglock
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// ErrTooManyWaiters is matched by errors.Is for a *TooManyWaitersError.
+var ErrTooManyWaiters = errors.New("pglock: too many waiters")
+
+// TooManyWaitersError is returned by WaitAndLock and WaitAndRLock when the lock is configured with WithMaxWaiters
+// and the waiting queue is full.
+type TooManyWaitersError struct {
+	// ID is the advisory lock id.
+	ID int64
+	// Waiters is the number of sessions waiting for the lock.
+	Waiters int
+	// Max is the configured maximum number of waiters.
+	Max int
+	// CorrelationID is the correlation id of the acquisition, see WithCorrelationID.
+	CorrelationID string
+}
+
+// Error implements the error interface.
+func (e *TooManyWaitersError) Error() string {
+	return fmt.Sprintf("pglock: lock %d has %d waiters, the maximum is %d", e.ID, e.Waiters, e.Max) + correlationSuffix(e.CorrelationID)
+}
+
+// Is reports whether target is ErrTooManyWaiters.
+func (e *TooManyWaitersError) Is(target error) bool {
+	return target == ErrTooManyWaiters
+}
+
+// WithMaxWaiters makes WaitAndLock and WaitAndRLock fail fast with a *TooManyWaitersError instead of waiting when
+// max or more sessions are already waiting for the lock, shedding load for work that is no longer worth doing behind
+// a deep queue. Waiters are counted across every session of the database, not only the ones of this process.
+// The limit is soft: sessions that check the queue at the same time may all start waiting.
+func WithMaxWaiters(max int) Option {
+	return func(l *Lock) {
+		l.maxWaiters = max
+	}
+}
+
+// checkWaiters returns a *TooManyWaitersError if the waiting queue of the lock is full.
+func (l *Lock) checkWaiters(ctx context.Context) error {
+	if l.maxWaiters <= 0 {
+		return nil
+	}
+	waiters, err := l.waiters(ctx)
+	if err != nil {
+		return err
+	}
+	if waiters >= l.maxWaiters {
+		return &TooManyWaitersError{ID: l.id, Waiters: waiters, Max: l.maxWaiters, CorrelationID: l.correlationID(ctx)}
+	}
+	return nil
+}
+
+// waiters returns how many sessions are waiting for the lock, counted with the session connection.
+func (l *Lock) waiters(ctx context.Context) (int, error) {
+	waiters := 0
+	err := l.conn.QueryRowContext(ctx, waitersQuery, int64(uint32(l.id>>32)), int64(uint32(l.id))).Scan(&waiters)
+	return waiters, err
+}
@@ -0,0 +1,62 @@
+package pglock
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTooManyWaitersError(t *testing.T) {
+	err := &TooManyWaitersError{ID: 1, Waiters: 3, Max: 2}
+	assert.True(t, errors.Is(err, ErrTooManyWaiters))
+	assert.Equal(t, "pglock: lock 1 has 3 waiters, the maximum is 2", err.Error())
+}
+
+func TestWithMaxWaiters(t *testing.T) {
+	db1, err := newDB()
+	assert.Nil(t, err)
+	defer closeDB(db1)
+	db2, err := newDB()
+	assert.Nil(t, err)
+	defer closeDB(db2)
+	db3, err := newDB()
+	assert.Nil(t, err)
+	defer closeDB(db3)
+
+	ctx := context.Background()
+	id := int64(46)
+	holder, err := NewLock(ctx, id, db1)
+	assert.Nil(t, err)
+	defer holder.Close()
+	waiter, err := NewLock(ctx, id, db2)
+	assert.Nil(t, err)
+	defer waiter.Close()
+	shed, err := NewLock(ctx, id, db3, WithMaxWaiters(1))
+	assert.Nil(t, err)
+	defer shed.Close()
+
+	// Without waiters the limit does not apply.
+	assert.Nil(t, shed.WaitAndLock(ctx))
+	assert.Nil(t, shed.Unlock(ctx))
+
+	assert.Nil(t, holder.WaitAndLock(ctx))
+	done := make(chan error)
+	go func() {
+		done <- waiter.WaitAndLock(ctx)
+	}()
+	assert.Eventually(t, func() bool {
+		waiters, err := Waiters(ctx, db1, id)
+		return err == nil && waiters == 1
+	}, 2*time.Second, 10*time.Millisecond)
+
+	err = shed.WaitAndLock(ctx)
+	assert.Equal(t, &TooManyWaitersError{ID: id, Waiters: 1, Max: 1}, err)
+	assert.Equal(t, Idle, shed.Status())
+
+	assert.Nil(t, holder.Unlock(ctx))
+	assert.Nil(t, <-done)
+	assert.Nil(t, waiter.Unlock(ctx))
+}
@@ -0,0 +1,276 @@
+package pglock
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// DistributedRWMutex is a cluster wide reader/writer mutual exclusion lock with the method set of sync.RWMutex.
+// It is built on the shared and exclusive advisory calls of a RWLocker, and also excludes goroutines of the same process,
+// which would otherwise share the session and all get the lock.
+// Like sync.RWMutex, a goroutine waiting in Lock keeps new readers of the same process from acquiring the lock,
+// so a steady flow of readers cannot starve a writer. Across processes the order is the one of the server lock queue.
+//
+// The methods without error return, like the ones of sync.RWMutex, panic if the database call fails.
+// Use the Context variants to handle errors and cancellation.
+type DistributedRWMutex struct {
+	locker RWLocker
+	local  localRWMutex
+}
+
+// NewDistributedRWMutex returns a DistributedRWMutex using locker, typically a *Lock.
+func NewDistributedRWMutex(locker RWLocker) *DistributedRWMutex {
+	return &DistributedRWMutex{locker: locker}
+}
+
+// Lock locks m for writing, waiting until it is available.
+func (m *DistributedRWMutex) Lock() {
+	must(m.LockContext(context.Background()))
+}
+
+// Unlock unlocks m for writing.
+func (m *DistributedRWMutex) Unlock() {
+	must(m.UnlockContext(context.Background()))
+}
+
+// RLock locks m for reading, waiting until it is available.
+func (m *DistributedRWMutex) RLock() {
+	must(m.RLockContext(context.Background()))
+}
+
+// RUnlock undoes a single RLock call.
+func (m *DistributedRWMutex) RUnlock() {
+	must(m.RUnlockContext(context.Background()))
+}
+
+// TryLock tries to lock m for writing and reports whether it succeeded.
+func (m *DistributedRWMutex) TryLock() bool {
+	ok, err := m.TryLockContext(context.Background())
+	must(err)
+	return ok
+}
+
+// TryRLock tries to lock m for reading and reports whether it succeeded.
+func (m *DistributedRWMutex) TryRLock() bool {
+	ok, err := m.TryRLockContext(context.Background())
+	must(err)
+	return ok
+}
+
+// RLocker returns a sync.Locker that implements Lock and Unlock by calling m.RLock and m.RUnlock.
+func (m *DistributedRWMutex) RLocker() sync.Locker {
+	return rlocker{m}
+}
+
+// LockContext locks m for writing, waiting until it is available or ctx is done.
+func (m *DistributedRWMutex) LockContext(ctx context.Context) error {
+	if err := m.local.lock(ctx); err != nil {
+		return err
+	}
+	if err := m.locker.WaitAndLock(ctx); err != nil {
+		m.local.unlock()
+		return err
+	}
+	return nil
+}
+
+// UnlockContext unlocks m for writing.
+// The local lock is released once the server lock is, so another goroutine cannot stack on the session in between.
+// If the server call fails m stays locked and UnlockContext may be retried, unless the lock was released by
+// the loss of the session (ErrReleasedBySessionLoss).
+func (m *DistributedRWMutex) UnlockContext(ctx context.Context) error {
+	m.local.mustHold()
+	err := m.locker.Unlock(ctx)
+	if err != nil && !errors.Is(err, ErrReleasedBySessionLoss) {
+		return err
+	}
+	m.local.unlock()
+	return err
+}
+
+// RLockContext locks m for reading, waiting until it is available or ctx is done.
+func (m *DistributedRWMutex) RLockContext(ctx context.Context) error {
+	if err := m.local.rlock(ctx); err != nil {
+		return err
+	}
+	if err := m.locker.WaitAndRLock(ctx); err != nil {
+		m.local.runlock()
+		return err
+	}
+	return nil
+}
+
+// RUnlockContext undoes a single RLockContext call.
+// Like UnlockContext, the local read lock is kept if the server call fails.
+func (m *DistributedRWMutex) RUnlockContext(ctx context.Context) error {
+	m.local.mustRHold()
+	err := m.locker.RUnlock(ctx)
+	if err != nil && !errors.Is(err, ErrReleasedBySessionLoss) {
+		return err
+	}
+	m.local.runlock()
+	return err
+}
+
+// TryLockContext tries to lock m for writing without waiting and reports whether it succeeded.
+func (m *DistributedRWMutex) TryLockContext(ctx context.Context) (bool, error) {
+	if !m.local.tryLock() {
+		return false, nil
+	}
+	ok, err := m.locker.Lock(ctx)
+	if !ok || err != nil {
+		m.local.unlock()
+	}
+	return ok, err
+}
+
+// TryRLockContext tries to lock m for reading without waiting and reports whether it succeeded.
+func (m *DistributedRWMutex) TryRLockContext(ctx context.Context) (bool, error) {
+	if !m.local.tryRLock() {
+		return false, nil
+	}
+	ok, err := m.locker.RLock(ctx)
+	if !ok || err != nil {
+		m.local.runlock()
+	}
+	return ok, err
+}
+
+func must(err error) {
+	if err != nil {
+		panic(err)
+	}
+}
+
+type rlocker struct {
+	m *DistributedRWMutex
+}
+
+func (r rlocker) Lock()   { r.m.RLock() }
+func (r rlocker) Unlock() { r.m.RUnlock() }
+
+// localRWMutex is an in-process reader/writer lock whose waits can be canceled with a context.
+// Pending writers block new readers, as in sync.RWMutex.
+type localRWMutex struct {
+	mu      sync.Mutex
+	writer  bool
+	readers int
+	pending int
+	changed chan struct{}
+}
+
+// wait returns a channel closed on the next release, the caller must hold mu.
+func (m *localRWMutex) wait() chan struct{} {
+	if m.changed == nil {
+		m.changed = make(chan struct{})
+	}
+	return m.changed
+}
+
+// notify wakes up the waiters, the caller must hold mu.
+func (m *localRWMutex) notify() {
+	if m.changed != nil {
+		close(m.changed)
+		m.changed = nil
+	}
+}
+
+func (m *localRWMutex) acquire(ctx context.Context, try func() bool) error {
+	for {
+		m.mu.Lock()
+		if try() {
+			m.mu.Unlock()
+			return nil
+		}
+		changed := m.wait()
+		m.mu.Unlock()
+
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+func (m *localRWMutex) lock(ctx context.Context) error {
+	m.mu.Lock()
+	m.pending++
+	m.mu.Unlock()
+	err := m.acquire(ctx, m.tryLockLocked)
+	m.mu.Lock()
+	m.pending--
+	// Readers held back by this writer may go on if it gave up.
+	m.notify()
+	m.mu.Unlock()
+	return err
+}
+
+func (m *localRWMutex) rlock(ctx context.Context) error {
+	return m.acquire(ctx, m.tryRLockLocked)
+}
+
+func (m *localRWMutex) tryLock() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.tryLockLocked()
+}
+
+func (m *localRWMutex) tryRLock() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.tryRLockLocked()
+}
+
+func (m *localRWMutex) tryLockLocked() bool {
+	if m.writer || m.readers > 0 {
+		return false
+	}
+	m.writer = true
+	return true
+}
+
+func (m *localRWMutex) tryRLockLocked() bool {
+	if m.writer || m.pending > 0 {
+		return false
+	}
+	m.readers++
+	return true
+}
+
+func (m *localRWMutex) mustHold() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if !m.writer {
+		panic("pglock: unlock of unlocked DistributedRWMutex")
+	}
+}
+
+func (m *localRWMutex) mustRHold() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.readers == 0 {
+		panic("pglock: RUnlock of unlocked DistributedRWMutex")
+	}
+}
+
+func (m *localRWMutex) unlock() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if !m.writer {
+		panic("pglock: unlock of unlocked DistributedRWMutex")
+	}
+	m.writer = false
+	m.notify()
+}
+
+func (m *localRWMutex) runlock() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.readers == 0 {
+		panic("pglock: RUnlock of unlocked DistributedRWMutex")
+	}
+	m.readers--
+	m.notify()
+}
@@ -0,0 +1,123 @@
+package pglock
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/allisson/go-pglock/v3/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestDistributedRWMutex(t *testing.T) {
+	locker := mocks.NewRWLocker(t)
+	locker.On("WaitAndLock", mock.Anything).Return(nil).Once()
+	locker.On("Unlock", mock.Anything).Return(nil).Once()
+	locker.On("WaitAndRLock", mock.Anything).Return(nil).Twice()
+	locker.On("RUnlock", mock.Anything).Return(nil).Twice()
+	m := NewDistributedRWMutex(locker)
+
+	m.Lock()
+	// Goroutines of the same process are excluded locally.
+	assert.False(t, m.TryLock())
+	assert.False(t, m.TryRLock())
+	m.Unlock()
+
+	m.RLock()
+	m.RLocker().Lock()
+	assert.False(t, m.TryLock())
+	m.RLocker().Unlock()
+	m.RUnlock()
+}
+
+func TestDistributedRWMutexWaitsLocally(t *testing.T) {
+	locker := mocks.NewRWLocker(t)
+	locker.On("WaitAndRLock", mock.Anything).Return(nil).Once()
+	locker.On("RUnlock", mock.Anything).Return(nil).Once()
+	locker.On("WaitAndLock", mock.Anything).Return(nil).Once()
+	locker.On("Unlock", mock.Anything).Return(nil).Once()
+	m := NewDistributedRWMutex(locker)
+
+	m.RLock()
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, m.LockContext(ctx), context.DeadlineExceeded)
+
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		m.RUnlock()
+	}()
+	assert.Nil(t, m.LockContext(context.Background()))
+	assert.Nil(t, m.UnlockContext(context.Background()))
+}
+
+func TestDistributedRWMutexErrors(t *testing.T) {
+	errDB := errors.New("connection refused")
+	locker := mocks.NewRWLocker(t)
+	locker.On("WaitAndLock", mock.Anything).Return(errDB).Once()
+	locker.On("Lock", mock.Anything).Return(false, nil).Once()
+	m := NewDistributedRWMutex(locker)
+
+	assert.ErrorIs(t, m.LockContext(context.Background()), errDB)
+	// A failed or missed acquisition must not keep the local lock.
+	ok, err := m.TryLockContext(context.Background())
+	assert.Nil(t, err)
+	assert.False(t, ok)
+	assert.Panics(t, func() { m.Unlock() })
+}
+
+func TestDistributedRWMutexWriterPreference(t *testing.T) {
+	locker := mocks.NewRWLocker(t)
+	locker.On("WaitAndRLock", mock.Anything).Return(nil).Twice()
+	locker.On("RUnlock", mock.Anything).Return(nil).Twice()
+	locker.On("WaitAndLock", mock.Anything).Return(nil).Once()
+	locker.On("Unlock", mock.Anything).Return(nil).Once()
+	m := NewDistributedRWMutex(locker)
+
+	m.RLock()
+	// A writer that gives up lets new readers in again.
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, m.LockContext(ctx), context.DeadlineExceeded)
+	m.RLock()
+	m.RUnlock()
+
+	locked := make(chan error)
+	go func() { locked <- m.LockContext(context.Background()) }()
+	// A waiting writer keeps new readers out.
+	assert.Eventually(t, func() bool { return !m.TryRLock() }, time.Second, time.Millisecond)
+	ctx, cancel = context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, m.RLockContext(ctx), context.DeadlineExceeded)
+
+	m.RUnlock()
+	assert.Nil(t, <-locked)
+	m.Unlock()
+}
+
+func TestDistributedRWMutexUnlockError(t *testing.T) {
+	errDB := errors.New("connection refused")
+	locker := mocks.NewRWLocker(t)
+	locker.On("WaitAndLock", mock.Anything).Return(nil).Once()
+	locker.On("Unlock", mock.Anything).Return(errDB).Once()
+	locker.On("Unlock", mock.Anything).Return(ErrReleasedBySessionLoss).Once()
+	locker.On("WaitAndRLock", mock.Anything).Return(nil).Once()
+	locker.On("RUnlock", mock.Anything).Return(errDB).Once()
+	locker.On("RUnlock", mock.Anything).Return(nil).Once()
+	m := NewDistributedRWMutex(locker)
+
+	m.Lock()
+	// The local lock is kept until the server lock is released.
+	assert.ErrorIs(t, m.UnlockContext(context.Background()), errDB)
+	assert.False(t, m.TryRLock())
+	// A session loss released the server lock, so the local one is released too.
+	assert.ErrorIs(t, m.UnlockContext(context.Background()), ErrReleasedBySessionLoss)
+
+	m.RLock()
+	assert.ErrorIs(t, m.RUnlockContext(context.Background()), errDB)
+	assert.False(t, m.TryLock())
+	assert.Nil(t, m.RUnlockContext(context.Background()))
+	assert.Panics(t, func() { m.RUnlock() })
+}
@@ -0,0 +1,126 @@
+package pglock
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ErrUnbalancedSection is matched by errors.Is for a *SectionError.
+var ErrUnbalancedSection = errors.New("pglock: unbalanced section")
+
+// SectionError is returned by Section.End when the section is not the innermost open section of its lock,
+// for example because a nested helper returned without ending its section, or because the lock was released.
+type SectionError struct {
+	// ID is the advisory lock id.
+	ID int64
+	// Name is the name of the section being ended.
+	Name string
+	// Open are the names of the open sections of the lock, outermost first.
+	Open []string
+}
+
+// Error implements the error interface.
+func (e *SectionError) Error() string {
+	if len(e.Open) == 0 {
+		return fmt.Sprintf("pglock: ending section %q of lock %d, but no section is open", e.Name, e.ID)
+	}
+	innermost := e.Open[len(e.Open)-1]
+	return fmt.Sprintf("pglock: ending section %q of lock %d, but the innermost open section is %q (open: %s)", e.Name, e.ID, innermost, strings.Join(e.Open, sectionSeparator))
+}
+
+// Is reports whether target is ErrUnbalancedSection.
+func (e *SectionError) Is(target error) bool {
+	return target == ErrUnbalancedSection
+}
+
+// sectionSeparator joins the names of nested sections.
+const sectionSeparator = " > "
+
+// SectionEvent reports a section that ended, see WithSectionObserver.
+type SectionEvent struct {
+	// ID is the advisory lock id.
+	ID int64
+	// Path are the names of the section and of the sections it was nested in, outermost first.
+	Path []string
+	// Duration is how long the section was open.
+	Duration time.Duration
+}
+
+// WithSectionObserver registers a function called when a section of the lock ends, for audit or metrics.
+func WithSectionObserver(fn func(SectionEvent)) Option {
+	return func(l *Lock) {
+		l.onSection = fn
+	}
+}
+
+// Section is a named logical section of a critical section, see Nest.
+type Section struct {
+	l     *Lock
+	name  string
+	depth int
+	seq   uint64
+	start time.Time
+}
+
+// openSection is an open section of a lock. Its sequence number tells apart sections with the same name at the same
+// depth, so that a Section ended twice, or kept from a previous hold, cannot end a newer one.
+type openSection struct {
+	name string
+	seq  uint64
+}
+
+// sectionNames returns the names of the open sections, outermost first, nil if none is open.
+func (s *lockState) sectionNames() []string {
+	if len(s.sections) == 0 {
+		return nil
+	}
+	names := make([]string, len(s.sections))
+	for i, section := range s.sections {
+		names[i] = section.name
+	}
+	return names
+}
+
+// Nest opens a named section nested in the current one while the lock is held, so large critical sections built from
+// helper functions get structured visibility without extra locks. Sections must be ended in reverse order with End.
+// Nest returns ErrNotHeld if the lock is not held. Open sections are dropped when the lock stops being held.
+func (l *Lock) Nest(name string) (*Section, error) {
+	l.state.mu.Lock()
+	defer l.state.mu.Unlock()
+	if !l.state.state.held() {
+		return nil, ErrNotHeld
+	}
+	l.state.sequence++
+	l.state.sections = append(l.state.sections, openSection{name: name, seq: l.state.sequence})
+	return &Section{l: l, name: name, depth: len(l.state.sections), seq: l.state.sequence, start: l.now()}, nil
+}
+
+// Sections returns the names of the open sections of the lock, outermost first.
+func (l *Lock) Sections() []string {
+	l.state.mu.Lock()
+	defer l.state.mu.Unlock()
+	return l.state.sectionNames()
+}
+
+// End closes the section. It returns a *SectionError, and leaves the open sections unchanged, if the section is
+// not the innermost open section of the lock, including when it was already ended and a section with the same name
+// was opened since.
+func (s *Section) End() error {
+	state := s.l.state
+	state.mu.Lock()
+	if len(state.sections) != s.depth || state.sections[s.depth-1].seq != s.seq {
+		open := state.sectionNames()
+		state.mu.Unlock()
+		return &SectionError{ID: s.l.id, Name: s.name, Open: open}
+	}
+	path := state.sectionNames()
+	state.sections = state.sections[:s.depth-1]
+	state.mu.Unlock()
+
+	if s.l.onSection != nil {
+		s.l.onSection(SectionEvent{ID: s.l.id, Path: path, Duration: s.l.since(s.start)})
+	}
+	return nil
+}
@@ -0,0 +1,68 @@
+package pglock
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNest(t *testing.T) {
+	var events []SectionEvent
+	l := &Lock{id: 1, state: &lockState{clock: systemClock{}}}
+	WithSectionObserver(func(e SectionEvent) { events = append(events, e) })(l)
+
+	_, err := l.Nest("load")
+	assert.Equal(t, ErrNotHeld, err)
+
+	l.state.endAcquire(false, AcquireResult{Acquired: true}, nil)
+	outer, err := l.Nest("load")
+	assert.Nil(t, err)
+	inner, err := l.Nest("parse")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"load", "parse"}, l.Sections())
+
+	err = outer.End()
+	assert.True(t, errors.Is(err, ErrUnbalancedSection))
+	assert.Equal(t, `pglock: ending section "load" of lock 1, but the innermost open section is "parse" (open: load > parse)`, err.Error())
+	assert.Equal(t, []string{"load", "parse"}, l.Sections())
+
+	assert.Nil(t, inner.End())
+	assert.Nil(t, outer.End())
+	assert.Empty(t, l.Sections())
+	if assert.Len(t, events, 2) {
+		assert.Equal(t, []string{"load", "parse"}, events[0].Path)
+		assert.Equal(t, []string{"load"}, events[1].Path)
+	}
+
+	section, err := l.Nest("write")
+	assert.Nil(t, err)
+	l.state.endRelease(false, true, nil)
+	assert.Empty(t, l.Sections())
+	err = section.End()
+	assert.Equal(t, &SectionError{ID: 1, Name: "write", Open: nil}, err)
+	assert.Equal(t, `pglock: ending section "write" of lock 1, but no section is open`, err.Error())
+}
+
+func TestNestStaleSection(t *testing.T) {
+	l := &Lock{id: 1, state: &lockState{clock: systemClock{}}}
+	l.state.endAcquire(false, AcquireResult{Acquired: true}, nil)
+
+	stale, err := l.Nest("load")
+	assert.Nil(t, err)
+	assert.Nil(t, stale.End())
+	current, err := l.Nest("load")
+	assert.Nil(t, err)
+
+	err = stale.End()
+	assert.Equal(t, &SectionError{ID: 1, Name: "load", Open: []string{"load"}}, err)
+	assert.Equal(t, []string{"load"}, l.Sections())
+	assert.Nil(t, current.End())
+
+	l.state.endRelease(false, true, nil)
+	l.state.endAcquire(false, AcquireResult{Acquired: true}, nil)
+	_, err = l.Nest("load")
+	assert.Nil(t, err)
+	assert.True(t, errors.Is(current.End(), ErrUnbalancedSection))
+	assert.Equal(t, []string{"load"}, l.Sections())
+}
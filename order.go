@@ -0,0 +1,153 @@
+package pglock
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// ErrLockOrder is matched by errors.Is for an *OrderViolation.
+var ErrLockOrder = errors.New("pglock: lock order violation")
+
+// OrderViolation reports an acquisition that goes against the order declared in a LockOrder, a potential deadlock.
+type OrderViolation struct {
+	// ID is the advisory lock id being acquired.
+	ID int64
+	// Acquiring is the namespace of the lock being acquired.
+	Acquiring string
+	// Held is the namespace of a lock already held in the same scope that should have been acquired after it.
+	Held string
+}
+
+// Error implements the error interface.
+func (v *OrderViolation) Error() string {
+	return fmt.Sprintf("pglock: acquiring lock %d of %q while holding %q, %q must be acquired first", v.ID, v.Acquiring, v.Held, v.Acquiring)
+}
+
+// Is reports whether target is ErrLockOrder.
+func (v *OrderViolation) Is(target error) bool {
+	return target == ErrLockOrder
+}
+
+// LockOrder is a partial order of lock namespaces. Locks created with WithLockOrder check every acquisition made
+// with a context from WithOrderScope against the locks already held in that scope, and report acquisitions that
+// go against the order before they deadlock in production.
+type LockOrder struct {
+	mu          sync.RWMutex
+	after       map[string]map[string]bool
+	strict      bool
+	onViolation func(*OrderViolation)
+}
+
+// NewLockOrder returns an empty LockOrder. Violations are reported to onViolation if it is not nil, and with
+// strict the acquisitions are also refused with the *OrderViolation, which is useful in tests.
+func NewLockOrder(strict bool, onViolation func(*OrderViolation)) *LockOrder {
+	return &LockOrder{after: map[string]map[string]bool{}, strict: strict, onViolation: onViolation}
+}
+
+// Declare declares that locks of namespace first must be acquired before locks of namespace then when both are held.
+// The order is transitive. Declare returns an error if the declaration contradicts the order.
+func (o *LockOrder) Declare(first, then string) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if first == then || o.after[then][first] {
+		return fmt.Errorf("pglock: declaring %q before %q contradicts the lock order", first, then)
+	}
+	// Everything before first, and first itself, now precedes then and everything after then.
+	successors := []string{then}
+	for ns := range o.after[then] {
+		successors = append(successors, ns)
+	}
+	predecessors := []string{first}
+	for ns, after := range o.after {
+		if after[first] {
+			predecessors = append(predecessors, ns)
+		}
+	}
+	for _, p := range predecessors {
+		if o.after[p] == nil {
+			o.after[p] = map[string]bool{}
+		}
+		for _, s := range successors {
+			o.after[p][s] = true
+		}
+	}
+	return nil
+}
+
+// precedes reports whether locks of namespace a must be acquired before locks of namespace b.
+func (o *LockOrder) precedes(a, b string) bool {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+	return o.after[a][b]
+}
+
+// WithLockOrder checks the acquisitions of the lock, which belongs to namespace, against order.
+func WithLockOrder(order *LockOrder, namespace string) Option {
+	return func(l *Lock) {
+		l.order = order
+		l.orderNamespace = namespace
+	}
+}
+
+type orderScopeKey struct{}
+
+// orderScope records the locks acquired within a scope, typically a request or a job.
+type orderScope struct {
+	mu   sync.Mutex
+	held map[*lockState]string
+}
+
+// WithOrderScope returns a copy of ctx starting a lock order scope: locks created with WithLockOrder and
+// acquired with ctx, or a context derived from it, are checked against the locks they find held in the scope.
+func WithOrderScope(ctx context.Context) context.Context {
+	return context.WithValue(ctx, orderScopeKey{}, &orderScope{held: map[*lockState]string{}})
+}
+
+// checkOrder reports, and with a strict order refuses, an acquisition going against the lock order.
+func (l *Lock) checkOrder(ctx context.Context) error {
+	scope, ok := ctx.Value(orderScopeKey{}).(*orderScope)
+	if l.order == nil || !ok {
+		return nil
+	}
+	scope.mu.Lock()
+	if _, reentrant := scope.held[l.state]; reentrant && l.state.current().held() {
+		// Stacking a lock already held in the scope does not change the acquisition order.
+		scope.mu.Unlock()
+		return nil
+	}
+	var violation *OrderViolation
+	for state, namespace := range scope.held {
+		switch {
+		case !state.current().held():
+			// Released locks are dropped lazily, so unlocking does not need the scope.
+			delete(scope.held, state)
+		case l.order.precedes(l.orderNamespace, namespace):
+			violation = &OrderViolation{ID: l.id, Acquiring: l.orderNamespace, Held: namespace}
+		}
+	}
+	scope.mu.Unlock()
+
+	if violation == nil {
+		return nil
+	}
+	if l.order.onViolation != nil {
+		l.order.onViolation(violation)
+	}
+	if l.order.strict {
+		return violation
+	}
+	return nil
+}
+
+// enterOrderScope records the lock as held in the order scope of ctx.
+func (l *Lock) enterOrderScope(ctx context.Context) {
+	scope, ok := ctx.Value(orderScopeKey{}).(*orderScope)
+	if l.order == nil || !ok {
+		return
+	}
+	scope.mu.Lock()
+	scope.held[l.state] = l.orderNamespace
+	scope.mu.Unlock()
+}
@@ -0,0 +1,66 @@
+package pglock
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLockOrderDeclare(t *testing.T) {
+	order := NewLockOrder(false, nil)
+	assert.Nil(t, order.Declare("accounts", "invoices"))
+	assert.Nil(t, order.Declare("invoices", "payments"))
+	assert.True(t, order.precedes("accounts", "payments"))
+	assert.False(t, order.precedes("payments", "accounts"))
+
+	assert.NotNil(t, order.Declare("payments", "accounts"))
+	assert.NotNil(t, order.Declare("accounts", "accounts"))
+	assert.Nil(t, order.Declare("tenants", "accounts"))
+	assert.True(t, order.precedes("tenants", "payments"))
+}
+
+func TestCheckOrder(t *testing.T) {
+	violations := []*OrderViolation{}
+	order := NewLockOrder(true, func(v *OrderViolation) { violations = append(violations, v) })
+	assert.Nil(t, order.Declare("accounts", "invoices"))
+
+	newLock := func(id int64, namespace string) *Lock {
+		l := &Lock{id: id, state: &lockState{clock: systemClock{}}}
+		WithLockOrder(order, namespace)(l)
+		return l
+	}
+	acquire := func(ctx context.Context, l *Lock) error {
+		if err := l.checkOrder(ctx); err != nil {
+			return err
+		}
+		l.state.endAcquire(false, AcquireResult{Acquired: true}, nil)
+		l.enterOrderScope(ctx)
+		return nil
+	}
+	accounts, invoices := newLock(1, "accounts"), newLock(2, "invoices")
+
+	// Outside a scope nothing is checked.
+	assert.Nil(t, acquire(context.Background(), invoices))
+	assert.Nil(t, acquire(context.Background(), accounts))
+	accounts.state.endRelease(false, true, nil)
+	invoices.state.endRelease(false, true, nil)
+
+	ctx := WithOrderScope(context.Background())
+	assert.Nil(t, acquire(ctx, accounts))
+	assert.Nil(t, acquire(ctx, invoices))
+	assert.Nil(t, acquire(ctx, accounts))
+	assert.Empty(t, violations)
+	accounts.state.endRelease(false, true, nil)
+	accounts.state.endRelease(false, true, nil)
+
+	err := acquire(ctx, accounts)
+	assert.True(t, errors.Is(err, ErrLockOrder))
+	assert.Equal(t, `pglock: acquiring lock 1 of "accounts" while holding "invoices", "accounts" must be acquired first`, err.Error())
+	assert.Equal(t, []*OrderViolation{{ID: 1, Acquiring: "accounts", Held: "invoices"}}, violations)
+
+	// Once released, a lock no longer constrains the scope.
+	invoices.state.endRelease(false, true, nil)
+	assert.Nil(t, acquire(ctx, accounts))
+}
@@ -0,0 +1,190 @@
+// Package pglockhttp holds postgresql advisory locks for the duration of HTTP requests.
+package pglockhttp
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/allisson/go-pglock/v3"
+)
+
+// defaultReleaseTimeout bounds the release of the lock after the handler returns.
+const defaultReleaseTimeout = 5 * time.Second
+
+// KeyFunc returns the lock id guarding a request. An error makes the guard respond 400 Bad Request.
+type KeyFunc func(r *http.Request) (int64, error)
+
+// Guard holds an exclusive advisory lock on its own session while a handler serves a request, and keeps the
+// metrics of one route. Handlers find the lock with pglock.FromContext on the request context.
+type Guard struct {
+	db             *sql.DB
+	key            KeyFunc
+	try            bool
+	releaseTimeout time.Duration
+	lockOptions    []pglock.Option
+
+	mu    sync.Mutex
+	stats Stats
+}
+
+// Option configures a Guard created by New.
+type Option func(*Guard)
+
+// WithTry makes the guard respond 409 Conflict when the lock is held instead of waiting for it.
+// The response carries a Retry-After header when the lock reports a hint, see pglock.WithRetryAfterHint.
+func WithTry() Option {
+	return func(g *Guard) {
+		g.try = true
+	}
+}
+
+// WithReleaseTimeout bounds how long releasing the lock may take after the handler returns, see pglock.Lock.UnlockWithin.
+func WithReleaseTimeout(d time.Duration) Option {
+	return func(g *Guard) {
+		g.releaseTimeout = d
+	}
+}
+
+// WithLockOptions sets the options of the locks created for each request.
+func WithLockOptions(opts ...pglock.Option) Option {
+	return func(g *Guard) {
+		g.lockOptions = append(g.lockOptions, opts...)
+	}
+}
+
+// New returns a Guard taking the locks returned by key on sessions from db.
+func New(db *sql.DB, key KeyFunc, opts ...Option) *Guard {
+	// Unlocking on close lets the session go back to the pool instead of being discarded after each request.
+	g := &Guard{db: db, key: key, releaseTimeout: defaultReleaseTimeout, lockOptions: []pglock.Option{pglock.WithAutoUnlockOnClose(nil)}}
+	for _, opt := range opts {
+		opt(g)
+	}
+	return g
+}
+
+// Stats are the metrics of a Guard.
+// Durations are encoded to JSON as strings, for example "1.5ms".
+type Stats struct {
+	// Requests is the number of requests seen by the guard.
+	Requests int64 `json:"requests"`
+	// Acquired is the number of requests served with the lock held.
+	Acquired int64 `json:"acquired"`
+	// Rejected is the number of requests answered 409 Conflict because the lock was held, only with WithTry.
+	Rejected int64 `json:"rejected"`
+	// Failed is the number of requests that could not be keyed or locked, including clients that went away while waiting.
+	Failed int64 `json:"failed"`
+	// LockWait is the total time spent acquiring the lock.
+	LockWait time.Duration `json:"lock_wait"`
+	// Held is the total time the lock was held by handlers.
+	Held time.Duration `json:"held"`
+}
+
+// MarshalJSON implements json.Marshaler.
+func (s Stats) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Requests int64  `json:"requests"`
+		Acquired int64  `json:"acquired"`
+		Rejected int64  `json:"rejected"`
+		Failed   int64  `json:"failed"`
+		LockWait string `json:"lock_wait"`
+		Held     string `json:"held"`
+	}{s.Requests, s.Acquired, s.Rejected, s.Failed, s.LockWait.String(), s.Held.String()})
+}
+
+// Stats returns the metrics of the guard.
+func (g *Guard) Stats() Stats {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.stats
+}
+
+// Wrap returns a handler serving each request with next while holding its lock. The lock is acquired with the
+// request context, so a client that goes away stops the wait. It is released when next returns or when the
+// request context is done, for example because the client disconnected, whichever comes first, with its own
+// timeout since the request context is done by then. Handlers still running after a disconnect no longer hold the
+// lock, pglock.RequireHeld reports it, so they should stop their work when the request context is done.
+func (g *Guard) Wrap(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		g.record(func(s *Stats) { s.Requests++ })
+		id, err := g.key(r)
+		if err != nil {
+			g.record(func(s *Stats) { s.Failed++ })
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		ctx := r.Context()
+		lock, err := pglock.NewLock(ctx, id, g.db, g.lockOptions...)
+		if err != nil {
+			g.fail(w)
+			return
+		}
+		defer lock.Close()
+
+		start := time.Now()
+		err = g.acquire(ctx, &lock)
+		wait := time.Since(start)
+		var processing *pglock.AlreadyProcessingError
+		switch {
+		case errors.As(err, &processing):
+			g.record(func(s *Stats) { s.Rejected++; s.LockWait += wait })
+			if processing.RetryAfter > 0 {
+				w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(processing.RetryAfter.Seconds()))))
+			}
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		case err != nil:
+			g.fail(w)
+			return
+		}
+		g.record(func(s *Stats) { s.Acquired++; s.LockWait += wait })
+
+		held := time.Now()
+		var release sync.Once
+		unlock := func() {
+			release.Do(func() {
+				_ = lock.UnlockWithin(g.releaseTimeout)
+				g.record(func(s *Stats) { s.Held += time.Since(held) })
+			})
+		}
+		served := make(chan struct{})
+		go func() {
+			select {
+			case <-ctx.Done():
+				unlock()
+			case <-served:
+			}
+		}()
+		defer func() {
+			close(served)
+			unlock()
+		}()
+		next.ServeHTTP(w, r.WithContext(pglock.WithLock(ctx, &lock)))
+	})
+}
+
+func (g *Guard) acquire(ctx context.Context, lock *pglock.Lock) error {
+	if g.try {
+		return lock.TryLockOrError(ctx)
+	}
+	return lock.WaitAndLock(ctx)
+}
+
+// fail answers a request that could not be locked with 503 Service Unavailable.
+func (g *Guard) fail(w http.ResponseWriter) {
+	g.record(func(s *Stats) { s.Failed++ })
+	http.Error(w, "pglockhttp: could not acquire lock", http.StatusServiceUnavailable)
+}
+
+func (g *Guard) record(update func(*Stats)) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	update(&g.stats)
+}
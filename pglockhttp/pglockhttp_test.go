@@ -0,0 +1,130 @@
+package pglockhttp
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/allisson/go-pglock/v3"
+	_ "github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+)
+
+func newDB() (*sql.DB, error) {
+	dsn := os.Getenv("DATABASE_URL")
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		return nil, err
+	}
+	return db, db.Ping()
+}
+
+func fixedKey(id int64) KeyFunc {
+	return func(r *http.Request) (int64, error) { return id, nil }
+}
+
+func TestGuardBadKey(t *testing.T) {
+	guard := New(nil, func(r *http.Request) (int64, error) { return 0, errors.New("missing job id") })
+	handler := guard.Wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Fatal("handler must not run")
+	}))
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/jobs", nil))
+	assert.Equal(t, http.StatusBadRequest, recorder.Code)
+	assert.Equal(t, Stats{Requests: 1, Failed: 1}, guard.Stats())
+}
+
+func TestStatsJSON(t *testing.T) {
+	data, err := json.Marshal(Stats{Requests: 3, Acquired: 1, Rejected: 1, Failed: 1, LockWait: time.Millisecond, Held: time.Second})
+	assert.Nil(t, err)
+	assert.JSONEq(t, `{"requests":3,"acquired":1,"rejected":1,"failed":1,"lock_wait":"1ms","held":"1s"}`, string(data))
+}
+
+func TestGuard(t *testing.T) {
+	db, err := newDB()
+	assert.Nil(t, err)
+	defer db.Close()
+
+	id := int64(43)
+	guard := New(db, fixedKey(id), WithTry(), WithLockOptions(pglock.WithRetryAfterHint(1500*time.Millisecond)))
+	inside := make(chan struct{})
+	release := make(chan struct{})
+	handler := guard.Wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Nil(t, pglock.RequireHeld(r.Context(), id))
+		if r.URL.Path == "/slow" {
+			close(inside)
+			<-release
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	done := make(chan int)
+	go func() {
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/slow", nil))
+		done <- recorder.Code
+	}()
+	<-inside
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/fast", nil))
+	assert.Equal(t, http.StatusConflict, recorder.Code)
+	assert.Equal(t, "2", recorder.Header().Get("Retry-After"))
+
+	close(release)
+	assert.Equal(t, http.StatusNoContent, <-done)
+	recorder = httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/fast", nil))
+	assert.Equal(t, http.StatusNoContent, recorder.Code)
+
+	stats := guard.Stats()
+	assert.Equal(t, int64(3), stats.Requests)
+	assert.Equal(t, int64(2), stats.Acquired)
+	assert.Equal(t, int64(1), stats.Rejected)
+	assert.NotZero(t, stats.Held)
+}
+
+func TestGuardReleasesOnDisconnect(t *testing.T) {
+	db, err := newDB()
+	assert.Nil(t, err)
+	defer db.Close()
+
+	id := int64(56)
+	guard := New(db, fixedKey(id))
+	inside := make(chan struct{})
+	release := make(chan struct{})
+	handler := guard.Wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(inside)
+		// The handler ignores the disconnect on purpose.
+		<-release
+	}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/jobs", nil).WithContext(ctx))
+		close(done)
+	}()
+	<-inside
+	pids, err := pglock.Holders(context.Background(), db, id)
+	assert.Nil(t, err)
+	assert.Len(t, pids, 1)
+
+	// The client goes away, the lock is released while the handler is still running.
+	cancel()
+	assert.Eventually(t, func() bool {
+		pids, err := pglock.Holders(context.Background(), db, id)
+		return err == nil && len(pids) == 0
+	}, 5*time.Second, 10*time.Millisecond)
+
+	close(release)
+	<-done
+	assert.Equal(t, int64(1), guard.Stats().Acquired)
+}
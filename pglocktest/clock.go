@@ -0,0 +1,139 @@
+package pglocktest
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/allisson/go-pglock/v3"
+)
+
+// FakeClock is a pglock.Clock whose time only moves when Advance is called, so timeouts, hold limits and watchdogs can be tested without sleeping.
+type FakeClock struct {
+	mu     sync.Mutex
+	now    time.Time
+	timers []*fakeTimer
+}
+
+var _ pglock.Clock = (*FakeClock)(nil)
+
+// NewFakeClock returns a FakeClock set to start.
+func NewFakeClock(start time.Time) *FakeClock {
+	return &FakeClock{now: start}
+}
+
+// Now returns the current fake time.
+func (c *FakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// Advance moves the fake time forward by d, firing the timers and tickers that expire on the way, in order.
+// Functions registered with AfterFunc are called synchronously by Advance.
+func (c *FakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	end := c.now.Add(d)
+	for {
+		t := c.next(end)
+		if t == nil {
+			break
+		}
+		c.now = t.when
+		if t.period > 0 {
+			t.when = t.when.Add(t.period)
+		} else {
+			t.stopped = true
+		}
+		f, ch, now := t.f, t.c, c.now
+		c.mu.Unlock()
+		if f != nil {
+			f()
+		} else {
+			// Like time.Ticker, drop the tick if the receiver is not keeping up.
+			select {
+			case ch <- now:
+			default:
+			}
+		}
+		c.mu.Lock()
+	}
+	c.now = end
+	c.mu.Unlock()
+}
+
+// next returns the earliest timer expiring at or before end, the caller must hold mu.
+func (c *FakeClock) next(end time.Time) *fakeTimer {
+	active := c.timers[:0]
+	for _, t := range c.timers {
+		if !t.stopped {
+			active = append(active, t)
+		}
+	}
+	c.timers = active
+	sort.SliceStable(c.timers, func(i, j int) bool { return c.timers[i].when.Before(c.timers[j].when) })
+	if len(c.timers) == 0 || c.timers[0].when.After(end) {
+		return nil
+	}
+	return c.timers[0]
+}
+
+func (c *FakeClock) add(t *fakeTimer) *fakeTimer {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	t.clock = c
+	t.when = c.now.Add(t.period + t.delay)
+	c.timers = append(c.timers, t)
+	return t
+}
+
+// NewTimer returns a timer firing after d of fake time.
+func (c *FakeClock) NewTimer(d time.Duration) pglock.Timer {
+	return c.add(&fakeTimer{c: make(chan time.Time, 1), delay: d})
+}
+
+// NewTicker returns a ticker firing every d of fake time.
+func (c *FakeClock) NewTicker(d time.Duration) pglock.Ticker {
+	if d <= 0 {
+		panic("pglocktest: non-positive interval for NewTicker")
+	}
+	return fakeTicker{c.add(&fakeTimer{c: make(chan time.Time, 1), period: d})}
+}
+
+// AfterFunc calls f from Advance once d of fake time has elapsed.
+func (c *FakeClock) AfterFunc(d time.Duration, f func()) pglock.Timer {
+	return c.add(&fakeTimer{f: f, delay: d})
+}
+
+type fakeTimer struct {
+	clock   *FakeClock
+	c       chan time.Time
+	f       func()
+	when    time.Time
+	delay   time.Duration
+	period  time.Duration
+	stopped bool
+}
+
+func (t *fakeTimer) C() <-chan time.Time {
+	if t.f != nil {
+		return nil
+	}
+	return t.c
+}
+
+func (t *fakeTimer) Stop() bool {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+	active := !t.stopped
+	t.stopped = true
+	return active
+}
+
+type fakeTicker struct {
+	*fakeTimer
+}
+
+func (t fakeTicker) Stop() {
+	t.fakeTimer.Stop()
+}
@@ -0,0 +1,85 @@
+package pglocktest
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/allisson/go-pglock/v3"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFakeClock(t *testing.T) {
+	start := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := NewFakeClock(start)
+	assert.Equal(t, start, clock.Now())
+
+	timer := clock.NewTimer(time.Second)
+	ticker := clock.NewTicker(400 * time.Millisecond)
+	calls := []time.Time{}
+	clock.AfterFunc(500*time.Millisecond, func() { calls = append(calls, clock.Now()) })
+	stopped := clock.AfterFunc(500*time.Millisecond, func() { t.Error("stopped timer fired") })
+	assert.True(t, stopped.Stop())
+	assert.False(t, stopped.Stop())
+	assert.Nil(t, stopped.C())
+
+	clock.Advance(450 * time.Millisecond)
+	assert.Equal(t, start.Add(450*time.Millisecond), clock.Now())
+	assert.Equal(t, start.Add(400*time.Millisecond), <-ticker.C())
+	assert.Len(t, timer.C(), 0)
+	assert.Len(t, calls, 0)
+
+	clock.Advance(600 * time.Millisecond)
+	assert.Equal(t, []time.Time{start.Add(500 * time.Millisecond)}, calls)
+	assert.Equal(t, start.Add(time.Second), <-timer.C())
+	// The 800ms tick is buffered, the 1200ms one is not due yet.
+	assert.Equal(t, start.Add(800*time.Millisecond), <-ticker.C())
+	assert.False(t, timer.Stop())
+	ticker.Stop()
+	clock.Advance(time.Second)
+	assert.Len(t, ticker.C(), 0)
+}
+
+func TestFakeClockWithWatchXactLocks(t *testing.T) {
+	db, err := sql.Open("postgres", "")
+	assert.Nil(t, err)
+	assert.Nil(t, db.Close())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	clock := NewFakeClock(time.Now())
+	warnings := make(chan pglock.XactLockWarning, 10)
+	go pglock.WatchXactLocks(ctx, db, time.Second, time.Hour, func(warning pglock.XactLockWarning) {
+		warnings <- warning
+	}, pglock.WithClock(clock))
+
+	// The polls follow the fake clock, a real hour would never pass.
+	assert.Eventually(t, func() bool {
+		clock.Advance(time.Hour)
+		return len(warnings) > 0
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.NotNil(t, (<-warnings).Err)
+}
+
+func TestFakeClockWithRunCanary(t *testing.T) {
+	db, err := sql.Open("postgres", "")
+	assert.Nil(t, err)
+	assert.Nil(t, db.Close())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	clock := NewFakeClock(time.Now())
+	results := make(chan pglock.CanaryResult, 10)
+	go pglock.RunCanary(ctx, db, int64(9), time.Hour, func(result pglock.CanaryResult) {
+		results <- result
+	}, pglock.WithClock(clock))
+
+	assert.Eventually(t, func() bool {
+		clock.Advance(time.Hour)
+		return len(results) > 0
+	}, 5*time.Second, 10*time.Millisecond)
+	result := <-results
+	assert.NotNil(t, result.Err)
+	assert.Equal(t, time.Duration(0), result.Total)
+}
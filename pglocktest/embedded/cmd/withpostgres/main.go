@@ -0,0 +1,69 @@
+// Command withpostgres runs a command with DATABASE_URL pointing at a throwaway embedded PostgreSQL server, so that
+// integration tests can run where neither Docker nor a database service is available. A DATABASE_URL already set is
+// passed on instead, and no server is started.
+//
+// Usage:
+//
+//	withpostgres [-dir directory] command [arguments]
+//
+// For example, from the pglocktest/embedded directory, to run the tests of the pglock module:
+//
+//	go run ./cmd/withpostgres -dir ../.. go test ./...
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+
+	"github.com/allisson/go-pglock/v3/pglocktest/embedded"
+)
+
+func main() {
+	dir := flag.String("dir", "", "directory the command runs in, the current directory when empty")
+	flag.Usage = func() {
+		fmt.Fprintln(flag.CommandLine.Output(), "usage: withpostgres [-dir directory] command [arguments]")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	os.Exit(run(*dir, flag.Args()))
+}
+
+// run runs args in dir with a database, and returns the exit code of the command.
+func run(dir string, args []string) int {
+	env := os.Environ()
+	if os.Getenv(embedded.URLEnv) == "" {
+		server, err := embedded.Start(embedded.Config{})
+		if err != nil {
+			log.Print(err)
+			return 1
+		}
+		defer func() {
+			if err := server.Stop(); err != nil {
+				log.Print(err)
+			}
+		}()
+		env = append(env, embedded.URLEnv+"="+server.DSN())
+	}
+
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Dir = dir
+	cmd.Env = env
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	if err := cmd.Run(); err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return exitErr.ExitCode()
+		}
+		log.Print(err)
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,17 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/allisson/go-pglock/v3/pglocktest/embedded"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunWithURL(t *testing.T) {
+	// A set URL is passed on without starting a server.
+	t.Setenv(embedded.URLEnv, "postgres://example")
+	assert.Equal(t, 0, run("", []string{"sh", "-c", `test "$DATABASE_URL" = postgres://example`}))
+	assert.Equal(t, 3, run("", []string{"sh", "-c", "exit 3"}))
+	assert.Equal(t, 0, run("/", []string{"sh", "-c", `test "$(pwd)" = /`}))
+	assert.Equal(t, 1, run("", []string{"/nonexistent/command"}))
+}
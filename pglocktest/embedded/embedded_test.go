@@ -0,0 +1,50 @@
+package embedded
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"testing"
+
+	_ "github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSetupKeepsURL(t *testing.T) {
+	t.Setenv(URLEnv, "postgres://example")
+	t.Setenv(EnableEnv, "1")
+
+	stop, err := Setup()
+	assert.Nil(t, err)
+	assert.Nil(t, stop())
+	assert.Equal(t, "postgres://example", os.Getenv(URLEnv))
+}
+
+func TestSetupDisabled(t *testing.T) {
+	t.Setenv(URLEnv, "")
+	t.Setenv(EnableEnv, "")
+
+	stop, err := Setup()
+	assert.Nil(t, err)
+	assert.Nil(t, stop())
+	assert.Equal(t, "", os.Getenv(URLEnv))
+}
+
+func TestStart(t *testing.T) {
+	if os.Getenv(EnableEnv) == "" {
+		t.Skip("set " + EnableEnv + " to start an embedded server")
+	}
+	server, err := Start(Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { assert.Nil(t, server.Stop()) }()
+
+	db, err := sql.Open("postgres", server.DSN())
+	assert.Nil(t, err)
+	defer db.Close()
+
+	var locked bool
+	assert.Nil(t, db.QueryRowContext(context.Background(), "SELECT pg_try_advisory_lock(1)").Scan(&locked))
+	assert.True(t, locked)
+}
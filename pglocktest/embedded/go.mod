@@ -0,0 +1,16 @@
+module github.com/allisson/go-pglock/v3/pglocktest/embedded
+
+go 1.17
+
+require (
+	github.com/fergusstrange/embedded-postgres v1.19.0
+	github.com/lib/pq v1.10.6
+	github.com/stretchr/testify v1.8.0
+)
+
+require (
+	github.com/davecgh/go-spew v1.1.1 // indirect
+	github.com/pmezard/go-difflib v1.0.0 // indirect
+	github.com/xi2/xz v0.0.0-20171230120015-48954b6210f8 // indirect
+	gopkg.in/yaml.v3 v3.0.1 // indirect
+)
@@ -0,0 +1,152 @@
+package pglocktest
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/allisson/go-pglock/v3"
+)
+
+// EventKind is the kind of an Event in a contention trace.
+type EventKind string
+
+const (
+	// Acquired is recorded when a contender obtained the lock.
+	Acquired EventKind = "acquired"
+	// Released is recorded right before a contender unlocks, while it still holds the lock.
+	Released EventKind = "released"
+	// Missed is recorded when a non waiting acquisition found the lock held.
+	Missed EventKind = "missed"
+	// Failed is recorded when a lock call returned an error.
+	Failed EventKind = "failed"
+)
+
+// Event is an entry of the ordered trace recorded by Run.
+type Event struct {
+	Seq       int
+	Contender string
+	Kind      EventKind
+	At        time.Time
+	Err       error
+}
+
+// Contender is a scripted participant of a contention test.
+type Contender struct {
+	// Name identifies the contender in the trace.
+	Name string
+	// Locker is the lock used by the contender, each contender needs its own session.
+	Locker pglock.Locker
+	// Iterations is how many times the contender tries to acquire the lock.
+	Iterations int
+	// Try makes the contender use Lock instead of WaitAndLock.
+	Try bool
+	// Work runs while the lock is held, it may be nil.
+	Work func(ctx context.Context)
+}
+
+// Run runs the contenders concurrently and returns the ordered trace of their lock events.
+func Run(ctx context.Context, contenders ...Contender) []Event {
+	trace := recorder{}
+	wg := sync.WaitGroup{}
+	for _, c := range contenders {
+		wg.Add(1)
+		go func(c Contender) {
+			defer wg.Done()
+			for i := 0; i < c.Iterations && ctx.Err() == nil; i++ {
+				acquired := true
+				var err error
+				if c.Try {
+					acquired, err = c.Locker.Lock(ctx)
+				} else {
+					err = c.Locker.WaitAndLock(ctx)
+				}
+				switch {
+				case err != nil:
+					trace.record(c.Name, Failed, err)
+					continue
+				case !acquired:
+					trace.record(c.Name, Missed, nil)
+					continue
+				}
+				trace.record(c.Name, Acquired, nil)
+				if c.Work != nil {
+					c.Work(ctx)
+				}
+				trace.record(c.Name, Released, nil)
+				if err := c.Locker.Unlock(ctx); err != nil {
+					trace.record(c.Name, Failed, err)
+				}
+			}
+		}(c)
+	}
+	wg.Wait()
+	return trace.events
+}
+
+type recorder struct {
+	mu     sync.Mutex
+	events []Event
+}
+
+func (r *recorder) record(contender string, kind EventKind, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, Event{Seq: len(r.events), Contender: contender, Kind: kind, At: time.Now(), Err: err})
+}
+
+// Acquisitions returns how many times each contender acquired the lock in trace.
+func Acquisitions(trace []Event) map[string]int {
+	counts := map[string]int{}
+	for _, event := range trace {
+		if event.Kind == Acquired {
+			counts[event.Contender]++
+		}
+	}
+	return counts
+}
+
+// AssertMutualExclusion asserts that no contender acquired the lock while another one held it.
+func AssertMutualExclusion(t testing.TB, trace []Event) bool {
+	t.Helper()
+	holder := ""
+	for _, event := range trace {
+		switch event.Kind {
+		case Acquired:
+			if holder != "" {
+				t.Errorf("pglocktest: %s acquired the lock at event %d while %s held it", event.Contender, event.Seq, holder)
+				return false
+			}
+			holder = event.Contender
+		case Released:
+			holder = ""
+		}
+	}
+	return true
+}
+
+// AssertNoFailures asserts that no lock call failed.
+func AssertNoFailures(t testing.TB, trace []Event) bool {
+	t.Helper()
+	for _, event := range trace {
+		if event.Kind == Failed {
+			t.Errorf("pglocktest: %s failed at event %d: %v", event.Contender, event.Seq, event.Err)
+			return false
+		}
+	}
+	return true
+}
+
+// AssertFair asserts that every contender in names acquired the lock at least min times.
+func AssertFair(t testing.TB, trace []Event, min int, names ...string) bool {
+	t.Helper()
+	counts := Acquisitions(trace)
+	for _, name := range names {
+		if counts[name] < min {
+			t.Errorf("pglocktest: %s acquired the lock %d times, expected at least %d (acquisitions: %v)", name, counts[name], min, counts)
+			return false
+		}
+	}
+	return true
+}
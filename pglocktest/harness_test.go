@@ -0,0 +1,70 @@
+package pglocktest
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/allisson/go-pglock/v3"
+	"github.com/allisson/go-pglock/v3/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestRunTrace(t *testing.T) {
+	locker := mocks.NewLocker(t)
+	locker.On("WaitAndLock", mock.Anything).Return(nil).Twice()
+	locker.On("Unlock", mock.Anything).Return(nil).Once()
+	locker.On("Unlock", mock.Anything).Return(errors.New("connection reset")).Once()
+	locker.On("Lock", mock.Anything).Return(false, nil).Once()
+
+	trace := Run(context.Background(),
+		Contender{Name: "waiter", Locker: locker, Iterations: 2},
+	)
+	trace = append(trace, Run(context.Background(), Contender{Name: "trier", Locker: locker, Iterations: 1, Try: true})...)
+
+	kinds := []EventKind{}
+	for _, event := range trace {
+		kinds = append(kinds, event.Kind)
+	}
+	assert.Equal(t, []EventKind{Acquired, Released, Acquired, Released, Failed, Missed}, kinds)
+	assert.Equal(t, map[string]int{"waiter": 2}, Acquisitions(trace))
+	assert.True(t, AssertMutualExclusion(t, trace))
+	assert.False(t, AssertNoFailures(&testing.T{}, trace))
+}
+
+func TestAssertMutualExclusion(t *testing.T) {
+	trace := []Event{
+		{Seq: 0, Contender: "a", Kind: Acquired},
+		{Seq: 1, Contender: "b", Kind: Acquired},
+	}
+	assert.False(t, AssertMutualExclusion(&testing.T{}, trace))
+}
+
+func TestRunContention(t *testing.T) {
+	db1, err := newDB()
+	assert.Nil(t, err)
+	defer closeDB(db1)
+	db2, err := newDB()
+	assert.Nil(t, err)
+	defer closeDB(db2)
+
+	ctx := context.Background()
+	id := int64(29)
+	lock1, err := pglock.NewLock(ctx, id, db1)
+	assert.Nil(t, err)
+	defer lock1.Close()
+	lock2, err := pglock.NewLock(ctx, id, db2)
+	assert.Nil(t, err)
+	defer lock2.Close()
+
+	work := func(ctx context.Context) { time.Sleep(10 * time.Millisecond) }
+	trace := Run(ctx,
+		Contender{Name: "a", Locker: &lock1, Iterations: 5, Work: work},
+		Contender{Name: "b", Locker: &lock2, Iterations: 5, Work: work},
+	)
+	AssertNoFailures(t, trace)
+	AssertMutualExclusion(t, trace)
+	AssertFair(t, trace, 5, "a", "b")
+}
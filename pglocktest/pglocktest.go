@@ -0,0 +1,98 @@
+// Package pglocktest provides helpers for integration tests that exercise
+// postgresql advisory locks against a live database.
+package pglocktest
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/allisson/go-pglock/v3"
+)
+
+// Holders returns the backend PIDs currently holding the session level advisory lock for id.
+// Both exclusive and shared holders are returned, waiters are not.
+func Holders(ctx context.Context, db *sql.DB, id int64) ([]int, error) {
+	return pglock.Holders(ctx, db, id)
+}
+
+// AssertHeld asserts that some session currently holds the advisory lock for id.
+func AssertHeld(t testing.TB, db *sql.DB, id int64) bool {
+	t.Helper()
+	pids, err := Holders(context.Background(), db, id)
+	if err != nil {
+		t.Errorf("pglocktest: could not inspect lock %d: %v", id, err)
+		return false
+	}
+	if len(pids) == 0 {
+		t.Errorf("pglocktest: expected lock %d to be held, but it is free", id)
+		return false
+	}
+	return true
+}
+
+// AssertHeldBy asserts that the session with the given backend PID currently holds the advisory lock for id.
+func AssertHeldBy(t testing.TB, db *sql.DB, id int64, pid int) bool {
+	t.Helper()
+	pids, err := Holders(context.Background(), db, id)
+	if err != nil {
+		t.Errorf("pglocktest: could not inspect lock %d: %v", id, err)
+		return false
+	}
+	for _, holder := range pids {
+		if holder == pid {
+			return true
+		}
+	}
+	t.Errorf("pglocktest: expected lock %d to be held by pid %d, but holders are %v", id, pid, pids)
+	return false
+}
+
+// AssertFree asserts that no session currently holds the advisory lock for id.
+func AssertFree(t testing.TB, db *sql.DB, id int64) bool {
+	t.Helper()
+	pids, err := Holders(context.Background(), db, id)
+	if err != nil {
+		t.Errorf("pglocktest: could not inspect lock %d: %v", id, err)
+		return false
+	}
+	if len(pids) > 0 {
+		t.Errorf("pglocktest: expected lock %d to be free, but it is held by %v", id, pids)
+		return false
+	}
+	return true
+}
+
+// TerminateSession kills the backend of the lock session with pg_terminate_backend, using a connection from db.
+// It is meant for resilience tests that need the lock to be lost deterministically.
+func TerminateSession(ctx context.Context, db *sql.DB, lock *pglock.Lock) error {
+	pid, err := lock.PID(ctx)
+	if err != nil {
+		return err
+	}
+	terminated := false
+	if err := db.QueryRowContext(ctx, "SELECT pg_terminate_backend($1)", pid).Scan(&terminated); err != nil {
+		return err
+	}
+	if !terminated {
+		return fmt.Errorf("pglocktest: could not terminate backend %d", pid)
+	}
+	// pg_terminate_backend only signals the backend, wait until it is gone.
+	for {
+		gone := false
+		sqlQuery := "SELECT NOT EXISTS (SELECT 1 FROM pg_stat_activity WHERE pid = $1)"
+		if err := db.QueryRowContext(ctx, sqlQuery, pid).Scan(&gone); err != nil {
+			return err
+		}
+		if gone {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package pglocktest
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/allisson/go-pglock/v3"
+	_ "github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+)
+
+func newDB() (*sql.DB, error) {
+	dsn := os.Getenv("DATABASE_URL")
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		return nil, err
+	}
+	return db, db.Ping()
+}
+
+func closeDB(db *sql.DB) {
+	if err := db.Close(); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func TestAssertHeldAndFree(t *testing.T) {
+	db1, err := newDB()
+	assert.Nil(t, err)
+	defer closeDB(db1)
+	db2, err := newDB()
+	assert.Nil(t, err)
+	defer closeDB(db2)
+
+	ctx := context.Background()
+	id := int64(-4294967297) // exercises both halves of the key
+	lock, err := pglock.NewLock(ctx, id, db1)
+	assert.Nil(t, err)
+	defer lock.Close()
+
+	AssertFree(t, db2, id)
+
+	ok, err := lock.Lock(ctx)
+	assert.True(t, ok)
+	assert.Nil(t, err)
+	AssertHeld(t, db2, id)
+	pid, err := lock.PID(ctx)
+	assert.Nil(t, err)
+	AssertHeldBy(t, db2, id, pid)
+
+	pids, err := Holders(ctx, db2, id)
+	assert.Nil(t, err)
+	assert.Len(t, pids, 1)
+
+	err = lock.Unlock(ctx)
+	assert.Nil(t, err)
+	AssertFree(t, db2, id)
+}
+
+func TestTerminateSession(t *testing.T) {
+	db1, err := newDB()
+	assert.Nil(t, err)
+	defer closeDB(db1)
+	db2, err := newDB()
+	assert.Nil(t, err)
+	defer closeDB(db2)
+
+	ctx := context.Background()
+	id := int64(20)
+	lock, err := pglock.NewLock(ctx, id, db1)
+	assert.Nil(t, err)
+	defer lock.Close()
+
+	err = lock.WaitAndLock(ctx)
+	assert.Nil(t, err)
+	AssertHeld(t, db2, id)
+
+	err = TerminateSession(ctx, db2, &lock)
+	assert.Nil(t, err)
+	AssertFree(t, db2, id)
+
+	err = lock.Unlock(ctx)
+	assert.NotNil(t, err)
+	assert.Equal(t, pglock.Lost, lock.Status())
+}
+
+func TestFakeClockWithMaxHold(t *testing.T) {
+	db, err := newDB()
+	assert.Nil(t, err)
+	defer closeDB(db)
+
+	ctx := context.Background()
+	id := int64(28)
+	clock := NewFakeClock(time.Now())
+	violations := 0
+	lock, err := pglock.NewLock(ctx, id, db, pglock.WithClock(clock), pglock.WithMaxHold(time.Hour, true, func(pglock.HoldViolation) {
+		violations++
+	}))
+	assert.Nil(t, err)
+	defer lock.Close()
+
+	assert.Nil(t, lock.WaitAndLock(ctx))
+	clock.Advance(59 * time.Minute)
+	assert.Equal(t, 0, violations)
+	AssertHeld(t, db, id)
+
+	clock.Advance(time.Minute)
+	assert.Equal(t, 1, violations)
+	AssertFree(t, db, id)
+}
@@ -0,0 +1,71 @@
+package pglock
+
+import (
+	"context"
+)
+
+// purposeSetting is the custom setting that stores the purpose of the latest acquisition.
+const purposeSetting = "pglock.purpose"
+
+type purposeKey struct{}
+
+// WithPurpose returns a copy of ctx carrying a short purpose string for lock acquisitions.
+// When Lock or WaitAndLock are called with this ctx, the purpose is published on the lock session before acquiring:
+// in its application_name, encoded with the codec of WithApplicationName or CompactAppNameCodec, so DBAs see it in
+// pg_stat_activity, and in the pglock.purpose setting, read with current_setting('pglock.purpose') on the session,
+// for example from server side functions. Both are cleared when the lock stops being held, or when the acquisition fails.
+// The correlation id of WithCorrelationID is published in the application_name the same way, with or without a purpose.
+func WithPurpose(ctx context.Context, purpose string) context.Context {
+	return context.WithValue(ctx, purposeKey{}, purpose)
+}
+
+// purposeFromContext returns the purpose carried by ctx, if any.
+func purposeFromContext(ctx context.Context) (string, bool) {
+	purpose, ok := ctx.Value(purposeKey{}).(string)
+	return purpose, ok
+}
+
+// setPurpose publishes the purpose and the correlation id carried by ctx on the session.
+func (l *Lock) setPurpose(ctx context.Context) error {
+	purpose, ok := purposeFromContext(ctx)
+	correlationID := l.correlationID(ctx)
+	if !ok && correlationID == "" {
+		return nil
+	}
+	sqlQuery := "SELECT current_setting('application_name'), set_config($1, $2, false), set_config('application_name', $3, false)"
+	var previous, setting, name string
+	if err := l.conn.QueryRowContext(ctx, sqlQuery, purposeSetting, purpose, l.applicationName(purpose, correlationID)).Scan(&previous, &setting, &name); err != nil {
+		return err
+	}
+	l.state.mu.Lock()
+	if !l.state.purpose {
+		l.state.purpose = true
+		l.state.appName = previous
+	}
+	l.state.mu.Unlock()
+	return nil
+}
+
+// clearPurpose removes the published purpose from the session if the lock is not held, restoring its application_name.
+func (l *Lock) clearPurpose(ctx context.Context) error {
+	l.state.mu.Lock()
+	clear := l.state.purpose && !l.state.state.held()
+	if clear {
+		l.state.purpose = false
+	}
+	appName := l.state.appName
+	l.state.mu.Unlock()
+	if !clear {
+		return nil
+	}
+	sqlQuery := "SELECT set_config($1, '', false), set_config('application_name', $2, false)"
+	_, err := l.conn.ExecContext(ctx, sqlQuery, purposeSetting, appName)
+	return err
+}
+
+// Purpose returns the purpose published on the lock session by the current acquisition, or an empty string.
+func (l *Lock) Purpose(ctx context.Context) (string, error) {
+	purpose := ""
+	err := l.conn.QueryRowContext(ctx, "SELECT coalesce(current_setting($1, true), '')", purposeSetting).Scan(&purpose)
+	return purpose, err
+}
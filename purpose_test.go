@@ -0,0 +1,62 @@
+package pglock
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPurpose(t *testing.T) {
+	db1, err := newDB()
+	assert.Nil(t, err)
+	defer closeDB(db1)
+	db2, err := newDB()
+	assert.Nil(t, err)
+	defer closeDB(db2)
+
+	ctx := context.Background()
+	id := int64(7)
+	lock, err := NewLock(ctx, id, db1, WithSessionSetup("SET application_name = 'billing'"))
+	assert.Nil(t, err)
+	defer lock.Close()
+
+	purpose, err := lock.Purpose(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, "", purpose)
+
+	err = lock.WaitAndLock(WithPurpose(ctx, "nightly invoice run"))
+	assert.Nil(t, err)
+	purpose, err = lock.Purpose(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, "nightly invoice run", purpose)
+
+	ok, err := lock.Lock(WithPurpose(ctx, "retry"))
+	assert.True(t, ok)
+	assert.Nil(t, err)
+	purpose, err = lock.Purpose(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, "retry", purpose)
+
+	// Other sessions see the purpose in pg_stat_activity.
+	sessions, err := HolderSessions(ctx, db2, id)
+	assert.Nil(t, err)
+	if assert.Len(t, sessions, 1) {
+		info, ok := sessions[0].Info(nil)
+		assert.True(t, ok)
+		assert.Equal(t, HolderInfo{ID: id, Purpose: "retry"}, info)
+	}
+
+	assert.Nil(t, lock.Unlock(ctx))
+	purpose, err = lock.Purpose(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, "retry", purpose)
+	assert.Nil(t, lock.Unlock(ctx))
+
+	// The purpose is cleared when the lock stops being held.
+	applicationName := ""
+	err = lock.conn.QueryRowContext(ctx, "SELECT current_setting('application_name'), current_setting('pglock.purpose')").Scan(&applicationName, &purpose)
+	assert.Nil(t, err)
+	assert.Equal(t, "billing", applicationName)
+	assert.Equal(t, "", purpose)
+}
@@ -0,0 +1,80 @@
+package pglock
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// FixedWindowLimiter is a cluster wide fixed window rate limiter.
+// Counters are stored per key in a table, and updates to a key are serialized with a transaction level advisory lock.
+type FixedWindowLimiter struct {
+	db    *sql.DB
+	table string
+	ns    Namespace
+}
+
+// NewFixedWindowLimiter returns a FixedWindowLimiter that stores its counters in table, see FixedWindowLimiterTable.
+func NewFixedWindowLimiter(ctx context.Context, db *sql.DB, table string) (*FixedWindowLimiter, error) {
+	name, err := openTable(ctx, db, FixedWindowLimiterTable(table))
+	if err != nil {
+		return nil, err
+	}
+	return &FixedWindowLimiter{db: db, table: name, ns: NS("pglock").NS("ratelimit").NS(table)}, nil
+}
+
+// Allow reports whether one more event for key fits in the current window, and counts it if so.
+// Windows are aligned to the server clock, so all processes agree on when a window starts.
+func (r *FixedWindowLimiter) Allow(ctx context.Context, key string, limit int, window time.Duration) (bool, error) {
+	if limit <= 0 {
+		return false, nil
+	}
+	if window < time.Millisecond {
+		return false, errors.New("pglock: rate limit window must be at least one millisecond")
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if _, err := tx.ExecContext(ctx, "SELECT pg_advisory_xact_lock($1)", r.ns.Key(key).ID); err != nil {
+		return false, err
+	}
+
+	// clock_timestamp, unlike now, is not frozen at the start of the transaction, which may be before the lock was granted.
+	// A caller that waited for the lock across a window boundary would otherwise count in an older window.
+	windowStart := int64(0)
+	sqlQuery := "SELECT (floor(extract(epoch FROM clock_timestamp()) * 1000 / $1::bigint) * $1::bigint)::bigint"
+	if err := tx.QueryRowContext(ctx, sqlQuery, window.Milliseconds()).Scan(&windowStart); err != nil {
+		return false, err
+	}
+
+	currentStart, count := int64(0), 0
+	sqlQuery = fmt.Sprintf("SELECT window_start, count FROM %s WHERE key = $1", r.table)
+	err = tx.QueryRowContext(ctx, sqlQuery, key).Scan(&currentStart, &count)
+	switch {
+	case errors.Is(err, sql.ErrNoRows) || (err == nil && currentStart < windowStart):
+		count = 0
+	case err != nil:
+		return false, err
+	case currentStart > windowStart:
+		// The stored window is newer, for example after the server clock stepped back, count the event in it
+		// instead of resetting its counter.
+		windowStart = currentStart
+	}
+	if count >= limit {
+		return false, nil
+	}
+
+	sqlQuery = fmt.Sprintf(`INSERT INTO %s (key, window_start, count) VALUES ($1, $2, $3)
+		ON CONFLICT (key) DO UPDATE SET window_start = EXCLUDED.window_start, count = EXCLUDED.count
+		WHERE %s.window_start <= EXCLUDED.window_start`, r.table, r.table)
+	if _, err := tx.ExecContext(ctx, sqlQuery, key, windowStart, count+1); err != nil {
+		return false, err
+	}
+	return true, tx.Commit()
+}
@@ -0,0 +1,61 @@
+package pglock
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFixedWindowLimiter(t *testing.T) {
+	db, err := newDB()
+	assert.Nil(t, err)
+	defer closeDB(db)
+
+	ctx := context.Background()
+	assert.Nil(t, Migrate(ctx, db, FixedWindowLimiterTable("pglock_test_fixed_window")))
+	limiter, err := NewFixedWindowLimiter(ctx, db, "pglock_test_fixed_window")
+	assert.Nil(t, err)
+	defer func() {
+		_, err := db.ExecContext(ctx, "DROP TABLE pglock_test_fixed_window")
+		assert.Nil(t, err)
+	}()
+
+	window := time.Hour
+	for i := 0; i < 3; i++ {
+		ok, err := limiter.Allow(ctx, "external-api", 3, window)
+		assert.Nil(t, err)
+		assert.True(t, ok)
+	}
+	ok, err := limiter.Allow(ctx, "external-api", 3, window)
+	assert.Nil(t, err)
+	assert.False(t, ok)
+
+	ok, err = limiter.Allow(ctx, "other-api", 3, window)
+	assert.Nil(t, err)
+	assert.True(t, ok)
+
+	// A newer window is never reset by a caller computing an older one.
+	next := time.Now().Add(window).UnixMilli() / window.Milliseconds() * window.Milliseconds()
+	_, err = db.ExecContext(ctx, "UPDATE pglock_test_fixed_window SET window_start = $1, count = 2 WHERE key = 'other-api'", next)
+	assert.Nil(t, err)
+	ok, err = limiter.Allow(ctx, "other-api", 3, window)
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	ok, err = limiter.Allow(ctx, "other-api", 3, window)
+	assert.Nil(t, err)
+	assert.False(t, ok)
+	var start int64
+	count := 0
+	err = db.QueryRowContext(ctx, "SELECT window_start, count FROM pglock_test_fixed_window WHERE key = 'other-api'").Scan(&start, &count)
+	assert.Nil(t, err)
+	assert.Equal(t, next, start)
+	assert.Equal(t, 3, count)
+}
+
+func TestQuoteIdentifier(t *testing.T) {
+	assert.Equal(t, `"limits"`, quoteIdentifier("limits"))
+	assert.Equal(t, `"app"."limits"`, quoteIdentifier("app.limits"))
+	assert.Equal(t, `"a""b"`, quoteIdentifier(`a"b`))
+}
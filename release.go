@@ -0,0 +1,65 @@
+package pglock
+
+import (
+	"context"
+	"sync"
+)
+
+// Release releases the acquisition it was returned for, in the mode it was acquired in.
+// It is idempotent: once a call succeeds, later calls do nothing and return nil.
+type Release func(ctx context.Context) error
+
+// Acquire obtains the exclusive lock like WaitAndLock and returns a Release bound to that acquisition.
+func (l *Lock) Acquire(ctx context.Context) (Release, error) {
+	if err := l.WaitAndLock(ctx); err != nil {
+		return nil, err
+	}
+	return l.release(false), nil
+}
+
+// TryAcquire obtains the exclusive lock like Lock and returns a Release bound to that acquisition.
+// The Release is nil if the lock could not be acquired immediately.
+func (l *Lock) TryAcquire(ctx context.Context) (Release, bool, error) {
+	ok, err := l.Lock(ctx)
+	if !ok || err != nil {
+		return nil, ok, err
+	}
+	return l.release(false), true, nil
+}
+
+// RAcquire obtains the shared lock like WaitAndRLock and returns a Release bound to that acquisition.
+func (l *Lock) RAcquire(ctx context.Context) (Release, error) {
+	if err := l.WaitAndRLock(ctx); err != nil {
+		return nil, err
+	}
+	return l.release(true), nil
+}
+
+// TryRAcquire obtains the shared lock like RLock and returns a Release bound to that acquisition.
+// The Release is nil if the lock could not be acquired immediately.
+func (l *Lock) TryRAcquire(ctx context.Context) (Release, bool, error) {
+	ok, err := l.RLock(ctx)
+	if !ok || err != nil {
+		return nil, ok, err
+	}
+	return l.release(true), true, nil
+}
+
+// release returns a Release that unlocks one acquisition in the given mode at most once.
+// A failed unlock can be retried, since the acquisition may still be held.
+func (l *Lock) release(shared bool) Release {
+	var mu sync.Mutex
+	released := false
+	return func(ctx context.Context) error {
+		mu.Lock()
+		defer mu.Unlock()
+		if released {
+			return nil
+		}
+		if err := l.unlock(ctx, shared); err != nil {
+			return err
+		}
+		released = true
+		return nil
+	}
+}
@@ -0,0 +1,51 @@
+package pglock
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAcquireRelease(t *testing.T) {
+	db1, err := newDB()
+	assert.Nil(t, err)
+	defer closeDB(db1)
+	db2, err := newDB()
+	assert.Nil(t, err)
+	defer closeDB(db2)
+
+	ctx := context.Background()
+	id := int64(33)
+	lock1, err := NewLock(ctx, id, db1)
+	assert.Nil(t, err)
+	defer lock1.Close()
+	lock2, err := NewLock(ctx, id, db2)
+	assert.Nil(t, err)
+	defer lock2.Close()
+
+	release, err := lock1.Acquire(ctx)
+	assert.Nil(t, err)
+	release2, ok, err := lock2.TryAcquire(ctx)
+	assert.Nil(t, release2)
+	assert.False(t, ok)
+	assert.Nil(t, err)
+
+	// Releasing twice only unlocks once, so a stacked acquisition stays held.
+	stacked, err := lock1.Acquire(ctx)
+	assert.Nil(t, err)
+	assert.Nil(t, release(ctx))
+	assert.Nil(t, release(ctx))
+	assert.Equal(t, 1, lock1.Describe().Depth)
+	assert.Nil(t, stacked(ctx))
+
+	rrelease1, ok, err := lock1.TryRAcquire(ctx)
+	assert.True(t, ok)
+	assert.Nil(t, err)
+	rrelease2, err := lock2.RAcquire(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, lock2.Describe().SharedDepth)
+	assert.Nil(t, rrelease1(ctx))
+	assert.Nil(t, rrelease2(ctx))
+	assert.Equal(t, Idle, lock2.Status())
+}
@@ -0,0 +1,70 @@
+package pglock
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ReleaseHints lets lock holders publish when they expect to release a lock, so waiters polling with Lock or
+// TryLockOrError can sleep until then instead of retrying in lockstep. Hints are stored per lock id in a table
+// and expire on their own, times are taken from the server clock so all processes agree on them.
+type ReleaseHints struct {
+	db    *sql.DB
+	table string
+}
+
+// NewReleaseHints returns a ReleaseHints that stores its hints in table, see ReleaseHintsTable.
+func NewReleaseHints(ctx context.Context, db *sql.DB, table string) (*ReleaseHints, error) {
+	name, err := openTable(ctx, db, ReleaseHintsTable(table))
+	if err != nil {
+		return nil, err
+	}
+	return &ReleaseHints{db: db, table: name}, nil
+}
+
+// Publish records that the holder of id expects to release it within d.
+func (h *ReleaseHints) Publish(ctx context.Context, id int64, d time.Duration) error {
+	sqlQuery := fmt.Sprintf(`INSERT INTO %s (id, release_at) VALUES ($1, clock_timestamp() + $2 * interval '1 microsecond')
+		ON CONFLICT (id) DO UPDATE SET release_at = EXCLUDED.release_at`, h.table)
+	_, err := h.db.ExecContext(ctx, sqlQuery, id, d.Microseconds())
+	return err
+}
+
+// Clear removes the hint of id, typically right before releasing the lock.
+func (h *ReleaseHints) Clear(ctx context.Context, id int64) error {
+	_, err := h.db.ExecContext(ctx, fmt.Sprintf("DELETE FROM %s WHERE id = $1", h.table), id)
+	return err
+}
+
+// RetryAfter returns how long until the holder of id expects to release it, zero if there is no live hint.
+func (h *ReleaseHints) RetryAfter(ctx context.Context, id int64) (time.Duration, error) {
+	sqlQuery := fmt.Sprintf(`SELECT greatest(extract(epoch FROM release_at - clock_timestamp()), 0)
+		FROM %s WHERE id = $1`, h.table)
+	seconds := float64(0)
+	err := h.db.QueryRowContext(ctx, sqlQuery, id).Scan(&seconds)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, nil
+	}
+	return time.Duration(seconds * float64(time.Second)), err
+}
+
+// WithReleaseHints makes TryLockOrError report the release hint published for the lock id in AlreadyProcessingError.RetryAfter,
+// falling back to WithRetryAfterHint when there is no live hint.
+func WithReleaseHints(hints *ReleaseHints) Option {
+	return func(l *Lock) {
+		l.releaseHints = hints
+	}
+}
+
+// retryAfterHint returns the RetryAfter hint of the lock, on a best effort basis.
+func (l *Lock) retryAfterHint(ctx context.Context) time.Duration {
+	if l.releaseHints != nil {
+		if d, err := l.releaseHints.RetryAfter(ctx, l.id); err == nil && d > 0 {
+			return d
+		}
+	}
+	return l.retryAfter
+}
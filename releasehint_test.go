@@ -0,0 +1,62 @@
+package pglock
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReleaseHints(t *testing.T) {
+	db1, err := newDB()
+	assert.Nil(t, err)
+	defer closeDB(db1)
+	db2, err := newDB()
+	assert.Nil(t, err)
+	defer closeDB(db2)
+
+	ctx := context.Background()
+	assert.Nil(t, Migrate(ctx, db1, ReleaseHintsTable("pglock_test_release_hints")))
+	hints, err := NewReleaseHints(ctx, db1, "pglock_test_release_hints")
+	assert.Nil(t, err)
+	defer func() {
+		_, err := db1.ExecContext(ctx, "DROP TABLE pglock_test_release_hints")
+		assert.Nil(t, err)
+	}()
+
+	id := int64(39)
+	lock1, err := NewLock(ctx, id, db1)
+	assert.Nil(t, err)
+	defer lock1.Close()
+	lock2, err := NewLock(ctx, id, db2, WithReleaseHints(hints), WithRetryAfterHint(time.Second))
+	assert.Nil(t, err)
+	defer lock2.Close()
+
+	ok, err := lock1.Lock(ctx)
+	assert.True(t, ok)
+	assert.Nil(t, err)
+
+	var processing *AlreadyProcessingError
+	assert.True(t, errors.As(lock2.TryLockOrError(ctx), &processing))
+	assert.Equal(t, time.Second, processing.RetryAfter)
+
+	assert.Nil(t, hints.Publish(ctx, id, time.Hour))
+	retryAfter, err := hints.RetryAfter(ctx, id)
+	assert.Nil(t, err)
+	assert.InDelta(t, time.Hour, retryAfter, float64(time.Minute))
+	assert.True(t, errors.As(lock2.TryLockOrError(ctx), &processing))
+	assert.InDelta(t, time.Hour, processing.RetryAfter, float64(time.Minute))
+
+	assert.Nil(t, hints.Clear(ctx, id))
+	retryAfter, err = hints.RetryAfter(ctx, id)
+	assert.Nil(t, err)
+	assert.Zero(t, retryAfter)
+
+	assert.Nil(t, hints.Publish(ctx, id, -time.Second))
+	retryAfter, err = hints.RetryAfter(ctx, id)
+	assert.Nil(t, err)
+	assert.Zero(t, retryAfter)
+	assert.Nil(t, lock1.Unlock(ctx))
+}
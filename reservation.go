@@ -0,0 +1,131 @@
+package pglock
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// ErrReserved is matched by errors.Is for a *ReservedError.
+var ErrReserved = errors.New("pglock: lock reserved")
+
+// ReservedError is returned when a lock is reserved by another owner for the current time, or when a reservation
+// overlaps one of another owner.
+type ReservedError struct {
+	// Reservation is the conflicting reservation.
+	Reservation Reservation
+}
+
+// Error implements the error interface.
+func (e *ReservedError) Error() string {
+	r := e.Reservation
+	return fmt.Sprintf("pglock: lock %d is reserved by %q from %s to %s", r.ID, r.Owner, r.Start.Format(time.RFC3339), r.End.Format(time.RFC3339))
+}
+
+// Is reports whether target is ErrReserved.
+func (e *ReservedError) Is(target error) bool {
+	return target == ErrReserved
+}
+
+// Reservation reserves a lock id for an owner during a time window.
+type Reservation struct {
+	ID    int64     `json:"id"`
+	Owner string    `json:"owner"`
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+}
+
+// Reservations stores reservations of lock ids for future time windows, for example planned maintenance on a
+// contended resource. Locks created with WithReservations refuse to acquire a reserved id during the window unless
+// they belong to the owner. Reservations are stored in a table and compared with the server clock.
+type Reservations struct {
+	db    *sql.DB
+	table string
+	ns    Namespace
+}
+
+// NewReservations returns a Reservations that stores its reservations in table, see ReservationsTable.
+func NewReservations(ctx context.Context, db *sql.DB, table string) (*Reservations, error) {
+	name, err := openTable(ctx, db, ReservationsTable(table))
+	if err != nil {
+		return nil, err
+	}
+	return &Reservations{db: db, table: name, ns: NS("pglock").NS("reservation").NS(table)}, nil
+}
+
+// Reserve reserves id for owner from start to end. It returns a *ReservedError if the window overlaps a
+// reservation of another owner.
+func (r *Reservations) Reserve(ctx context.Context, id int64, owner string, start, end time.Time) error {
+	if !end.After(start) {
+		return errors.New("pglock: reservation must end after it starts")
+	}
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if _, err := tx.ExecContext(ctx, "SELECT pg_advisory_xact_lock($1)", r.ns.Key(strconv.FormatInt(id, 10)).ID); err != nil {
+		return err
+	}
+	sqlQuery := fmt.Sprintf(`SELECT owner, starts_at, ends_at FROM %s
+		WHERE id = $1 AND owner <> $2 AND starts_at < $4 AND ends_at > $3 ORDER BY starts_at LIMIT 1`, r.table)
+	conflict := Reservation{ID: id}
+	err = tx.QueryRowContext(ctx, sqlQuery, id, owner, start, end).Scan(&conflict.Owner, &conflict.Start, &conflict.End)
+	switch {
+	case err == nil:
+		return &ReservedError{Reservation: conflict}
+	case !errors.Is(err, sql.ErrNoRows):
+		return err
+	}
+
+	sqlQuery = fmt.Sprintf("INSERT INTO %s (id, owner, starts_at, ends_at) VALUES ($1, $2, $3, $4)", r.table)
+	if _, err := tx.ExecContext(ctx, sqlQuery, id, owner, start, end); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Cancel removes the reservations of id held by owner.
+func (r *Reservations) Cancel(ctx context.Context, id int64, owner string) error {
+	sqlQuery := fmt.Sprintf("DELETE FROM %s WHERE id = $1 AND owner = $2", r.table)
+	_, err := r.db.ExecContext(ctx, sqlQuery, id, owner)
+	return err
+}
+
+// Active returns the reservation of id covering the current server time, if any.
+func (r *Reservations) Active(ctx context.Context, id int64) (Reservation, bool, error) {
+	sqlQuery := fmt.Sprintf(`SELECT owner, starts_at, ends_at FROM %s
+		WHERE id = $1 AND starts_at <= clock_timestamp() AND ends_at > clock_timestamp() ORDER BY starts_at LIMIT 1`, r.table)
+	reservation := Reservation{ID: id}
+	err := r.db.QueryRowContext(ctx, sqlQuery, id).Scan(&reservation.Owner, &reservation.Start, &reservation.End)
+	if errors.Is(err, sql.ErrNoRows) {
+		return reservation, false, nil
+	}
+	return reservation, err == nil, err
+}
+
+// WithReservations makes lock acquisitions fail with a *ReservedError while the lock id is reserved by an owner
+// other than owner. The reservation is checked before acquiring, a waiting acquisition is not interrupted by a
+// window that starts while it waits.
+func WithReservations(reservations *Reservations, owner string) Option {
+	return func(l *Lock) {
+		l.reservations = reservations
+		l.reservationOwner = owner
+	}
+}
+
+// checkReservation returns a *ReservedError if the lock id is reserved by another owner.
+func (l *Lock) checkReservation(ctx context.Context) error {
+	if l.reservations == nil {
+		return nil
+	}
+	reservation, ok, err := l.reservations.Active(ctx, l.id)
+	if err != nil || !ok || reservation.Owner == l.reservationOwner {
+		return err
+	}
+	return &ReservedError{Reservation: reservation}
+}
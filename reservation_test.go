@@ -0,0 +1,63 @@
+package pglock
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReservedError(t *testing.T) {
+	start := time.Date(2024, 1, 2, 3, 0, 0, 0, time.UTC)
+	err := error(&ReservedError{Reservation: Reservation{ID: 7, Owner: "maintenance", Start: start, End: start.Add(time.Hour)}})
+	assert.True(t, errors.Is(err, ErrReserved))
+	assert.Equal(t, `pglock: lock 7 is reserved by "maintenance" from 2024-01-02T03:00:00Z to 2024-01-02T04:00:00Z`, err.Error())
+}
+
+func TestReservations(t *testing.T) {
+	db, err := newDB()
+	assert.Nil(t, err)
+	defer closeDB(db)
+
+	ctx := context.Background()
+	assert.Nil(t, Migrate(ctx, db, ReservationsTable("pglock_test_reservations")))
+	reservations, err := NewReservations(ctx, db, "pglock_test_reservations")
+	assert.Nil(t, err)
+	defer func() {
+		_, err := db.ExecContext(ctx, "DROP TABLE pglock_test_reservations")
+		assert.Nil(t, err)
+	}()
+
+	id := int64(42)
+	now := time.Now()
+	assert.NotNil(t, reservations.Reserve(ctx, id, "maintenance", now, now))
+	assert.Nil(t, reservations.Reserve(ctx, id, "maintenance", now.Add(-time.Minute), now.Add(time.Hour)))
+	err = reservations.Reserve(ctx, id, "other", now.Add(30*time.Minute), now.Add(2*time.Hour))
+	assert.True(t, errors.Is(err, ErrReserved))
+	assert.Nil(t, reservations.Reserve(ctx, id, "other", now.Add(time.Hour), now.Add(2*time.Hour)))
+
+	worker, err := NewLock(ctx, id, db, WithReservations(reservations, "worker"))
+	assert.Nil(t, err)
+	defer worker.Close()
+	maintenance, err := NewLock(ctx, id, db, WithReservations(reservations, "maintenance"))
+	assert.Nil(t, err)
+	defer maintenance.Close()
+
+	ok, err := worker.Lock(ctx)
+	assert.False(t, ok)
+	assert.True(t, errors.Is(err, ErrReserved))
+	assert.True(t, errors.Is(worker.WaitAndLock(ctx), ErrReserved))
+	assert.Nil(t, maintenance.WaitAndLock(ctx))
+	assert.Nil(t, maintenance.Unlock(ctx))
+
+	assert.Nil(t, reservations.Cancel(ctx, id, "maintenance"))
+	_, active, err := reservations.Active(ctx, id)
+	assert.Nil(t, err)
+	assert.False(t, active)
+	ok, err = worker.Lock(ctx)
+	assert.True(t, ok)
+	assert.Nil(t, err)
+	assert.Nil(t, worker.Unlock(ctx))
+}
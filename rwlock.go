@@ -0,0 +1,44 @@
+package pglock
+
+import (
+	"context"
+)
+
+// RWLocker is an interface for postgresql advisory locks with shared (read) and exclusive (write) modes.
+type RWLocker interface {
+	Locker
+	RLock(ctx context.Context) (bool, error)
+	WaitAndRLock(ctx context.Context) error
+	RUnlock(ctx context.Context) error
+}
+
+// RLock obtains shared session level advisory lock if available.
+// Shared holders do not block each other, only exclusive ones do.
+// It will either obtain the lock and return true, or return false if the lock cannot be acquired immediately.
+func (l *Lock) RLock(ctx context.Context) (bool, error) {
+	result, err := l.RLockWithResult(ctx)
+	return result.Acquired, err
+}
+
+// RLockWithResult is like RLock, but returns an AcquireResult with a timing breakdown of the acquisition.
+func (l *Lock) RLockWithResult(ctx context.Context) (AcquireResult, error) {
+	return l.lockWithResult(ctx, true)
+}
+
+// WaitAndRLock obtains shared session level advisory lock.
+// If another session holds the lock in exclusive mode, this function will wait until it is released.
+// Like exclusive requests, shared requests stack and must be unlocked as many times as they were locked.
+func (l *Lock) WaitAndRLock(ctx context.Context) error {
+	_, err := l.WaitAndRLockWithResult(ctx)
+	return err
+}
+
+// WaitAndRLockWithResult is like WaitAndRLock, but returns an AcquireResult with a timing breakdown of the acquisition.
+func (l *Lock) WaitAndRLockWithResult(ctx context.Context) (AcquireResult, error) {
+	return l.waitAndLockWithResult(ctx, true)
+}
+
+// RUnlock releases the shared lock.
+func (l *Lock) RUnlock(ctx context.Context) error {
+	return l.unlock(ctx, true)
+}
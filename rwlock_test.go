@@ -0,0 +1,63 @@
+package pglock
+
+import (
+	"context"
+	"testing"
+
+	"github.com/allisson/go-pglock/v3/mocks"
+	"github.com/stretchr/testify/assert"
+)
+
+// Lock and the mocks must satisfy the interfaces.
+var (
+	_ RWLocker = (*Lock)(nil)
+	_ RWLocker = (*mocks.RWLocker)(nil)
+	_ Locker   = (*mocks.Locker)(nil)
+)
+
+func TestRLock(t *testing.T) {
+	db1, err := newDB()
+	assert.Nil(t, err)
+	defer closeDB(db1)
+	db2, err := newDB()
+	assert.Nil(t, err)
+	defer closeDB(db2)
+	db3, err := newDB()
+	assert.Nil(t, err)
+	defer closeDB(db3)
+
+	ctx := context.Background()
+	id := int64(22)
+	reader1, err := NewLock(ctx, id, db1)
+	assert.Nil(t, err)
+	defer reader1.Close()
+	reader2, err := NewLock(ctx, id, db2)
+	assert.Nil(t, err)
+	defer reader2.Close()
+	writer, err := NewLock(ctx, id, db3)
+	assert.Nil(t, err)
+	defer writer.Close()
+
+	ok, err := reader1.RLock(ctx)
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, HeldShared, reader1.Status())
+	err = reader2.WaitAndRLock(ctx)
+	assert.Nil(t, err)
+
+	ok, err = writer.Lock(ctx)
+	assert.Nil(t, err)
+	assert.False(t, ok)
+
+	assert.Nil(t, reader1.RUnlock(ctx))
+	assert.Equal(t, Idle, reader1.Status())
+	assert.Nil(t, reader2.RUnlock(ctx))
+
+	ok, err = writer.Lock(ctx)
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	ok, err = reader1.RLock(ctx)
+	assert.Nil(t, err)
+	assert.False(t, ok)
+	assert.Nil(t, writer.Unlock(ctx))
+}
@@ -0,0 +1,162 @@
+package pglock
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Engines reported in ServerInfo.Engine.
+const (
+	EnginePostgres    = "postgres"
+	EngineAurora      = "aurora"
+	EngineYugabyteDB  = "yugabytedb"
+	EngineCockroachDB = "cockroachdb"
+)
+
+const (
+	// minServerVersion is the oldest server supported, WaitAndLock deadlines rely on lock_timeout (9.3).
+	minServerVersion = 90300
+	// waitEventsVersion is the first server with pg_blocking_pids and the pg_stat_activity wait_event columns (9.6).
+	waitEventsVersion = 90600
+)
+
+// ErrUnsupportedServer is matched by errors.Is for an *UnsupportedServerError.
+var ErrUnsupportedServer = errors.New("pglock: unsupported server")
+
+// UnsupportedServerError is returned when the server is too old for a feature.
+type UnsupportedServerError struct {
+	// Engine is the detected engine, see ServerInfo.Engine.
+	Engine string
+	// Version is the server_version_num of the server, for example 90500.
+	Version int
+	// Feature is what the server lacks.
+	Feature string
+	// Required is the oldest server_version_num providing the feature, zero if no version of the engine does.
+	Required int
+}
+
+// Error implements the error interface.
+func (e *UnsupportedServerError) Error() string {
+	if e.Required == 0 {
+		return fmt.Sprintf("pglock: %s server does not support %s", e.Engine, e.Feature)
+	}
+	return fmt.Sprintf("pglock: server version %d does not support %s, version %d or newer is required", e.Version, e.Feature, e.Required)
+}
+
+// Is reports whether target is ErrUnsupportedServer.
+func (e *UnsupportedServerError) Is(target error) bool {
+	return target == ErrUnsupportedServer
+}
+
+// ServerInfo describes the server features the package relies on.
+type ServerInfo struct {
+	// Version is the server_version_num of the server, for example 150004.
+	Version int `json:"version"`
+	// Engine is the Postgres compatible engine detected from version(), one of EnginePostgres, EngineAurora,
+	// EngineYugabyteDB or EngineCockroachDB. Aurora is detected from its aurora_version function, other managed
+	// Postgres services report EnginePostgres.
+	Engine string `json:"engine"`
+	// AdvisoryLocks reports whether the engine enforces advisory locks. CockroachDB accepts the advisory lock
+	// functions as no-ops and YugabyteDB only enforces them when yb_enable_advisory_locks is on.
+	AdvisoryLocks bool `json:"advisory_locks"`
+	// WaitEvents reports whether pg_blocking_pids and the pg_stat_activity wait_event columns are available.
+	// They are needed by WithBlockerSampling, WithStarvationDetection and Activity.
+	WaitEvents bool `json:"wait_events"`
+}
+
+// CheckServer reads server_version_num and version() and reports the features available on the server.
+// It returns an *UnsupportedServerError if the engine does not enforce advisory locks or the server is too
+// old for advisory locks with deadlines.
+func CheckServer(ctx context.Context, db *sql.DB) (ServerInfo, error) {
+	info := ServerInfo{}
+	var version string
+	err := db.QueryRowContext(ctx, "SELECT current_setting('server_version_num')::int, version()").Scan(&info.Version, &version)
+	if err != nil {
+		return info, fmt.Errorf("pglock: could not read server version: %w", err)
+	}
+	info.Engine = engine(version)
+	if info.Engine == EnginePostgres {
+		aurora := false
+		sqlQuery := "SELECT EXISTS (SELECT 1 FROM pg_proc WHERE proname = 'aurora_version')"
+		if err := db.QueryRowContext(ctx, sqlQuery).Scan(&aurora); err != nil {
+			return info, fmt.Errorf("pglock: could not detect aurora: %w", err)
+		}
+		if aurora {
+			info.Engine = EngineAurora
+		}
+	}
+
+	ybAdvisoryLocks := false
+	if info.Engine == EngineYugabyteDB {
+		sqlQuery := "SELECT coalesce(current_setting('yb_enable_advisory_locks', true), 'off') = 'on'"
+		if err := db.QueryRowContext(ctx, sqlQuery).Scan(&ybAdvisoryLocks); err != nil {
+			return info, fmt.Errorf("pglock: could not read yb_enable_advisory_locks: %w", err)
+		}
+	}
+	return info, info.check(ybAdvisoryLocks)
+}
+
+// engine detects the engine from the output of version().
+func engine(version string) string {
+	switch {
+	case strings.HasPrefix(version, "CockroachDB"):
+		return EngineCockroachDB
+	case strings.Contains(version, "-YB-"):
+		return EngineYugabyteDB
+	default:
+		return EnginePostgres
+	}
+}
+
+func (i *ServerInfo) check(ybAdvisoryLocks bool) error {
+	i.WaitEvents = i.Version >= waitEventsVersion
+	switch i.Engine {
+	case EngineCockroachDB:
+		i.AdvisoryLocks = false
+	case EngineYugabyteDB:
+		i.AdvisoryLocks = ybAdvisoryLocks
+	default:
+		i.AdvisoryLocks = true
+	}
+	if !i.AdvisoryLocks {
+		return &UnsupportedServerError{Engine: i.Engine, Version: i.Version, Feature: "advisory locks"}
+	}
+	if i.Version < minServerVersion {
+		return &UnsupportedServerError{Engine: i.Engine, Version: i.Version, Feature: "lock_timeout", Required: minServerVersion}
+	}
+	return nil
+}
+
+// WithServerCheck makes NewLock call CheckServer and fail with a descriptive error on unsupported servers instead
+// of failing later with SQL errors. On servers without wait events, blocker sampling and starvation detection
+// are disabled and Activity returns an *UnsupportedServerError.
+func WithServerCheck() Option {
+	return func(l *Lock) {
+		l.serverCheck = true
+	}
+}
+
+// checkServer applies WithServerCheck to the lock.
+func (l *Lock) checkServer(ctx context.Context) error {
+	info, err := CheckServer(ctx, l.inspectDB())
+	if err != nil {
+		return err
+	}
+	l.server = &info
+	if !info.WaitEvents {
+		l.blockerInterval = 0
+		l.starvationThreshold = 0
+	}
+	return nil
+}
+
+// requireWaitEvents returns an *UnsupportedServerError if the server is known to lack wait events.
+func (l *Lock) requireWaitEvents() error {
+	if l.server != nil && !l.server.WaitEvents {
+		return &UnsupportedServerError{Engine: l.server.Engine, Version: l.server.Version, Feature: "wait events", Required: waitEventsVersion}
+	}
+	return nil
+}
@@ -0,0 +1,66 @@
+package pglock
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServerInfoCheck(t *testing.T) {
+	info := ServerInfo{Version: 90500, Engine: EnginePostgres}
+	assert.Nil(t, info.check(false))
+	assert.False(t, info.WaitEvents)
+	assert.True(t, info.AdvisoryLocks)
+
+	info = ServerInfo{Version: 90600, Engine: EnginePostgres}
+	assert.Nil(t, info.check(false))
+	assert.True(t, info.WaitEvents)
+
+	info = ServerInfo{Version: 90200, Engine: EnginePostgres}
+	err := info.check(false)
+	assert.True(t, errors.Is(err, ErrUnsupportedServer))
+	assert.Equal(t, "pglock: server version 90200 does not support lock_timeout, version 90300 or newer is required", err.Error())
+
+	info = ServerInfo{Version: 130000, Engine: EngineCockroachDB}
+	err = info.check(false)
+	assert.True(t, errors.Is(err, ErrUnsupportedServer))
+	assert.Equal(t, "pglock: cockroachdb server does not support advisory locks", err.Error())
+
+	info = ServerInfo{Version: 110002, Engine: EngineYugabyteDB}
+	assert.NotNil(t, info.check(false))
+	assert.Nil(t, info.check(true))
+	assert.True(t, info.AdvisoryLocks)
+}
+
+func TestEngine(t *testing.T) {
+	assert.Equal(t, EnginePostgres, engine("PostgreSQL 15.4 on x86_64-pc-linux-gnu, compiled by gcc"))
+	assert.Equal(t, EngineYugabyteDB, engine("PostgreSQL 11.2-YB-2.20.1.0-b0 on x86_64-pc-linux-gnu"))
+	assert.Equal(t, EngineCockroachDB, engine("CockroachDB CCL v23.1.11 (x86_64-pc-linux-gnu)"))
+}
+
+func TestActivityWithoutWaitEvents(t *testing.T) {
+	l := Lock{server: &ServerInfo{Version: 90500}}
+	_, err := l.Activity(context.Background())
+	assert.True(t, errors.Is(err, ErrUnsupportedServer))
+}
+
+func TestWithServerCheck(t *testing.T) {
+	db, err := newDB()
+	assert.Nil(t, err)
+	defer closeDB(db)
+
+	ctx := context.Background()
+	info, err := CheckServer(ctx, db)
+	assert.Nil(t, err)
+	assert.True(t, info.WaitEvents)
+	assert.True(t, info.AdvisoryLocks)
+	assert.Equal(t, EnginePostgres, info.Engine)
+
+	lock, err := NewLock(ctx, 30, db, WithServerCheck(), WithBlockerSampling(10))
+	assert.Nil(t, err)
+	defer lock.Close()
+	assert.Equal(t, info, *lock.server)
+	assert.NotZero(t, lock.blockerInterval)
+}
@@ -0,0 +1,27 @@
+package pglock
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrReleasedBySessionLoss is returned by Unlock and RUnlock when the release failed because the session connection
+// died, and a fresh connection confirmed that the session does not hold the lock anymore. The server releases every
+// lock of a session when it ends, so shutdown paths can treat it as a successful release.
+var ErrReleasedBySessionLoss = errors.New("pglock: lock released by session loss")
+
+// releasedBySessionLoss reports whether pg_locks, read with a separate connection, confirms that the lock session does not hold
+// the lock anymore. If the session PID is not known, the lock must not be held by any session.
+func (l *Lock) releasedBySessionLoss(ctx context.Context) bool {
+	l.state.mu.Lock()
+	pid := l.state.pid
+	l.state.mu.Unlock()
+
+	// See Holders for how bigint keys are stored in pg_locks.
+	sqlQuery := `SELECT NOT EXISTS (SELECT 1 FROM pg_locks
+		WHERE locktype = 'advisory' AND granted AND objsubid = 1 AND classid = $1 AND objid = $2
+		AND database = (SELECT oid FROM pg_database WHERE datname = current_database()) AND ($3 = 0 OR pid = $3))`
+	released := false
+	err := l.inspectDB().QueryRowContext(ctx, sqlQuery, int64(uint32(l.id>>32)), int64(uint32(l.id)), pid).Scan(&released)
+	return err == nil && released
+}
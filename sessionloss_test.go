@@ -0,0 +1,41 @@
+package pglock
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUnlockAfterSessionLoss(t *testing.T) {
+	db1, err := newDB()
+	assert.Nil(t, err)
+	defer closeDB(db1)
+	db2, err := newDB()
+	assert.Nil(t, err)
+	defer closeDB(db2)
+
+	ctx := context.Background()
+	id := int64(48)
+	for _, knownPID := range []bool{true, false} {
+		lock, err := NewLock(ctx, id, db1, WithInspectDB(db2))
+		assert.Nil(t, err)
+		assert.Nil(t, lock.WaitAndLock(ctx))
+		if knownPID {
+			_, err := lock.PID(ctx)
+			assert.Nil(t, err)
+		}
+		pids, err := Holders(ctx, db2, id)
+		assert.Nil(t, err)
+		if assert.Len(t, pids, 1) {
+			_, err = db2.ExecContext(ctx, "SELECT pg_terminate_backend($1)", pids[0])
+			assert.Nil(t, err)
+		}
+
+		err = lock.Unlock(ctx)
+		assert.True(t, errors.Is(err, ErrReleasedBySessionLoss), "known pid %v: %v", knownPID, err)
+		assert.Equal(t, Lost, lock.Status())
+		lock.Close()
+	}
+}
@@ -0,0 +1,89 @@
+package pglock
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+)
+
+// SessionSnapshot is a session holding or waiting for an advisory lock.
+// Waited is encoded to JSON as a string, for example "1.5s".
+type SessionSnapshot struct {
+	// PID is the backend process ID of the session.
+	PID int `json:"pid"`
+	// Shared reports whether the lock is held or requested in shared mode.
+	Shared bool `json:"shared"`
+	// ApplicationName is the application_name of the session.
+	ApplicationName string `json:"application_name"`
+	// Waited is how long a waiter has been waiting, measured from the start of its current statement.
+	// It is always zero for holders: PostgreSQL does not record when an advisory lock was granted, so how long a
+	// lock has been held cannot be read from the server.
+	Waited time.Duration `json:"waited"`
+}
+
+// MarshalJSON implements json.Marshaler.
+func (s SessionSnapshot) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		PID             int    `json:"pid"`
+		Shared          bool   `json:"shared"`
+		ApplicationName string `json:"application_name"`
+		Waited          string `json:"waited"`
+	}{s.PID, s.Shared, s.ApplicationName, s.Waited.String()})
+}
+
+// KeySnapshot is the state of one advisory lock key.
+type KeySnapshot struct {
+	// ID is the advisory lock id.
+	ID int64 `json:"id"`
+	// Name is the name of the id in the names passed to Snapshot, empty if it is not there.
+	Name string `json:"name,omitempty"`
+	// Holders are the sessions holding the lock.
+	Holders []SessionSnapshot `json:"holders"`
+	// Waiters are the sessions waiting for the lock, longest waiting first.
+	Waiters []SessionSnapshot `json:"waiters"`
+}
+
+// Snapshot returns every session level advisory lock key of the current database that is held or waited for,
+// with its holders and waiters, in a single query. names maps ids to human readable names, for example the IDNames
+// map generated by pglockgen, and may be nil. Keys are ordered by id, negative ids first.
+// Hold durations are not available, see SessionSnapshot.Waited.
+func Snapshot(ctx context.Context, db *sql.DB, names map[int64]string) ([]KeySnapshot, error) {
+	// See Holders for how bigint keys are stored in pg_locks. The shift wraps around, so the sort key is the signed id.
+	sqlQuery := `SELECT l.classid::bigint, l.objid::bigint, l.pid, l.mode = 'ShareLock', l.granted,
+		coalesce(a.application_name, ''),
+		CASE WHEN l.granted THEN 0 ELSE coalesce(extract(epoch FROM clock_timestamp() - a.state_change), 0) END
+		FROM pg_locks l LEFT JOIN pg_stat_activity a ON a.pid = l.pid
+		WHERE l.locktype = 'advisory' AND l.objsubid = 1
+		AND l.database = (SELECT oid FROM pg_database WHERE datname = current_database())
+		ORDER BY l.classid::bigint << 32 | l.objid::bigint, a.state_change, l.pid`
+	rows, err := db.QueryContext(ctx, sqlQuery)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	snapshots := []KeySnapshot{}
+	for rows.Next() {
+		var classid, objid int64
+		var granted bool
+		var waited float64
+		session := SessionSnapshot{}
+		if err := rows.Scan(&classid, &objid, &session.PID, &session.Shared, &granted, &session.ApplicationName, &waited); err != nil {
+			return nil, err
+		}
+		session.Waited = time.Duration(waited * float64(time.Second))
+
+		id := int64(uint64(classid)<<32 | uint64(objid))
+		if len(snapshots) == 0 || snapshots[len(snapshots)-1].ID != id {
+			snapshots = append(snapshots, KeySnapshot{ID: id, Name: names[id], Holders: []SessionSnapshot{}, Waiters: []SessionSnapshot{}})
+		}
+		snapshot := &snapshots[len(snapshots)-1]
+		if granted {
+			snapshot.Holders = append(snapshot.Holders, session)
+		} else {
+			snapshot.Waiters = append(snapshot.Waiters, session)
+		}
+	}
+	return snapshots, rows.Err()
+}
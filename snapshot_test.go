@@ -0,0 +1,80 @@
+package pglock
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSessionSnapshotMarshalJSON(t *testing.T) {
+	data, err := json.Marshal(SessionSnapshot{PID: 100, Shared: true, ApplicationName: "worker", Waited: 1500 * time.Millisecond})
+	assert.Nil(t, err)
+	assert.JSONEq(t, `{"pid":100,"shared":true,"application_name":"worker","waited":"1.5s"}`, string(data))
+}
+
+func TestSnapshot(t *testing.T) {
+	db1, err := newDB()
+	assert.Nil(t, err)
+	defer closeDB(db1)
+	db2, err := newDB()
+	assert.Nil(t, err)
+	defer closeDB(db2)
+
+	ctx := context.Background()
+	id := int64(-4294967262) // exercises both halves of the key
+	lock1, err := NewLock(ctx, id, db1)
+	assert.Nil(t, err)
+	defer lock1.Close()
+	lock2, err := NewLock(ctx, id, db2)
+	assert.Nil(t, err)
+	defer lock2.Close()
+
+	ok, err := lock1.Lock(ctx)
+	assert.True(t, ok)
+	assert.Nil(t, err)
+	other, err := NewLock(ctx, 54, db1)
+	assert.Nil(t, err)
+	defer other.Close()
+	ok, err = other.Lock(ctx)
+	assert.True(t, ok)
+	assert.Nil(t, err)
+	pid1, err := lock1.PID(ctx)
+	assert.Nil(t, err)
+	pid2, err := lock2.PID(ctx)
+	assert.Nil(t, err)
+
+	done := make(chan error)
+	go func() { done <- lock2.WaitAndLock(ctx) }()
+
+	var key KeySnapshot
+	assert.Eventually(t, func() bool {
+		snapshots, err := Snapshot(ctx, db1, map[int64]string{id: "billing/invoices"})
+		if err != nil {
+			return false
+		}
+		// Negative ids sort before positive ones.
+		if !sort.SliceIsSorted(snapshots, func(i, j int) bool { return snapshots[i].ID < snapshots[j].ID }) {
+			t.Errorf("snapshots are not sorted by id: %v", snapshots)
+		}
+		for _, snapshot := range snapshots {
+			if snapshot.ID == id {
+				key = snapshot
+				return len(snapshot.Waiters) == 1
+			}
+		}
+		return false
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, "billing/invoices", key.Name)
+	assert.Equal(t, pid1, key.Holders[0].PID)
+	assert.Equal(t, time.Duration(0), key.Holders[0].Waited)
+	assert.Equal(t, pid2, key.Waiters[0].PID)
+	assert.False(t, key.Waiters[0].Shared)
+
+	assert.Nil(t, lock1.Unlock(ctx))
+	assert.Nil(t, <-done)
+	assert.Nil(t, lock2.Unlock(ctx))
+}
@@ -0,0 +1,53 @@
+package pglock
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// StarvationEvent reports an exclusive acquisition blocked behind readers for longer than the configured threshold.
+type StarvationEvent struct {
+	// ID is the advisory lock id.
+	ID int64
+	// Waited is how long the writer has been waiting.
+	Waited time.Duration
+	// Readers is the number of sessions holding the lock in shared mode when the event was detected.
+	Readers int
+}
+
+// WithStarvationDetection makes WaitAndLock report, at most once per acquisition, when it has been waiting for longer than threshold
+// while readers hold the lock in shared mode. Readers are counted with a separate connection from the pool.
+func WithStarvationDetection(threshold time.Duration, fn func(StarvationEvent)) Option {
+	return func(l *Lock) {
+		l.starvationThreshold = threshold
+		l.onStarvation = fn
+	}
+}
+
+// watchStarvation reports starvation of an exclusive acquisition until stop is closed.
+// The returned function waits for the watcher to finish.
+func (l *Lock) watchStarvation(ctx context.Context, stop <-chan struct{}) func() {
+	if l.starvationThreshold <= 0 || l.onStarvation == nil {
+		return func() {}
+	}
+
+	start := l.now()
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		timer := l.state.clock.NewTimer(l.starvationThreshold)
+		defer timer.Stop()
+		select {
+		case <-stop:
+		case <-ctx.Done():
+		case <-timer.C():
+			readers, err := Readers(ctx, l.inspectDB(), l.id)
+			if err == nil && readers > 0 {
+				l.onStarvation(StarvationEvent{ID: l.id, Waited: l.since(start), Readers: readers})
+			}
+		}
+	}()
+	return wg.Wait
+}
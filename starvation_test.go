@@ -0,0 +1,49 @@
+package pglock
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStarvationDetection(t *testing.T) {
+	db1, err := newDB()
+	assert.Nil(t, err)
+	defer closeDB(db1)
+	db2, err := newDB()
+	assert.Nil(t, err)
+	defer closeDB(db2)
+
+	ctx := context.Background()
+	id := int64(25)
+	reader, err := NewLock(ctx, id, db1)
+	assert.Nil(t, err)
+	defer reader.Close()
+	events := make(chan StarvationEvent, 1)
+	writer, err := NewLock(ctx, id, db2, WithStarvationDetection(100*time.Millisecond, func(event StarvationEvent) {
+		events <- event
+	}))
+	assert.Nil(t, err)
+	defer writer.Close()
+
+	assert.Nil(t, reader.WaitAndRLock(ctx))
+	go func() {
+		time.Sleep(300 * time.Millisecond)
+		if err := reader.RUnlock(ctx); err != nil {
+			t.Error(err)
+		}
+	}()
+	assert.Nil(t, writer.WaitAndLock(ctx))
+
+	select {
+	case event := <-events:
+		assert.Equal(t, id, event.ID)
+		assert.Equal(t, 1, event.Readers)
+		assert.True(t, event.Waited >= 100*time.Millisecond)
+	default:
+		t.Fatal("starvation was not reported")
+	}
+	assert.Nil(t, writer.Unlock(ctx))
+}
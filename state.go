@@ -0,0 +1,331 @@
+package pglock
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// State is the state of a Lock.
+type State int
+
+const (
+	// Idle means the session is open and does not hold the lock.
+	Idle State = iota
+	// Acquiring means an acquisition is in progress and the lock is not held yet.
+	Acquiring
+	// HeldExclusive means the session holds the lock in exclusive mode, and possibly in shared mode as well.
+	HeldExclusive
+	// HeldShared means the session holds the lock in shared mode only.
+	HeldShared
+	// Lost means the session connection failed while the lock was held, so the server has released it.
+	Lost
+	// Closed means Close was called.
+	Closed
+)
+
+var stateNames = map[State]string{
+	Idle:          "idle",
+	Acquiring:     "acquiring",
+	HeldExclusive: "held_exclusive",
+	HeldShared:    "held_shared",
+	Lost:          "lost",
+	Closed:        "closed",
+}
+
+// String returns the state name.
+func (s State) String() string {
+	if name, ok := stateNames[s]; ok {
+		return name
+	}
+	return "unknown"
+}
+
+// held reports whether the state means the lock is held, in any mode.
+func (s State) held() bool {
+	return s == HeldExclusive || s == HeldShared
+}
+
+// MarshalJSON encodes the state as its name.
+func (s State) MarshalJSON() ([]byte, error) {
+	return json.Marshal(s.String())
+}
+
+// UnmarshalJSON decodes a state from its name.
+func (s *State) UnmarshalJSON(data []byte) error {
+	var name string
+	if err := json.Unmarshal(data, &name); err != nil {
+		return err
+	}
+	for state, stateName := range stateNames {
+		if stateName == name {
+			*s = state
+			return nil
+		}
+	}
+	return fmt.Errorf("pglock: unknown state %q", name)
+}
+
+// lockState tracks the state of a Lock, it is shared by copies of the same Lock.
+type lockState struct {
+	mu       sync.Mutex
+	state    State
+	depth    int
+	shared   int
+	last     AcquireResult
+	pid      int
+	holds    []context.CancelFunc
+	tokens   map[string]bool
+	sections []openSection
+	sequence uint64
+	since    time.Time
+	settings []string
+	purpose  bool
+	appName  string
+	slots    int
+	closed   bool
+	release  func()
+	dequeue  func()
+
+	clock     Clock
+	maxHold   time.Duration
+	holdTimer Timer
+	onMaxHold func()
+
+	onChange func(from, to State)
+}
+
+// LockStatus is a point in time description of a Lock, suitable for operational endpoints.
+type LockStatus struct {
+	// ID is the advisory lock id.
+	ID int64 `json:"id"`
+	// State is the lock state.
+	State State `json:"state"`
+	// Depth is the number of stacked exclusive acquisitions held by the session.
+	Depth int `json:"depth"`
+	// SharedDepth is the number of stacked shared acquisitions held by the session.
+	SharedDepth int `json:"shared_depth"`
+	// LastAcquire describes the most recent acquisition attempt.
+	LastAcquire AcquireResult `json:"last_acquire"`
+}
+
+// WithStateChange registers a function called on every state transition of the lock.
+// It is called synchronously by the goroutine that caused the transition, so it must be safe for concurrent use
+// when the lock is used from several goroutines.
+func WithStateChange(fn func(from, to State)) Option {
+	return func(l *Lock) {
+		l.state.onChange = fn
+	}
+}
+
+// Status returns the current state of the lock.
+func (l *Lock) Status() State {
+	if l.state == nil {
+		return Idle
+	}
+	return l.state.current()
+}
+
+// current returns the current state.
+func (s *lockState) current() State {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.state
+}
+
+// Describe returns the current LockStatus of the lock.
+func (l *Lock) Describe() LockStatus {
+	status := LockStatus{ID: l.id}
+	if l.state == nil {
+		return status
+	}
+	l.state.mu.Lock()
+	defer l.state.mu.Unlock()
+	status.State = l.state.state
+	status.Depth = l.state.depth
+	status.SharedDepth = l.state.shared
+	status.LastAcquire = l.state.last
+	return status
+}
+
+// set changes the state and notifies onChange, the caller must hold mu.
+// onChange is called after mu is released.
+func (s *lockState) set(to State) func() {
+	from := s.state
+	s.state = to
+	if to != HeldExclusive {
+		for _, cancel := range s.holds {
+			cancel()
+		}
+		s.holds = nil
+		s.tokens = nil
+	}
+	if !to.held() {
+		s.sections = nil
+	}
+	s.leaveQueue()
+	if to == Lost {
+		// The session is gone, its SessionLimiter slot must not keep a replacement session waiting until Close.
+		s.freeSlot()
+	}
+	if !from.held() && to.held() {
+		s.since = s.clock.Now()
+	}
+	switch {
+	case !from.held() && to.held() && s.maxHold > 0 && s.onMaxHold != nil:
+		s.holdTimer = s.clock.AfterFunc(s.maxHold, s.onMaxHold)
+	case from.held() && !to.held() && s.holdTimer != nil:
+		s.holdTimer.Stop()
+		s.holdTimer = nil
+	}
+	if from == to || s.onChange == nil {
+		return func() {}
+	}
+	return func() { s.onChange(from, to) }
+}
+
+// beginAcquire moves an idle lock to Acquiring.
+func (s *lockState) beginAcquire() {
+	s.mu.Lock()
+	notify := func() {}
+	if s.state == Idle {
+		notify = s.set(Acquiring)
+	}
+	s.mu.Unlock()
+	notify()
+}
+
+// held returns the state matching the acquisitions held by the session.
+func (s *lockState) held() State {
+	switch {
+	case s.depth > 0:
+		return HeldExclusive
+	case s.shared > 0:
+		return HeldShared
+	}
+	return Idle
+}
+
+// endAcquire records the outcome of an exclusive acquisition, or of a shared one if shared is true.
+func (s *lockState) endAcquire(shared bool, result AcquireResult, err error) {
+	s.mu.Lock()
+	s.last = result
+	notify := func() {}
+	switch {
+	case s.state == Closed || s.state == Lost:
+	case isConnError(err):
+		s.depth, s.shared, s.slots = 0, 0, 0
+		notify = s.set(Lost)
+	default:
+		if result.Acquired && shared {
+			s.shared++
+		} else if result.Acquired {
+			s.depth++
+		}
+		notify = s.set(s.held())
+	}
+	s.mu.Unlock()
+	notify()
+}
+
+// endRelease records the outcome of an exclusive unlock, or of a shared one if shared is true.
+func (s *lockState) endRelease(shared bool, released bool, err error) {
+	s.mu.Lock()
+	notify := func() {}
+	switch {
+	case s.state == Closed || s.state == Lost:
+	case isConnError(err):
+		s.depth, s.shared, s.slots = 0, 0, 0
+		notify = s.set(Lost)
+	case released && shared && s.shared > 0:
+		s.shared--
+		notify = s.set(s.held())
+	case released && !shared && s.depth > 0:
+		s.depth--
+		notify = s.set(s.held())
+	}
+	s.mu.Unlock()
+	notify()
+}
+
+// observe moves the lock to Lost if err means the session connection is gone.
+func (s *lockState) observe(err error) {
+	s.mu.Lock()
+	notify := func() {}
+	if s.state != Closed && s.state != Lost && isConnError(err) {
+		s.depth, s.shared, s.slots = 0, 0, 0
+		notify = s.set(Lost)
+	}
+	s.mu.Unlock()
+	notify()
+}
+
+// addSlot records delta acquisitions of the UpgradableRLock slot.
+func (s *lockState) addSlot(delta int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.slots += delta
+}
+
+// releasedAll records that every acquisition of the session was released.
+func (s *lockState) releasedAll() {
+	s.mu.Lock()
+	notify := func() {}
+	s.slots = 0
+	if s.state.held() {
+		s.depth, s.shared = 0, 0
+		notify = s.set(Idle)
+	}
+	s.mu.Unlock()
+	notify()
+}
+
+// addHold registers cancel to be called when the lock stops being held.
+// It returns false if the lock is not held anymore.
+func (s *lockState) addHold(cancel context.CancelFunc) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.state != HeldExclusive {
+		return false
+	}
+	s.holds = append(s.holds, cancel)
+	return true
+}
+
+// close moves the lock to Closed.
+func (s *lockState) close() {
+	s.mu.Lock()
+	if !s.closed {
+		s.closed = true
+		sessionClosed()
+		s.freeSlot()
+	}
+	s.depth, s.shared, s.slots = 0, 0, 0
+	notify := s.set(Closed)
+	s.mu.Unlock()
+	notify()
+}
+
+// freeSlot returns the SessionLimiter slot of the session, once. The caller must hold mu.
+func (s *lockState) freeSlot() {
+	if s.release != nil {
+		s.release()
+		s.release = nil
+	}
+}
+
+// isConnError reports whether err means the session connection is gone.
+func isConnError(err error) bool {
+	if errors.Is(err, driver.ErrBadConn) || errors.Is(err, sql.ErrConnDone) {
+		return true
+	}
+	// Class 08 is connection exception, 57P01 and 57P02 are reported when the backend is terminated.
+	state := sqlState(err)
+	return strings.HasPrefix(state, "08") || state == "57P01" || state == "57P02"
+}
@@ -0,0 +1,92 @@
+package pglock
+
+import (
+	"encoding/json"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStateString(t *testing.T) {
+	assert.Equal(t, "idle", Idle.String())
+	assert.Equal(t, "acquiring", Acquiring.String())
+	assert.Equal(t, "held_exclusive", HeldExclusive.String())
+	assert.Equal(t, "held_shared", HeldShared.String())
+	assert.Equal(t, "lost", Lost.String())
+	assert.Equal(t, "closed", Closed.String())
+	assert.Equal(t, "unknown", State(100).String())
+}
+
+func TestStateJSON(t *testing.T) {
+	for state := range stateNames {
+		data, err := json.Marshal(state)
+		assert.Nil(t, err)
+		var decoded State
+		assert.Nil(t, json.Unmarshal(data, &decoded))
+		assert.Equal(t, state, decoded)
+	}
+	var decoded State
+	assert.NotNil(t, json.Unmarshal([]byte(`"bogus"`), &decoded))
+}
+
+func TestLockStatusJSON(t *testing.T) {
+	status := LockStatus{
+		ID:    42,
+		State: HeldExclusive,
+		Depth: 1,
+		LastAcquire: AcquireResult{
+			Acquired:   true,
+			ConnWait:   time.Millisecond,
+			ServerWait: 2 * time.Second,
+			Total:      2*time.Second + time.Millisecond,
+			Attempts:   1,
+		},
+	}
+	data, err := json.Marshal(status)
+	assert.Nil(t, err)
+	assert.JSONEq(t, `{
+		"id": 42,
+		"state": "held_exclusive",
+		"depth": 1,
+		"shared_depth": 0,
+		"last_acquire": {"acquired": true, "conn_wait": "1ms", "server_wait": "2s", "total": "2.001s", "attempts": 1}
+	}`, string(data))
+}
+
+func TestLockStateConcurrentUse(t *testing.T) {
+	// Run with -race: acquisitions and releases recorded from different goroutines must not race.
+	l := Lock{id: 1, state: &lockState{clock: systemClock{}}}
+	changes := int64(0)
+	WithStateChange(func(from, to State) { atomic.AddInt64(&changes, 1) })(&l)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			l.state.beginAcquire()
+			l.state.endAcquire(false, AcquireResult{Acquired: true}, nil)
+		}()
+		go func() {
+			defer wg.Done()
+			_ = l.Status()
+			_ = l.Describe()
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, 10, l.Describe().Depth)
+
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			l.state.endRelease(false, true, nil)
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, Idle, l.Status())
+	assert.NotZero(t, changes)
+}
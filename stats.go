@@ -0,0 +1,71 @@
+package pglock
+
+import (
+	"encoding/json"
+	"sync/atomic"
+	"time"
+)
+
+// Package wide session counters, updated atomically.
+var (
+	openSessions  int64
+	totalSessions int64
+	connWait      int64
+	lockWaits     int64
+	lockWait      int64
+)
+
+// Stats describes the database connections used by the package.
+// Durations are encoded to JSON as strings, for example "1.5ms".
+type Stats struct {
+	// OpenSessions is the number of pool connections currently held by locks created with NewLock and not closed yet.
+	OpenSessions int64 `json:"open_sessions"`
+	// TotalSessions is the number of pool connections taken by NewLock since the process started.
+	TotalSessions int64 `json:"total_sessions"`
+	// ConnWait is the total time NewLock spent waiting for a free connection from the pool (pool checkout).
+	// A high value calls for a larger pool or fewer concurrent lock sessions, see WithSessionLimiter.
+	ConnWait time.Duration `json:"conn_wait"`
+	// LockWaits is the number of waiting acquisitions, such as WaitAndLock, that completed.
+	LockWaits int64 `json:"lock_waits"`
+	// LockWait is the total time waiting acquisitions spent waiting on the server for the advisory lock.
+	// A high value calls for shorter critical sections or finer grained keys.
+	LockWait time.Duration `json:"lock_wait"`
+}
+
+// MarshalJSON implements json.Marshaler.
+func (s Stats) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		OpenSessions  int64  `json:"open_sessions"`
+		TotalSessions int64  `json:"total_sessions"`
+		ConnWait      string `json:"conn_wait"`
+		LockWaits     int64  `json:"lock_waits"`
+		LockWait      string `json:"lock_wait"`
+	}{s.OpenSessions, s.TotalSessions, s.ConnWait.String(), s.LockWaits, s.LockWait.String()})
+}
+
+// PackageStats returns the connection usage of the package across all locks, to see when advisory locks are the reason max_connections is being approached.
+// Connections used briefly by helpers such as Holders or the rate limiters are not counted.
+func PackageStats() Stats {
+	return Stats{
+		OpenSessions:  atomic.LoadInt64(&openSessions),
+		TotalSessions: atomic.LoadInt64(&totalSessions),
+		ConnWait:      time.Duration(atomic.LoadInt64(&connWait)),
+		LockWaits:     atomic.LoadInt64(&lockWaits),
+		LockWait:      time.Duration(atomic.LoadInt64(&lockWait)),
+	}
+}
+
+func sessionOpened(wait time.Duration) {
+	atomic.AddInt64(&openSessions, 1)
+	atomic.AddInt64(&totalSessions, 1)
+	atomic.AddInt64(&connWait, int64(wait))
+}
+
+func lockWaited(wait time.Duration) {
+	atomic.AddInt64(&lockWaits, 1)
+	atomic.AddInt64(&lockWait, int64(wait))
+}
+
+func sessionClosed() {
+	atomic.AddInt64(&openSessions, -1)
+}
@@ -0,0 +1,44 @@
+package pglock
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPackageStats(t *testing.T) {
+	db, err := newDB()
+	assert.Nil(t, err)
+	defer closeDB(db)
+
+	ctx := context.Background()
+	before := PackageStats()
+	lock, err := NewLock(ctx, int64(16), db)
+	assert.Nil(t, err)
+
+	stats := PackageStats()
+	assert.Equal(t, before.OpenSessions+1, stats.OpenSessions)
+	assert.Equal(t, before.TotalSessions+1, stats.TotalSessions)
+	assert.True(t, stats.ConnWait > before.ConnWait)
+
+	assert.Nil(t, lock.WaitAndLock(ctx))
+	assert.Nil(t, lock.Unlock(ctx))
+	stats = PackageStats()
+	assert.Equal(t, before.LockWaits+1, stats.LockWaits)
+
+	assert.Nil(t, lock.Close())
+	// A second Close must not be counted twice.
+	assert.NotNil(t, lock.Close())
+	stats = PackageStats()
+	assert.Equal(t, before.OpenSessions, stats.OpenSessions)
+	assert.Equal(t, before.TotalSessions+1, stats.TotalSessions)
+}
+
+func TestStatsJSON(t *testing.T) {
+	data, err := json.Marshal(Stats{OpenSessions: 1, TotalSessions: 2, ConnWait: time.Millisecond, LockWaits: 3, LockWait: time.Second})
+	assert.Nil(t, err)
+	assert.JSONEq(t, `{"open_sessions":1,"total_sessions":2,"conn_wait":"1ms","lock_waits":3,"lock_wait":"1s"}`, string(data))
+}
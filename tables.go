@@ -0,0 +1,129 @@
+package pglock
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// undefinedTable is the SQLSTATE reported when a table does not exist.
+const undefinedTable = "42P01"
+
+// Table is the schema of the table a table backed helper, such as Fence or TokenBucketLimiter, stores its state in.
+// The helpers do not create their tables, which would need DDL rights at runtime: create them beforehand with Migrate,
+// or run CreateSQL from the migration tool of the application. The table name may be schema qualified.
+type Table struct {
+	// Name is the table name.
+	Name string
+	// helper is the name of the constructor using the table, for error messages.
+	helper string
+	// columns are the column and constraint definitions of the table.
+	columns string
+}
+
+// CreateSQL returns the CREATE TABLE IF NOT EXISTS statement of the table.
+func (t Table) CreateSQL() string {
+	return fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (%s)", quoteIdentifier(t.Name), t.columns)
+}
+
+// FenceTable returns the table of NewFence.
+func FenceTable(name string) Table {
+	return Table{Name: name, helper: "NewFence", columns: `
+		key text PRIMARY KEY,
+		token bigint NOT NULL
+	`}
+}
+
+// MaintenanceTable returns the table of NewMaintenance.
+func MaintenanceTable(name string) Table {
+	return Table{Name: name, helper: "NewMaintenance", columns: `
+		namespace text PRIMARY KEY,
+		reason text NOT NULL,
+		since timestamptz NOT NULL
+	`}
+}
+
+// FixedWindowLimiterTable returns the table of NewFixedWindowLimiter.
+func FixedWindowLimiterTable(name string) Table {
+	return Table{Name: name, helper: "NewFixedWindowLimiter", columns: `
+		key text PRIMARY KEY,
+		window_start bigint NOT NULL,
+		count integer NOT NULL
+	`}
+}
+
+// ReleaseHintsTable returns the table of NewReleaseHints.
+func ReleaseHintsTable(name string) Table {
+	return Table{Name: name, helper: "NewReleaseHints", columns: `
+		id bigint PRIMARY KEY,
+		release_at timestamptz NOT NULL
+	`}
+}
+
+// ReservationsTable returns the table of NewReservations.
+func ReservationsTable(name string) Table {
+	return Table{Name: name, helper: "NewReservations", columns: `
+		id bigint NOT NULL,
+		owner text NOT NULL,
+		starts_at timestamptz NOT NULL,
+		ends_at timestamptz NOT NULL,
+		CHECK (ends_at > starts_at)
+	`}
+}
+
+// TokenBucketLimiterTable returns the table of NewTokenBucketLimiter.
+func TokenBucketLimiterTable(name string) Table {
+	return Table{Name: name, helper: "NewTokenBucketLimiter", columns: `
+		key text PRIMARY KEY,
+		tokens double precision NOT NULL,
+		updated_at bigint NOT NULL
+	`}
+}
+
+// UnlockTokensTable returns the table of NewUnlockTokens.
+func UnlockTokensTable(name string) Table {
+	return Table{Name: name, helper: "NewUnlockTokens", columns: `
+		token text PRIMARY KEY,
+		id bigint NOT NULL,
+		redeemed_at timestamptz
+	`}
+}
+
+// Migrate creates the tables that do not exist yet, in a single transaction. It is the explicit setup step of the
+// table backed helpers, to run once with a role allowed to create tables, for example at deploy time.
+func Migrate(ctx context.Context, db *sql.DB, tables ...Table) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	for _, table := range tables {
+		if _, err := tx.ExecContext(ctx, table.CreateSQL()); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// openTable checks that table exists and returns its quoted name. It only needs read access to the table.
+func openTable(ctx context.Context, db *sql.DB, table Table) (string, error) {
+	name := quoteIdentifier(table.Name)
+	if _, err := db.ExecContext(ctx, fmt.Sprintf("SELECT FROM %s LIMIT 0", name)); err != nil {
+		if sqlState(err) == undefinedTable {
+			return "", fmt.Errorf("pglock: %s: table %s does not exist, create it with Migrate: %w", table.helper, table.Name, err)
+		}
+		return "", err
+	}
+	return name, nil
+}
+
+// quoteIdentifier quotes a possibly schema qualified identifier.
+func quoteIdentifier(name string) string {
+	parts := strings.Split(name, ".")
+	for i, part := range parts {
+		parts[i] = `"` + strings.ReplaceAll(part, `"`, `""`) + `"`
+	}
+	return strings.Join(parts, ".")
+}
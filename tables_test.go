@@ -0,0 +1,38 @@
+package pglock
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTableCreateSQL(t *testing.T) {
+	sqlQuery := FenceTable("locks.fence").CreateSQL()
+	assert.Contains(t, sqlQuery, `CREATE TABLE IF NOT EXISTS "locks"."fence" (`)
+	assert.Contains(t, sqlQuery, "token bigint NOT NULL")
+}
+
+func TestMigrate(t *testing.T) {
+	db, err := newDB()
+	assert.Nil(t, err)
+	defer closeDB(db)
+
+	ctx := context.Background()
+	table := FenceTable("pglock_test_migrate")
+
+	// The helpers do not create their tables.
+	_, err = NewFence(ctx, db, table.Name)
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "create it with Migrate")
+
+	assert.Nil(t, Migrate(ctx, db, table))
+	defer func() {
+		_, err := db.ExecContext(ctx, "DROP TABLE pglock_test_migrate")
+		assert.Nil(t, err)
+	}()
+	// Migrate is idempotent.
+	assert.Nil(t, Migrate(ctx, db, table))
+	_, err = NewFence(ctx, db, table.Name)
+	assert.Nil(t, err)
+}
@@ -0,0 +1,153 @@
+package pglock
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrTempStateMissing is matched by errors.Is for a *TempStateError.
+var ErrTempStateMissing = errors.New("pglock: temporary state missing")
+
+// TempStateError is returned by VerifyTempState when temporary state of the lock session is missing or changed.
+type TempStateError struct {
+	// ID is the advisory lock id.
+	ID int64
+	// Missing are the temporary tables that do not exist and the settings that do not have their value.
+	Missing []string
+}
+
+// Error implements the error interface.
+func (e *TempStateError) Error() string {
+	return fmt.Sprintf("pglock: temporary state of lock %d missing: %s", e.ID, strings.Join(e.Missing, ", "))
+}
+
+// Is reports whether target is ErrTempStateMissing.
+func (e *TempStateError) Is(target error) bool {
+	return target == ErrTempStateMissing
+}
+
+type tempTable struct {
+	name       string
+	definition string
+}
+
+type tempSetting struct {
+	name  string
+	value string
+}
+
+// WithTempTable creates a temporary table on the lock session when the lock becomes held, and drops it when the lock stops being held,
+// for workflows that stage data on the same session that serializes them. definition is the column list, for example
+// "id bigint PRIMARY KEY, payload jsonb". A table left over by a previous holder of the session is replaced.
+// If the table cannot be created the lock is released and the acquisition fails.
+func WithTempTable(name, definition string) Option {
+	return func(l *Lock) {
+		l.tempTables = append(l.tempTables, tempTable{name: name, definition: definition})
+	}
+}
+
+// WithTempSetting sets a configuration parameter of the lock session when the lock becomes held, and restores its previous value
+// when the lock stops being held. If the parameter cannot be set the lock is released and the acquisition fails.
+func WithTempSetting(name, value string) Option {
+	return func(l *Lock) {
+		l.tempSettings = append(l.tempSettings, tempSetting{name: name, value: value})
+	}
+}
+
+// setupTempState creates the temporary state of the lock session, releasing the lock acquired in mode shared if that fails.
+func (l *Lock) setupTempState(ctx context.Context, shared bool) error {
+	err := l.createTempState(ctx)
+	if err == nil {
+		return nil
+	}
+	sqlQuery := "SELECT pg_advisory_unlock($1)"
+	if shared {
+		sqlQuery = "SELECT pg_advisory_unlock_shared($1)"
+	}
+	if _, unlockErr := l.conn.ExecContext(ctx, sqlQuery, l.id); isConnError(unlockErr) {
+		return unlockErr
+	}
+	return err
+}
+
+func (l *Lock) createTempState(ctx context.Context) error {
+	for _, table := range l.tempTables {
+		name := quoteIdentifier(table.name)
+		if _, err := l.conn.ExecContext(ctx, "DROP TABLE IF EXISTS pg_temp."+name); err != nil {
+			return err
+		}
+		if _, err := l.conn.ExecContext(ctx, fmt.Sprintf("CREATE TEMP TABLE %s (%s)", name, table.definition)); err != nil {
+			return err
+		}
+	}
+
+	previous := make([]string, len(l.tempSettings))
+	for i, setting := range l.tempSettings {
+		sqlQuery := "SELECT coalesce(current_setting($1, true), ''), set_config($1, $2, false)"
+		var current string
+		if err := l.conn.QueryRowContext(ctx, sqlQuery, setting.name, setting.value).Scan(&previous[i], &current); err != nil {
+			return err
+		}
+	}
+	l.state.mu.Lock()
+	l.state.settings = previous
+	l.state.mu.Unlock()
+	return nil
+}
+
+// teardownTempState drops the temporary tables and restores the settings of the lock session if the lock is not held anymore.
+func (l *Lock) teardownTempState(ctx context.Context) error {
+	l.state.mu.Lock()
+	previous := l.state.settings
+	held := l.state.state.held()
+	if !held {
+		l.state.settings = nil
+	}
+	l.state.mu.Unlock()
+	if held {
+		return nil
+	}
+
+	for _, table := range l.tempTables {
+		if _, err := l.conn.ExecContext(ctx, "DROP TABLE IF EXISTS pg_temp."+quoteIdentifier(table.name)); err != nil {
+			return err
+		}
+	}
+	for i, value := range previous {
+		if _, err := l.conn.ExecContext(ctx, "SELECT set_config($1, $2, false)", l.tempSettings[i].name, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// VerifyTempState checks that the temporary tables and settings configured with WithTempTable and WithTempSetting are still in place
+// on the lock session, for example before using staged data after a long pause. It returns a *TempStateError listing what is missing.
+func (l *Lock) VerifyTempState(ctx context.Context) error {
+	missing := []string{}
+	for _, table := range l.tempTables {
+		exists := false
+		sqlQuery := "SELECT to_regclass('pg_temp.' || $1) IS NOT NULL"
+		if err := l.conn.QueryRowContext(ctx, sqlQuery, quoteIdentifier(table.name)).Scan(&exists); err != nil {
+			return err
+		}
+		if !exists {
+			missing = append(missing, "table "+table.name)
+		}
+	}
+	for _, setting := range l.tempSettings {
+		value := ""
+		if err := l.conn.QueryRowContext(ctx, "SELECT coalesce(current_setting($1, true), '')", setting.name).Scan(&value); err != nil {
+			return err
+		}
+		if value != setting.value {
+			missing = append(missing, "setting "+setting.name)
+		}
+	}
+	if len(missing) > 0 {
+		return &TempStateError{ID: l.id, Missing: missing}
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+package pglock
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTempStateError(t *testing.T) {
+	err := &TempStateError{ID: 1, Missing: []string{"table staging", "setting work_mem"}}
+	assert.True(t, errors.Is(err, ErrTempStateMissing))
+	assert.Equal(t, "pglock: temporary state of lock 1 missing: table staging, setting work_mem", err.Error())
+}
+
+func TestTempState(t *testing.T) {
+	db, err := newDB()
+	assert.Nil(t, err)
+	defer closeDB(db)
+
+	ctx := context.Background()
+	id := int64(49)
+	lock, err := NewLock(ctx, id, db, WithTempTable("staging", "id bigint PRIMARY KEY"), WithTempSetting("pglock.batch", "7"))
+	assert.Nil(t, err)
+	defer lock.Close()
+
+	assert.Nil(t, lock.WaitAndLock(ctx))
+	assert.Nil(t, lock.VerifyTempState(ctx))
+	_, err = lock.conn.ExecContext(ctx, "INSERT INTO staging (id) VALUES (1)")
+	assert.Nil(t, err)
+
+	// Stacked acquisitions keep the state.
+	acquired, err := lock.Lock(ctx)
+	assert.Nil(t, err)
+	assert.True(t, acquired)
+	assert.Nil(t, lock.Unlock(ctx))
+	count := 0
+	assert.Nil(t, lock.conn.QueryRowContext(ctx, "SELECT count(*) FROM staging").Scan(&count))
+	assert.Equal(t, 1, count)
+
+	assert.Nil(t, lock.Unlock(ctx))
+	err = lock.VerifyTempState(ctx)
+	assert.Equal(t, &TempStateError{ID: id, Missing: []string{"table staging", "setting pglock.batch"}}, err)
+
+	// The table is created empty on the next acquisition.
+	assert.Nil(t, lock.WaitAndLock(ctx))
+	assert.Nil(t, lock.conn.QueryRowContext(ctx, "SELECT count(*) FROM staging").Scan(&count))
+	assert.Equal(t, 0, count)
+	assert.Nil(t, lock.Unlock(ctx))
+}
+
+func TestTempStateSetupFailure(t *testing.T) {
+	db, err := newDB()
+	assert.Nil(t, err)
+	defer closeDB(db)
+
+	ctx := context.Background()
+	id := int64(49)
+	lock, err := NewLock(ctx, id, db, WithTempTable("staging", "id no_such_type"))
+	assert.Nil(t, err)
+	defer lock.Close()
+
+	acquired, err := lock.Lock(ctx)
+	assert.NotNil(t, err)
+	assert.False(t, acquired)
+	assert.Equal(t, Idle, lock.Status())
+	pids, err := Holders(ctx, db, id)
+	assert.Nil(t, err)
+	assert.Empty(t, pids)
+}
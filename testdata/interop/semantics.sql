@@ -0,0 +1,52 @@
+-- Advisory lock semantics every implementation sharing keys with pglock must agree on.
+-- Run it on a single session, for example with psql -v ON_ERROR_STOP=1 -f semantics.sql.
+-- Each check raises an exception when the server behaves differently.
+
+SELECT pg_advisory_unlock_all();
+
+-- A bigint key is shown in pg_locks as classid = high 32 bits, objid = low 32 bits, objsubid = 1.
+SELECT pg_advisory_lock(-4294967297);
+DO $$
+BEGIN
+	IF NOT EXISTS (SELECT 1 FROM pg_locks WHERE locktype = 'advisory' AND pid = pg_backend_pid()
+		AND classid::bigint = 4294967294 AND objid::bigint = 4294967295 AND objsubid = 1) THEN
+		RAISE EXCEPTION 'bigint key layout';
+	END IF;
+END $$;
+
+-- Session locks stack: they must be unlocked as many times as they were locked.
+SELECT pg_advisory_lock(-4294967297);
+DO $$
+BEGIN
+	IF NOT (pg_advisory_unlock(-4294967297) AND pg_advisory_unlock(-4294967297)) THEN
+		RAISE EXCEPTION 'stacked unlock';
+	END IF;
+	IF pg_advisory_unlock(-4294967297) THEN
+		RAISE EXCEPTION 'unlock of a free key';
+	END IF;
+END $$;
+
+-- The two int4 key form uses its own key space (objsubid = 2), it does not conflict with PairKey(hi, lo).
+SELECT pg_advisory_lock(-2, -1);
+DO $$
+BEGIN
+	IF NOT EXISTS (SELECT 1 FROM pg_locks WHERE locktype = 'advisory' AND pid = pg_backend_pid()
+		AND classid::bigint = 4294967294 AND objid::bigint = 4294967295 AND objsubid = 2) THEN
+		RAISE EXCEPTION 'two key layout';
+	END IF;
+	IF pg_advisory_unlock(-4294967297) THEN
+		RAISE EXCEPTION 'two key form shares the bigint key space';
+	END IF;
+END $$;
+SELECT pg_advisory_unlock(-2, -1);
+
+-- Shared locks are shown with mode ShareLock, exclusive ones with ExclusiveLock.
+SELECT pg_advisory_lock_shared(4294967298);
+DO $$
+BEGIN
+	IF NOT EXISTS (SELECT 1 FROM pg_locks WHERE locktype = 'advisory' AND pid = pg_backend_pid()
+		AND classid::bigint = 1 AND objid::bigint = 2 AND objsubid = 1 AND mode = 'ShareLock') THEN
+		RAISE EXCEPTION 'shared lock mode';
+	END IF;
+END $$;
+SELECT pg_advisory_unlock_shared(4294967298);
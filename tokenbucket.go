@@ -0,0 +1,114 @@
+package pglock
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+)
+
+// TokenBucketLimiter is a cluster wide token bucket rate limiter.
+// Buckets are stored per key in a table, and updates to a key are serialized with a transaction level advisory lock.
+type TokenBucketLimiter struct {
+	db    *sql.DB
+	table string
+	ns    Namespace
+	clock Clock
+}
+
+// NewTokenBucketLimiter returns a TokenBucketLimiter that stores its buckets in table, see TokenBucketLimiterTable.
+// opts set the clock Wait sleeps on, see WithClock.
+func NewTokenBucketLimiter(ctx context.Context, db *sql.DB, table string, opts ...Option) (*TokenBucketLimiter, error) {
+	name, err := openTable(ctx, db, TokenBucketLimiterTable(table))
+	if err != nil {
+		return nil, err
+	}
+	return &TokenBucketLimiter{db: db, table: name, ns: NS("pglock").NS("tokenbucket").NS(table), clock: timing(opts).state.clock}, nil
+}
+
+// Allow takes a token from the bucket of key if one is available.
+// The bucket refills at rate tokens per second and holds at most burst tokens, a new bucket starts full.
+// If the bucket cannot be read or updated the token is refused and the error is returned.
+func (r *TokenBucketLimiter) Allow(ctx context.Context, key string, rate float64, burst int) (bool, error) {
+	taken, _, err := r.take(ctx, key, rate, burst)
+	if err != nil {
+		return false, err
+	}
+	return taken, nil
+}
+
+// Wait blocks until a token from the bucket of key is available and takes it, or until ctx is done.
+func (r *TokenBucketLimiter) Wait(ctx context.Context, key string, rate float64, burst int) error {
+	for {
+		taken, wait, err := r.take(ctx, key, rate, burst)
+		if err != nil || taken {
+			return err
+		}
+		timer := r.clock.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C():
+		}
+	}
+}
+
+// take takes a token if one is available, otherwise it returns how long until the next token.
+func (r *TokenBucketLimiter) take(ctx context.Context, key string, rate float64, burst int) (bool, time.Duration, error) {
+	if rate <= 0 || burst <= 0 {
+		return false, 0, errors.New("pglock: token bucket rate and burst must be positive")
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, 0, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if _, err := tx.ExecContext(ctx, "SELECT pg_advisory_xact_lock($1)", r.ns.Key(key).ID); err != nil {
+		return false, 0, err
+	}
+
+	// clock_timestamp, unlike now, is not frozen at the start of the transaction, which may be before the lock was granted.
+	now := int64(0)
+	if err := tx.QueryRowContext(ctx, "SELECT (extract(epoch FROM clock_timestamp()) * 1000)::bigint").Scan(&now); err != nil {
+		return false, 0, err
+	}
+
+	tokens, updatedAt := float64(burst), now
+	sqlQuery := fmt.Sprintf("SELECT tokens, updated_at FROM %s WHERE key = $1", r.table)
+	err = tx.QueryRowContext(ctx, sqlQuery, key).Scan(&tokens, &updatedAt)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return false, 0, err
+	}
+	if elapsed := now - updatedAt; elapsed > 0 {
+		tokens += float64(elapsed) / 1000 * rate
+	}
+	tokens = math.Min(tokens, float64(burst))
+	if tokens < 1 {
+		return false, refillWait(tokens, rate), nil
+	}
+
+	sqlQuery = fmt.Sprintf(`INSERT INTO %s (key, tokens, updated_at) VALUES ($1, $2, $3)
+		ON CONFLICT (key) DO UPDATE SET tokens = EXCLUDED.tokens, updated_at = EXCLUDED.updated_at`, r.table)
+	if _, err := tx.ExecContext(ctx, sqlQuery, key, tokens-1, now); err != nil {
+		return false, 0, err
+	}
+	if err := tx.Commit(); err != nil {
+		return false, 0, err
+	}
+	return true, 0, nil
+}
+
+// refillWait returns how long a bucket holding tokens, less than one, takes to refill to one token at rate.
+// It is rounded up to at least a nanosecond, a bucket just below one token is still empty.
+func refillWait(tokens, rate float64) time.Duration {
+	wait := time.Duration(math.Ceil((1 - tokens) / rate * float64(time.Second)))
+	if wait < time.Nanosecond {
+		return time.Nanosecond
+	}
+	return wait
+}
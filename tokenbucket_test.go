@@ -0,0 +1,69 @@
+package pglock
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTokenBucketLimiter(t *testing.T) {
+	db, err := newDB()
+	assert.Nil(t, err)
+	defer closeDB(db)
+
+	ctx := context.Background()
+	assert.Nil(t, Migrate(ctx, db, TokenBucketLimiterTable("pglock_test_token_bucket")))
+	limiter, err := NewTokenBucketLimiter(ctx, db, "pglock_test_token_bucket")
+	assert.Nil(t, err)
+	defer func() {
+		_, err := db.ExecContext(ctx, "DROP TABLE pglock_test_token_bucket")
+		assert.Nil(t, err)
+	}()
+
+	rate, burst := 10.0, 2
+	for i := 0; i < burst; i++ {
+		ok, err := limiter.Allow(ctx, "sync", rate, burst)
+		assert.Nil(t, err)
+		assert.True(t, ok)
+	}
+	ok, err := limiter.Allow(ctx, "sync", rate, burst)
+	assert.Nil(t, err)
+	assert.False(t, ok)
+
+	// A token is refilled every 100 milliseconds.
+	start := time.Now()
+	err = limiter.Wait(ctx, "sync", rate, burst)
+	assert.Nil(t, err)
+	assert.True(t, time.Since(start) >= 50*time.Millisecond)
+
+	timeoutCtx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	err = limiter.Wait(timeoutCtx, "sync", rate, burst)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestTokenBucketLimiterAllowError(t *testing.T) {
+	db, err := sql.Open("postgres", "")
+	assert.Nil(t, err)
+	assert.Nil(t, db.Close())
+
+	limiter := &TokenBucketLimiter{db: db, table: quoteIdentifier("pglock_test_token_bucket"), ns: NS("pglock").NS("tokenbucket")}
+	ok, err := limiter.Allow(context.Background(), "sync", 10, 2)
+	assert.NotNil(t, err)
+	assert.False(t, ok)
+
+	ok, err = limiter.Allow(context.Background(), "sync", 0, 2)
+	assert.NotNil(t, err)
+	assert.False(t, ok)
+}
+
+func TestRefillWait(t *testing.T) {
+	assert.Equal(t, 100*time.Millisecond, refillWait(0, 10))
+	assert.Equal(t, 50*time.Millisecond, refillWait(0.5, 10))
+	// A bucket a rounding error below one token waits, instead of counting as a token taken.
+	assert.Equal(t, time.Nanosecond, refillWait(0.9999999999999999, 10))
+	assert.Equal(t, time.Nanosecond, refillWait(0.9999999999999999, 1e9))
+}
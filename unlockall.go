@@ -0,0 +1,53 @@
+package pglock
+
+import "context"
+
+// HeldLock is an advisory lock held by a session.
+type HeldLock struct {
+	// ID is the bigint key, or the two int4 keys packed like PairKey when Pair is true.
+	ID int64 `json:"id"`
+	// Pair reports whether the lock was taken with the two int4 key form, pg_advisory_lock(int, int).
+	Pair bool `json:"pair"`
+	// Shared reports whether the lock is held in shared mode.
+	Shared bool `json:"shared"`
+}
+
+// UnlockAll releases every session level advisory lock held by the lock session with a single
+// pg_advisory_unlock_all, and returns the locks that were held. It takes two round trips however many keys are held.
+// Stacked acquisitions of a key are reported once.
+func (l *Lock) UnlockAll(ctx context.Context) ([]HeldLock, error) {
+	held, err := l.heldLocks(ctx)
+	if err != nil {
+		l.state.observe(err)
+		return nil, err
+	}
+	if err := l.releaseAll(ctx); err != nil {
+		return nil, err
+	}
+	return held, nil
+}
+
+// heldLocks returns the advisory locks held by the lock session.
+func (l *Lock) heldLocks(ctx context.Context) ([]HeldLock, error) {
+	// See Holders for how bigint keys are stored in pg_locks, the two key form uses objsubid = 2.
+	sqlQuery := `SELECT classid::bigint, objid::bigint, objsubid = 2, mode = 'ShareLock' FROM pg_locks
+		WHERE locktype = 'advisory' AND granted AND pid = pg_backend_pid()
+		ORDER BY 1, 2, 3, 4`
+	rows, err := l.conn.QueryContext(ctx, sqlQuery)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	held := []HeldLock{}
+	for rows.Next() {
+		var classid, objid int64
+		lock := HeldLock{}
+		if err := rows.Scan(&classid, &objid, &lock.Pair, &lock.Shared); err != nil {
+			return nil, err
+		}
+		lock.ID = int64(uint64(classid)<<32 | uint64(objid))
+		held = append(held, lock)
+	}
+	return held, rows.Err()
+}
@@ -0,0 +1,41 @@
+package pglock
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUnlockAll(t *testing.T) {
+	db1, err := newDB()
+	assert.Nil(t, err)
+	defer closeDB(db1)
+	db2, err := newDB()
+	assert.Nil(t, err)
+	defer closeDB(db2)
+
+	ctx := context.Background()
+	id := int64(-4294967257) // exercises both halves of the key
+	lock, err := NewLock(ctx, id, db1)
+	assert.Nil(t, err)
+	defer lock.Close()
+
+	assert.Nil(t, lock.WaitAndLock(ctx))
+	assert.Nil(t, lock.WaitAndLock(ctx))
+	hi, lo := SplitKey(id)
+	_, err = lock.conn.ExecContext(ctx, "SELECT pg_advisory_lock_shared($1, $2)", hi, lo)
+	assert.Nil(t, err)
+
+	held, err := lock.UnlockAll(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, []HeldLock{{ID: id}, {ID: id, Pair: true, Shared: true}}, held)
+	assert.Equal(t, Idle, lock.Status())
+	pids, err := Holders(ctx, db2, id)
+	assert.Nil(t, err)
+	assert.Empty(t, pids)
+
+	held, err = lock.UnlockAll(ctx)
+	assert.Nil(t, err)
+	assert.Empty(t, held)
+}
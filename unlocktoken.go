@@ -0,0 +1,127 @@
+package pglock
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+)
+
+// ErrInvalidUnlockToken is returned by UnlockTokens.Redeem for unknown or already redeemed tokens.
+var ErrInvalidUnlockToken = errors.New("pglock: invalid unlock token")
+
+// UnlockTokens lets a lock holder mint one-time tokens that another process can redeem to release the lock,
+// for orchestrators that must clean up after stuck workers without a blanket permission to terminate sessions.
+// Advisory locks can only be released by the session holding them, so the holder watches its tokens and releases
+// the lock itself when one is redeemed. Redeeming only needs access to the token table, and only releases the
+// lock id the token was minted for, other locks of the session are kept.
+// Tokens are stored in a table.
+type UnlockTokens struct {
+	db    *sql.DB
+	table string
+}
+
+// NewUnlockTokens returns an UnlockTokens that stores its tokens in table, see UnlockTokensTable.
+func NewUnlockTokens(ctx context.Context, db *sql.DB, table string) (*UnlockTokens, error) {
+	name, err := openTable(ctx, db, UnlockTokensTable(table))
+	if err != nil {
+		return nil, err
+	}
+	return &UnlockTokens{db: db, table: name}, nil
+}
+
+// Mint returns a token releasing the exclusive lock l when redeemed. Until the lock stops being held, l checks
+// whether the token was redeemed every hold check interval, see WithHoldCheckInterval, and then releases every
+// stacked exclusive acquisition of its id. The token is deleted when the lock stops being held.
+// Mint returns ErrNotHeld if l is not held exclusively.
+func (t *UnlockTokens) Mint(ctx context.Context, l *Lock) (string, error) {
+	raw := make([]byte, 16)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	token := hex.EncodeToString(raw)
+	sqlQuery := fmt.Sprintf("INSERT INTO %s (token, id) VALUES ($1, $2)", t.table)
+	if _, err := t.db.ExecContext(ctx, sqlQuery, token, l.id); err != nil {
+		return "", err
+	}
+
+	holdCtx, cancel := context.WithCancel(context.Background())
+	if !l.state.addHold(cancel) {
+		cancel()
+		t.delete(token)
+		return "", ErrNotHeld
+	}
+	go t.watch(holdCtx, l, token)
+	return token, nil
+}
+
+// watch releases l when token is redeemed, until holdCtx is done. The token is deleted when the watch ends.
+func (t *UnlockTokens) watch(holdCtx context.Context, l *Lock, token string) {
+	defer t.delete(token)
+	interval := l.holdCheckInterval
+	if interval <= 0 {
+		interval = defaultHoldCheckInterval
+	}
+	ticker := l.state.clock.NewTicker(interval)
+	defer ticker.Stop()
+
+	sqlQuery := fmt.Sprintf("SELECT redeemed_at IS NOT NULL FROM %s WHERE token = $1", t.table)
+	for {
+		select {
+		case <-holdCtx.Done():
+			return
+		case <-ticker.C():
+			redeemed := false
+			err := t.db.QueryRowContext(holdCtx, sqlQuery, token).Scan(&redeemed)
+			if errors.Is(err, sql.ErrNoRows) {
+				return
+			}
+			if err == nil && redeemed {
+				l.releaseExclusive()
+				return
+			}
+		}
+	}
+}
+
+// delete removes token from the table.
+func (t *UnlockTokens) delete(token string) {
+	sqlQuery := fmt.Sprintf("DELETE FROM %s WHERE token = $1", t.table)
+	_, _ = t.db.ExecContext(context.Background(), sqlQuery, token)
+}
+
+// releaseExclusive releases every stacked exclusive acquisition of the lock.
+func (l *Lock) releaseExclusive() {
+	for {
+		l.state.mu.Lock()
+		depth := l.state.depth
+		l.state.mu.Unlock()
+		if depth == 0 {
+			return
+		}
+		if err := l.Unlock(context.Background()); err != nil {
+			return
+		}
+	}
+}
+
+// Redeem consumes token, asking the holder to release the lock it was minted for. The holder releases it within
+// its hold check interval, use Holders to wait for the release. Redeem returns ErrInvalidUnlockToken for unknown
+// or already redeemed tokens, and for tokens of locks that stopped being held.
+func (t *UnlockTokens) Redeem(ctx context.Context, token string) error {
+	sqlQuery := fmt.Sprintf("UPDATE %s SET redeemed_at = clock_timestamp() WHERE token = $1 AND redeemed_at IS NULL", t.table)
+	result, err := t.db.ExecContext(ctx, sqlQuery, token)
+	if err != nil {
+		return err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrInvalidUnlockToken
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+package pglock
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUnlockTokens(t *testing.T) {
+	db1, err := newDB()
+	assert.Nil(t, err)
+	defer closeDB(db1)
+	db2, err := newDB()
+	assert.Nil(t, err)
+	defer closeDB(db2)
+
+	ctx := context.Background()
+	assert.Nil(t, Migrate(ctx, db2, UnlockTokensTable("pglock_test_unlock_tokens")))
+	tokens, err := NewUnlockTokens(ctx, db2, "pglock_test_unlock_tokens")
+	assert.Nil(t, err)
+	defer func() {
+		_, err := db2.ExecContext(ctx, "DROP TABLE pglock_test_unlock_tokens")
+		assert.Nil(t, err)
+	}()
+
+	id := int64(41)
+	lock, err := NewLock(ctx, id, db1, WithHoldCheckInterval(10*time.Millisecond))
+	assert.Nil(t, err)
+	defer lock.Close()
+
+	_, err = tokens.Mint(ctx, &lock)
+	assert.Equal(t, ErrNotHeld, err)
+
+	assert.Nil(t, lock.WaitAndLock(ctx))
+	assert.Nil(t, lock.WaitAndLock(ctx))
+	pid, err := lock.PID(ctx)
+	assert.Nil(t, err)
+	token, err := tokens.Mint(ctx, &lock)
+	assert.Nil(t, err)
+	assert.Len(t, token, 32)
+	unused, err := tokens.Mint(ctx, &lock)
+	assert.Nil(t, err)
+
+	assert.Nil(t, tokens.Redeem(ctx, token))
+	assert.Equal(t, ErrInvalidUnlockToken, tokens.Redeem(ctx, token))
+	// The holder releases every stacked acquisition itself, its session is kept.
+	assert.Eventually(t, func() bool {
+		pids, err := Holders(ctx, db2, id)
+		return err == nil && len(pids) == 0
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, Idle, lock.Status())
+	current, err := lock.PID(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, pid, current)
+
+	// The lock is not held anymore, so the other token was deleted.
+	assert.Eventually(t, func() bool {
+		count := -1
+		err := db2.QueryRowContext(ctx, "SELECT count(*) FROM pglock_test_unlock_tokens").Scan(&count)
+		return err == nil && count == 0
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, ErrInvalidUnlockToken, tokens.Redeem(ctx, unused))
+}
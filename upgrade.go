@@ -0,0 +1,86 @@
+package pglock
+
+import (
+	"context"
+	"strconv"
+)
+
+// UpgradableRLock is a shared lock that can be upgraded to exclusive without releasing it.
+// At most one session holds the upgradable lock at a time, concurrently with ordinary readers using RLock or WaitAndRLock,
+// so Upgrade can never deadlock against another upgrader.
+//
+// The upgrade slot is an exclusive advisory lock on the id NS("pglock").NS("upgrade").Key(id in decimal).ID, so it stays in
+// the bigint key space and does not conflict with applications using the two int4 key form. The slot is tracked by the
+// lock, so WithAutoUnlockOnClose does not count it as a leaked lock.
+//
+// An UpgradableRLock is not safe for concurrent use.
+type UpgradableRLock struct {
+	lock     *Lock
+	upgraded bool
+}
+
+// NewUpgradableRLock returns an UpgradableRLock using the session of lock.
+func NewUpgradableRLock(lock *Lock) *UpgradableRLock {
+	return &UpgradableRLock{lock: lock}
+}
+
+// WaitAndRLock waits for the upgrade slot, then obtains the lock in shared mode.
+func (u *UpgradableRLock) WaitAndRLock(ctx context.Context) error {
+	if _, err := u.lock.conn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", upgradeSlot(u.lock.id)); err != nil {
+		u.lock.state.observe(err)
+		return err
+	}
+	u.lock.state.addSlot(1)
+	if err := u.lock.WaitAndRLock(ctx); err != nil {
+		_ = u.releaseSlot(context.Background())
+		return err
+	}
+	u.upgraded = false
+	return nil
+}
+
+// Upgrade converts the shared lock into an exclusive one, waiting for the ordinary readers to release it.
+func (u *UpgradableRLock) Upgrade(ctx context.Context) error {
+	if u.upgraded {
+		return nil
+	}
+	// The session already holds the shared lock, which does not conflict with its own exclusive request.
+	if err := u.lock.WaitAndLock(ctx); err != nil {
+		return err
+	}
+	u.upgraded = true
+	return u.lock.RUnlock(ctx)
+}
+
+// Unlock releases the lock, shared or upgraded, and the upgrade slot.
+func (u *UpgradableRLock) Unlock(ctx context.Context) error {
+	var err error
+	if u.upgraded {
+		err = u.lock.Unlock(ctx)
+	} else {
+		err = u.lock.RUnlock(ctx)
+	}
+	if err != nil {
+		return err
+	}
+	u.upgraded = false
+	return u.releaseSlot(ctx)
+}
+
+func (u *UpgradableRLock) releaseSlot(ctx context.Context) error {
+	released := false
+	err := u.lock.conn.QueryRowContext(ctx, "SELECT pg_advisory_unlock($1)", upgradeSlot(u.lock.id)).Scan(&released)
+	if err != nil {
+		u.lock.state.observe(err)
+		return err
+	}
+	if released {
+		u.lock.state.addSlot(-1)
+	}
+	return nil
+}
+
+// upgradeSlot returns the id of the upgrade slot of the lock id.
+func upgradeSlot(id int64) int64 {
+	return NS("pglock").NS("upgrade").Key(strconv.FormatInt(id, 10)).ID
+}
@@ -0,0 +1,96 @@
+package pglock
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUpgradableRLock(t *testing.T) {
+	db1, err := newDB()
+	assert.Nil(t, err)
+	defer closeDB(db1)
+	db2, err := newDB()
+	assert.Nil(t, err)
+	defer closeDB(db2)
+	db3, err := newDB()
+	assert.Nil(t, err)
+	defer closeDB(db3)
+
+	ctx := context.Background()
+	id := int64(24)
+	lock1, err := NewLock(ctx, id, db1)
+	assert.Nil(t, err)
+	defer lock1.Close()
+	lock2, err := NewLock(ctx, id, db2)
+	assert.Nil(t, err)
+	defer lock2.Close()
+	reader, err := NewLock(ctx, id, db3)
+	assert.Nil(t, err)
+	defer reader.Close()
+
+	upgradable1 := NewUpgradableRLock(&lock1)
+	upgradable2 := NewUpgradableRLock(&lock2)
+
+	// An upgradable reader coexists with ordinary readers.
+	assert.Nil(t, upgradable1.WaitAndRLock(ctx))
+	assert.Nil(t, reader.WaitAndRLock(ctx))
+
+	// But not with another upgradable reader.
+	timeoutCtx, cancel := context.WithTimeout(ctx, 100*time.Millisecond)
+	defer cancel()
+	assert.NotNil(t, upgradable2.WaitAndRLock(timeoutCtx))
+
+	// Upgrade waits for the ordinary readers.
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		if err := reader.RUnlock(ctx); err != nil {
+			t.Error(err)
+		}
+	}()
+	assert.Nil(t, upgradable1.Upgrade(ctx))
+	assert.Equal(t, HeldExclusive, lock1.Status())
+	ok, err := reader.RLock(ctx)
+	assert.Nil(t, err)
+	assert.False(t, ok)
+
+	assert.Nil(t, upgradable1.Unlock(ctx))
+	assert.Equal(t, Idle, lock1.Status())
+	assert.Nil(t, upgradable2.WaitAndRLock(ctx))
+	assert.Nil(t, upgradable2.Unlock(ctx))
+}
+
+func TestUpgradableRLockSlot(t *testing.T) {
+	db1, err := newDB()
+	assert.Nil(t, err)
+	defer closeDB(db1)
+	db2, err := newDB()
+	assert.Nil(t, err)
+	defer closeDB(db2)
+
+	ctx := context.Background()
+	id := int64(55)
+	leaked := 0
+	lock, err := NewLock(ctx, id, db1, WithAutoUnlockOnClose(func(_ int64, count int) { leaked = count }))
+	assert.Nil(t, err)
+	pid, err := lock.PID(ctx)
+	assert.Nil(t, err)
+
+	upgradable := NewUpgradableRLock(&lock)
+	assert.Nil(t, upgradable.WaitAndRLock(ctx))
+
+	// The slot is a bigint key, the two int4 key space is left to the application.
+	pids, err := Holders(ctx, db2, upgradeSlot(id))
+	assert.Nil(t, err)
+	assert.Equal(t, []int{pid}, pids)
+	pairs := 0
+	err = db2.QueryRowContext(ctx, "SELECT count(*) FROM pg_locks WHERE locktype = 'advisory' AND objsubid = 2 AND pid = $1", pid).Scan(&pairs)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, pairs)
+
+	// Only the shared lock is reported as leaked, not its slot.
+	assert.Nil(t, lock.Close())
+	assert.Equal(t, 1, leaked)
+}
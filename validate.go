@@ -0,0 +1,148 @@
+package pglock
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ErrValidation is matched by errors.Is for a *ValidationError.
+var ErrValidation = errors.New("pglock: validation failed")
+
+// ValidationError is returned by Validate when the probe found problems.
+type ValidationError struct {
+	// Problems describe what is wrong, see ValidationReport.Problems.
+	Problems []string
+}
+
+// Error implements the error interface.
+func (e *ValidationError) Error() string {
+	return "pglock: validation failed: " + strings.Join(e.Problems, "; ")
+}
+
+// Is reports whether target is ErrValidation.
+func (e *ValidationError) Is(target error) bool {
+	return target == ErrValidation
+}
+
+// ValidationReport is the outcome of Validate.
+// Durations are encoded to JSON as strings, for example "1.5ms".
+type ValidationReport struct {
+	// Server describes the server, see CheckServer.
+	Server ServerInfo
+	// Replica reports whether the server is a hot standby. Advisory locks work there but only between sessions of the same standby.
+	Replica bool
+	// StablePID reports whether the probe session kept its backend between statements. It does not through a connection
+	// pooler in transaction or statement mode, which breaks session level locks.
+	StablePID bool
+	// Visible reports whether the probe lock was seen from another connection of the pool.
+	Visible bool
+	// RoundTrip is the duration of a trivial query on the probe session.
+	RoundTrip time.Duration
+	// Acquire is the duration of the probe acquisition.
+	Acquire time.Duration
+	// Release is the duration of the probe release.
+	Release time.Duration
+	// Problems describe what makes advisory locks unreliable with db, empty if none was found.
+	Problems []string
+}
+
+// MarshalJSON encodes the report with durations as strings.
+func (r ValidationReport) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Server    ServerInfo `json:"server"`
+		Replica   bool       `json:"replica"`
+		StablePID bool       `json:"stable_pid"`
+		Visible   bool       `json:"visible"`
+		RoundTrip string     `json:"round_trip"`
+		Acquire   string     `json:"acquire"`
+		Release   string     `json:"release"`
+		Problems  []string   `json:"problems"`
+	}{r.Server, r.Replica, r.StablePID, r.Visible, r.RoundTrip.String(), r.Acquire.String(), r.Release.String(), r.Problems})
+}
+
+// validateKey is the probe lock of Validate.
+var validateKey = NS("pglock").Key("validate").ID
+
+// Validate checks that advisory locks work end to end with db, so misconfiguration is caught at service startup
+// instead of under production traffic. It checks the server with CheckServer, checks that the server is not a
+// hot standby, acquires and releases a probe lock, checks that the session keeps its backend as it does without a
+// pooler in transaction mode, and that the probe lock is visible from another connection of the pool.
+// Validate returns a *ValidationError along with the report when it found problems.
+func Validate(ctx context.Context, db *sql.DB) (ValidationReport, error) {
+	report := ValidationReport{Problems: []string{}}
+	server, err := CheckServer(ctx, db)
+	report.Server = server
+	var unsupported *UnsupportedServerError
+	switch {
+	case errors.As(err, &unsupported):
+		report.Problems = append(report.Problems, unsupported.Error())
+	case err != nil:
+		return report, err
+	}
+
+	if err := db.QueryRowContext(ctx, "SELECT pg_is_in_recovery()").Scan(&report.Replica); err != nil {
+		return report, err
+	}
+	if report.Replica {
+		report.Problems = append(report.Problems, "server is a hot standby, its advisory locks do not coordinate with the primary")
+	}
+
+	lock, err := NewLock(ctx, validateKey, db)
+	if err != nil {
+		return report, err
+	}
+	defer lock.Close()
+
+	start := time.Now()
+	pid := 0
+	if err := lock.conn.QueryRowContext(ctx, "SELECT pg_backend_pid()").Scan(&pid); err != nil {
+		return report, err
+	}
+	report.RoundTrip = time.Since(start)
+
+	result, err := lock.WaitAndLockWithResult(ctx)
+	report.Acquire = result.Total
+	if err != nil {
+		return report, fmt.Errorf("pglock: could not acquire probe lock: %w", err)
+	}
+
+	current := 0
+	if err := lock.conn.QueryRowContext(ctx, "SELECT pg_backend_pid()").Scan(&current); err != nil {
+		return report, err
+	}
+	report.StablePID = current == pid
+	if !report.StablePID {
+		report.Problems = append(report.Problems, "session changed backend between statements, a pooler in transaction or statement mode breaks session level locks")
+	}
+
+	if db.Stats().MaxOpenConnections == 1 {
+		report.Problems = append(report.Problems, "pool allows a single connection, a Lock keeps it for its whole life")
+	} else {
+		pids, err := Holders(ctx, db, validateKey)
+		if err != nil {
+			return report, err
+		}
+		for _, holder := range pids {
+			report.Visible = report.Visible || holder == pid
+		}
+		if !report.Visible {
+			report.Problems = append(report.Problems, "probe lock not visible from another connection, connections may reach different servers")
+		}
+	}
+
+	start = time.Now()
+	if err := lock.Unlock(ctx); err != nil {
+		return report, fmt.Errorf("pglock: could not release probe lock: %w", err)
+	}
+	report.Release = time.Since(start)
+
+	if len(report.Problems) > 0 {
+		return report, &ValidationError{Problems: report.Problems}
+	}
+	return report, nil
+}
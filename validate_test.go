@@ -0,0 +1,47 @@
+package pglock
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidationError(t *testing.T) {
+	err := error(&ValidationError{Problems: []string{"a", "b"}})
+	assert.True(t, errors.Is(err, ErrValidation))
+	assert.Equal(t, "pglock: validation failed: a; b", err.Error())
+}
+
+func TestValidationReportMarshalJSON(t *testing.T) {
+	report := ValidationReport{
+		Server:    ServerInfo{Version: 150004, Engine: EnginePostgres, AdvisoryLocks: true, WaitEvents: true},
+		StablePID: true,
+		Visible:   true,
+		RoundTrip: time.Millisecond,
+		Acquire:   2 * time.Millisecond,
+		Release:   3 * time.Millisecond,
+		Problems:  []string{},
+	}
+	data, err := json.Marshal(report)
+	assert.Nil(t, err)
+	assert.JSONEq(t, `{"server":{"version":150004,"engine":"postgres","advisory_locks":true,"wait_events":true},
+		"replica":false,"stable_pid":true,"visible":true,"round_trip":"1ms","acquire":"2ms","release":"3ms","problems":[]}`, string(data))
+}
+
+func TestValidate(t *testing.T) {
+	db, err := newDB()
+	assert.Nil(t, err)
+	defer closeDB(db)
+
+	report, err := Validate(context.Background(), db)
+	assert.Nil(t, err)
+	assert.Equal(t, EnginePostgres, report.Server.Engine)
+	assert.False(t, report.Replica)
+	assert.True(t, report.StablePID)
+	assert.True(t, report.Visible)
+	assert.Empty(t, report.Problems)
+}
@@ -0,0 +1,57 @@
+package pglock
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+)
+
+// ErrSessionNotFound is returned when the lock session is not listed in pg_stat_activity.
+var ErrSessionNotFound = errors.New("pglock: session not found in pg_stat_activity")
+
+// SessionActivity is what pg_stat_activity reports for the lock session.
+type SessionActivity struct {
+	// State is the session state, for example "active" or "idle".
+	State string `json:"state"`
+	// WaitEventType is the type of event the session is waiting for, empty if it is not waiting.
+	WaitEventType string `json:"wait_event_type"`
+	// WaitEvent is the name of the event the session is waiting for, empty if it is not waiting.
+	WaitEvent string `json:"wait_event"`
+}
+
+// WaitingOnAdvisoryLock reports whether the session is blocked waiting for an advisory lock.
+func (a SessionActivity) WaitingOnAdvisoryLock() bool {
+	return a.WaitEventType == "Lock" && a.WaitEvent == "advisory"
+}
+
+// WaitingOnIO reports whether the session is waiting on the network or the disk, rather than on a lock.
+func (a SessionActivity) WaitingOnIO() bool {
+	return a.WaitEventType == "Client" || a.WaitEventType == "IO"
+}
+
+// Activity samples pg_stat_activity for the lock session, using a separate connection from the pool since the
+// lock session may be blocked. It can be called while WaitAndLock is waiting to tell a session waiting on the
+// advisory lock from one stuck on the network or IO. WaitAndLock caches the session PID before blocking, so Activity
+// does not need the busy session.
+// With WithServerCheck it returns an *UnsupportedServerError on servers without wait events.
+func (l *Lock) Activity(ctx context.Context) (SessionActivity, error) {
+	activity := SessionActivity{}
+	if err := l.requireWaitEvents(); err != nil {
+		return activity, err
+	}
+	pid, err := l.PID(ctx)
+	if err != nil {
+		return activity, err
+	}
+
+	var state, waitEventType, waitEvent sql.NullString
+	sqlQuery := "SELECT state, wait_event_type, wait_event FROM pg_stat_activity WHERE pid = $1"
+	err = l.inspectDB().QueryRowContext(ctx, sqlQuery, pid).Scan(&state, &waitEventType, &waitEvent)
+	if errors.Is(err, sql.ErrNoRows) {
+		return activity, ErrSessionNotFound
+	}
+	activity.State = state.String
+	activity.WaitEventType = waitEventType.String
+	activity.WaitEvent = waitEvent.String
+	return activity, err
+}
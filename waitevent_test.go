@@ -0,0 +1,50 @@
+package pglock
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestActivity(t *testing.T) {
+	db1, err := newDB()
+	assert.Nil(t, err)
+	defer closeDB(db1)
+	db2, err := newDB()
+	assert.Nil(t, err)
+	defer closeDB(db2)
+
+	ctx := context.Background()
+	id := int64(15)
+	lock1, err := NewLock(ctx, id, db1)
+	assert.Nil(t, err)
+	defer lock1.Close()
+	lock2, err := NewLock(ctx, id, db2)
+	assert.Nil(t, err)
+	defer lock2.Close()
+
+	activity, err := lock1.Activity(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, "idle", activity.State)
+	assert.False(t, activity.WaitingOnAdvisoryLock())
+
+	err = lock1.WaitAndLock(ctx)
+	assert.Nil(t, err)
+
+	// lock2 has not used its session before blocking, WaitAndLock caches its PID.
+	done := make(chan error)
+	go func() { done <- lock2.WaitAndLock(ctx) }()
+	time.Sleep(200 * time.Millisecond)
+
+	activity, err = lock2.Activity(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, "active", activity.State)
+	assert.True(t, activity.WaitingOnAdvisoryLock())
+	assert.False(t, activity.WaitingOnIO())
+
+	assert.Nil(t, lock1.Unlock(ctx))
+	assert.Nil(t, <-done)
+	assert.Nil(t, lock2.Unlock(ctx))
+}
@@ -0,0 +1,42 @@
+package pglock
+
+import (
+	"context"
+	"database/sql"
+)
+
+// WithXactLock obtains exclusive transaction level advisory lock on tx, waiting if needed, and runs fn.
+// The lock is held until tx commits or rolls back, there is no explicit unlock.
+func WithXactLock(ctx context.Context, tx *sql.Tx, id int64, fn func(tx *sql.Tx) error) error {
+	if _, err := tx.ExecContext(ctx, "SELECT pg_advisory_xact_lock($1)", id); err != nil {
+		return err
+	}
+	return fn(tx)
+}
+
+// WithXactRLock obtains shared transaction level advisory lock on tx, waiting if needed, and runs fn.
+// Shared holders do not block each other, only exclusive ones, so read-mostly transactions can run concurrently while still excluding
+// exclusive holders such as migrations using WithXactLock or Lock on the same id.
+// The lock is held until tx commits or rolls back, there is no explicit unlock.
+func WithXactRLock(ctx context.Context, tx *sql.Tx, id int64, fn func(tx *sql.Tx) error) error {
+	if _, err := tx.ExecContext(ctx, "SELECT pg_advisory_xact_lock_shared($1)", id); err != nil {
+		return err
+	}
+	return fn(tx)
+}
+
+// TryXactLock obtains exclusive transaction level advisory lock on tx if available, without waiting.
+// It returns false if the lock cannot be acquired immediately. The lock is held until tx commits or rolls back.
+func TryXactLock(ctx context.Context, tx *sql.Tx, id int64) (bool, error) {
+	result := false
+	err := tx.QueryRowContext(ctx, "SELECT pg_try_advisory_xact_lock($1)", id).Scan(&result)
+	return result, err
+}
+
+// TryXactRLock obtains shared transaction level advisory lock on tx if available, without waiting.
+// It returns false if the lock cannot be acquired immediately. The lock is held until tx commits or rolls back.
+func TryXactRLock(ctx context.Context, tx *sql.Tx, id int64) (bool, error) {
+	result := false
+	err := tx.QueryRowContext(ctx, "SELECT pg_try_advisory_xact_lock_shared($1)", id).Scan(&result)
+	return result, err
+}
@@ -0,0 +1,114 @@
+package pglock
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWithXactRLock(t *testing.T) {
+	db1, err := newDB()
+	assert.Nil(t, err)
+	defer closeDB(db1)
+	db2, err := newDB()
+	assert.Nil(t, err)
+	defer closeDB(db2)
+
+	ctx := context.Background()
+	id := int64(19)
+	lock, err := NewLock(ctx, id, db2)
+	assert.Nil(t, err)
+	defer lock.Close()
+
+	tx1, err := db1.BeginTx(ctx, nil)
+	assert.Nil(t, err)
+	tx2, err := db1.BeginTx(ctx, nil)
+	assert.Nil(t, err)
+
+	// Shared holders do not block each other.
+	err = WithXactRLock(ctx, tx1, id, func(tx *sql.Tx) error { return nil })
+	assert.Nil(t, err)
+	err = WithXactRLock(ctx, tx2, id, func(tx *sql.Tx) error { return nil })
+	assert.Nil(t, err)
+
+	// But they exclude exclusive holders until the transactions end.
+	ok, err := lock.Lock(ctx)
+	assert.Nil(t, err)
+	assert.False(t, ok)
+
+	assert.Nil(t, tx1.Commit())
+	assert.Nil(t, tx2.Rollback())
+	ok, err = lock.Lock(ctx)
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	assert.Nil(t, lock.Unlock(ctx))
+}
+
+func TestWithXactLock(t *testing.T) {
+	db1, err := newDB()
+	assert.Nil(t, err)
+	defer closeDB(db1)
+	db2, err := newDB()
+	assert.Nil(t, err)
+	defer closeDB(db2)
+
+	ctx := context.Background()
+	id := int64(19)
+	lock, err := NewLock(ctx, id, db2)
+	assert.Nil(t, err)
+	defer lock.Close()
+
+	tx, err := db1.BeginTx(ctx, nil)
+	assert.Nil(t, err)
+	called := false
+	err = WithXactLock(ctx, tx, id, func(tx *sql.Tx) error {
+		called = true
+		return nil
+	})
+	assert.Nil(t, err)
+	assert.True(t, called)
+
+	ok, err := lock.Lock(ctx)
+	assert.Nil(t, err)
+	assert.False(t, ok)
+
+	assert.Nil(t, tx.Commit())
+	ok, err = lock.Lock(ctx)
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	assert.Nil(t, lock.Unlock(ctx))
+}
+
+func TestTryXactLock(t *testing.T) {
+	db, err := newDB()
+	assert.Nil(t, err)
+	defer closeDB(db)
+
+	ctx := context.Background()
+	id := int64(21)
+	tx1, err := db.BeginTx(ctx, nil)
+	assert.Nil(t, err)
+	tx2, err := db.BeginTx(ctx, nil)
+	assert.Nil(t, err)
+	tx3, err := db.BeginTx(ctx, nil)
+	assert.Nil(t, err)
+
+	ok, err := TryXactRLock(ctx, tx1, id)
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	ok, err = TryXactRLock(ctx, tx2, id)
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	ok, err = TryXactLock(ctx, tx3, id)
+	assert.Nil(t, err)
+	assert.False(t, ok)
+
+	assert.Nil(t, tx1.Rollback())
+	assert.Nil(t, tx2.Rollback())
+	ok, err = TryXactLock(ctx, tx3, id)
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	assert.Nil(t, tx3.Rollback())
+}
@@ -0,0 +1,106 @@
+package pglock
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// XactLockWarning reports a transaction holding advisory locks for longer than the configured threshold.
+type XactLockWarning struct {
+	// PID is the backend process id of the session running the transaction.
+	PID int
+	// IDs are the advisory lock ids held by the session, in ascending order.
+	IDs []int64
+	// Start is when the transaction started.
+	Start time.Time
+	// Age is how long the transaction has been open.
+	Age time.Duration
+	// State is the state of the session, for example "idle in transaction".
+	State string
+	// Query is the last query run by the session.
+	Query string
+	// Err is the error of the poll, if any. The other fields are empty when Err is set.
+	Err error
+}
+
+// LongXactLocks returns the transactions open for longer than threshold whose session holds advisory locks, oldest first.
+// A forgotten open transaction is the most common way transaction level locks taken with WithXactLock go wrong, since they are
+// only released on commit or rollback. pg_locks does not tell transaction level locks from session level ones, so a session
+// level lock held by a session inside a long transaction is reported too.
+func LongXactLocks(ctx context.Context, db *sql.DB, threshold time.Duration) ([]XactLockWarning, error) {
+	// See Holders for how bigint keys are stored in pg_locks.
+	sqlQuery := `SELECT l.pid, l.classid::bigint, l.objid::bigint, a.xact_start,
+		extract(epoch FROM clock_timestamp() - a.xact_start), coalesce(a.state, ''), coalesce(a.query, '')
+		FROM pg_locks l JOIN pg_stat_activity a ON a.pid = l.pid
+		WHERE l.locktype = 'advisory' AND l.granted AND l.objsubid = 1
+		AND l.database = (SELECT oid FROM pg_database WHERE datname = current_database())
+		AND a.xact_start < clock_timestamp() - $1 * interval '1 second'
+		ORDER BY a.xact_start, l.pid, 2, 3`
+	rows, err := db.QueryContext(ctx, sqlQuery, threshold.Seconds())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	warnings := []XactLockWarning{}
+	for rows.Next() {
+		var classid, objid int64
+		var age float64
+		warning := XactLockWarning{}
+		if err := rows.Scan(&warning.PID, &classid, &objid, &warning.Start, &age, &warning.State, &warning.Query); err != nil {
+			return nil, err
+		}
+		id := int64(uint64(classid)<<32 | uint64(objid))
+		if n := len(warnings); n > 0 && warnings[n-1].PID == warning.PID && warnings[n-1].Start.Equal(warning.Start) {
+			warnings[n-1].IDs = append(warnings[n-1].IDs, id)
+			continue
+		}
+		warning.IDs = []int64{id}
+		warning.Age = time.Duration(age * float64(time.Second))
+		warnings = append(warnings, warning)
+	}
+	return warnings, rows.Err()
+}
+
+// WatchXactLocks runs LongXactLocks every interval until ctx is done, passing each long transaction to warn once,
+// the first time it is seen over threshold. Failed polls are passed to warn with Err set, and the next polls are
+// spaced by the delays of WithBackoff when they are longer than interval. opts only set the timing of the watch,
+// WithClock and WithBackoff.
+func WatchXactLocks(ctx context.Context, db *sql.DB, threshold, interval time.Duration, warn func(XactLockWarning), opts ...Option) {
+	type xact struct {
+		pid   int
+		start time.Time
+	}
+	warned := map[xact]bool{}
+
+	l := timing(opts)
+	failures := retrier{l: l}
+	delay := interval
+	for {
+		if err := l.sleep(ctx, delay); err != nil {
+			return
+		}
+		warnings, err := LongXactLocks(ctx, db, threshold)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			warn(XactLockWarning{Err: err})
+			delay = maxDuration(interval, failures.fail())
+			continue
+		}
+		failures.reset()
+		delay = interval
+		// Only keep the transactions still open, so the map does not grow with every transaction ever reported.
+		open := make(map[xact]bool, len(warnings))
+		for _, warning := range warnings {
+			key := xact{pid: warning.PID, start: warning.Start}
+			open[key] = true
+			if !warned[key] {
+				warn(warning)
+			}
+		}
+		warned = open
+	}
+}
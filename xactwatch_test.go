@@ -0,0 +1,81 @@
+package pglock
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLongXactLocks(t *testing.T) {
+	db1, err := newDB()
+	assert.Nil(t, err)
+	defer closeDB(db1)
+	db2, err := newDB()
+	assert.Nil(t, err)
+	defer closeDB(db2)
+
+	ctx := context.Background()
+	id := int64(45)
+	tx, err := db1.BeginTx(ctx, nil)
+	assert.Nil(t, err)
+	assert.Nil(t, WithXactLock(ctx, tx, id, func(tx *sql.Tx) error { return nil }))
+	var pid int
+	assert.Nil(t, tx.QueryRowContext(ctx, "SELECT pg_backend_pid()").Scan(&pid))
+
+	warnings := make(chan XactLockWarning, 10)
+	watchCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go WatchXactLocks(watchCtx, db2, 100*time.Millisecond, 50*time.Millisecond, func(warning XactLockWarning) {
+		warnings <- warning
+	})
+
+	select {
+	case warning := <-warnings:
+		assert.Nil(t, warning.Err)
+		assert.Equal(t, pid, warning.PID)
+		assert.Equal(t, []int64{id}, warning.IDs)
+		assert.True(t, warning.Age >= 100*time.Millisecond)
+		assert.Equal(t, "idle in transaction", warning.State)
+		assert.Equal(t, "SELECT pg_backend_pid()", warning.Query)
+	case <-time.After(2 * time.Second):
+		t.Fatal("long transaction was not reported")
+	}
+
+	// Each transaction is reported once.
+	time.Sleep(200 * time.Millisecond)
+	assert.Len(t, warnings, 0)
+
+	assert.Nil(t, tx.Rollback())
+	held, err := LongXactLocks(ctx, db2, 0)
+	assert.Nil(t, err)
+	for _, warning := range held {
+		assert.NotEqual(t, pid, warning.PID)
+	}
+}
+
+func TestWatchXactLocksBackoff(t *testing.T) {
+	db, err := sql.Open("postgres", "")
+	assert.Nil(t, err)
+	assert.Nil(t, db.Close())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	attempts := []int{}
+	backoff := BackoffFunc(func(attempt int, _ time.Duration) time.Duration {
+		attempts = append(attempts, attempt)
+		return time.Millisecond
+	})
+	failures := 0
+	WatchXactLocks(ctx, db, time.Second, time.Millisecond, func(warning XactLockWarning) {
+		assert.NotNil(t, warning.Err)
+		failures++
+		if failures == 3 {
+			cancel()
+		}
+	}, WithBackoff(backoff))
+
+	// Each failed poll is followed by the next backoff delay.
+	assert.Equal(t, []int{1, 2, 3}, attempts)
+}
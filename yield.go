@@ -0,0 +1,38 @@
+package pglock
+
+import (
+	"context"
+	"time"
+)
+
+// WithTimeSlice sets how long the lock is held before Yield gives it up to waiting sessions, see Yield.
+func WithTimeSlice(slice time.Duration) Option {
+	return func(l *Lock) {
+		l.timeSlice = slice
+	}
+}
+
+// Yield shares a long contended lock between batch jobs that can checkpoint, in rough round-robin order.
+// It is meant to be called at checkpoints while the lock is held: once the lock has been held for the time slice set with
+// WithTimeSlice, if other sessions are waiting for it (as seen in pg_locks), Yield releases the lock and waits for it again,
+// queuing behind them. It reports whether the lock was released. Yield only releases a single exclusive acquisition,
+// it does nothing if the lock is not held exclusively or if acquisitions are stacked.
+// If waiting for the lock again fails the lock is not held anymore and the error is returned.
+func (l *Lock) Yield(ctx context.Context) (bool, error) {
+	l.state.mu.Lock()
+	single := l.state.state == HeldExclusive && l.state.depth == 1 && l.state.shared == 0
+	held := l.now().Sub(l.state.since)
+	l.state.mu.Unlock()
+	if !single || held < l.timeSlice {
+		return false, nil
+	}
+
+	waiters, err := l.waiters(ctx)
+	if err != nil || waiters == 0 {
+		return false, err
+	}
+	if err := l.Unlock(ctx); err != nil {
+		return false, err
+	}
+	return true, l.WaitAndLock(ctx)
+}
@@ -0,0 +1,65 @@
+package pglock
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestYield(t *testing.T) {
+	db1, err := newDB()
+	assert.Nil(t, err)
+	defer closeDB(db1)
+	db2, err := newDB()
+	assert.Nil(t, err)
+	defer closeDB(db2)
+
+	ctx := context.Background()
+	id := int64(47)
+	batch, err := NewLock(ctx, id, db1, WithTimeSlice(100*time.Millisecond))
+	assert.Nil(t, err)
+	defer batch.Close()
+	other, err := NewLock(ctx, id, db2)
+	assert.Nil(t, err)
+	defer other.Close()
+
+	yielded, err := batch.Yield(ctx)
+	assert.Nil(t, err)
+	assert.False(t, yielded)
+
+	assert.Nil(t, batch.WaitAndLock(ctx))
+	acquired := make(chan error)
+	go func() {
+		acquired <- other.WaitAndLock(ctx)
+	}()
+	assert.Eventually(t, func() bool {
+		waiters, err := Waiters(ctx, db1, id)
+		return err == nil && waiters == 1
+	}, 2*time.Second, 10*time.Millisecond)
+
+	// The time slice is not used up yet.
+	yielded, err = batch.Yield(ctx)
+	assert.Nil(t, err)
+	assert.False(t, yielded)
+
+	time.Sleep(100 * time.Millisecond)
+	done := make(chan bool)
+	go func() {
+		yielded, err := batch.Yield(ctx)
+		assert.Nil(t, err)
+		done <- yielded
+	}()
+	assert.Nil(t, <-acquired)
+	assert.Nil(t, other.Unlock(ctx))
+	assert.True(t, <-done)
+	assert.Equal(t, HeldExclusive, batch.Status())
+
+	// Without waiters the lock is kept.
+	time.Sleep(100 * time.Millisecond)
+	yielded, err = batch.Yield(ctx)
+	assert.Nil(t, err)
+	assert.False(t, yielded)
+	assert.Nil(t, batch.Unlock(ctx))
+}